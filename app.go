@@ -3,26 +3,28 @@ package main
 import (
 	"archive/zip"
 	"context"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"log"
-	"math/big"
-	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
-	"software.sslmate.com/src/go-pkcs12"
 )
 
 // App struct
 type App struct {
 	ctx context.Context
+
+	ocspMu     sync.Mutex
+	ocspServer *http.Server
 }
 
 // NewApp creates a new App application struct
@@ -37,68 +39,135 @@ func (a *App) startup(ctx context.Context) {
 	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
 		os.MkdirAll(outputDir, 0755)
 	}
+	ensureInventory()
 }
 
 // CAInput holds the details for the Certificate Authority.
 type CAInput struct {
-	Country    string `json:"country"`
-	State      string `json:"state"`
-	Locality   string `json:"locality"`
-	Org        string `json:"org"`
-	CommonName string `json:"commonName"`
-	ExpiryDays int    `json:"expiryDays"`
+	Country      string               `json:"country"`
+	State        string               `json:"state"`
+	Locality     string               `json:"locality"`
+	Org          string               `json:"org"`
+	CommonName   string               `json:"commonName"`
+	ExpiryDays   int                  `json:"expiryDays"`
+	KeyAlgorithm string               `json:"keyAlgorithm"`
+	PathLen      int                  `json:"pathLen"`     // -1 for no limit
+	Profile      string               `json:"profile"`     // Certificate profile; empty for "ca"
+	Passphrase   string               `json:"passphrase"`  // Encrypts the CA key when set
+	Token        TokenInput           `json:"token"`       // Generates the key on a PKCS#11 token when Module is set
+	Constraints  NameConstraintsInput `json:"constraints"` // Names the CA may issue for
+}
+
+// CertInput holds the details for a device certificate.
+type CertInput struct {
+	CommonName    string       `json:"commonName"`
+	SANs          string       `json:"sans"` // Comma-separated
+	CAName        string       `json:"caName"`
+	ExpiryDays    int          `json:"expiryDays"`    // 0 uses the profile's validity
+	KeyAlgorithm  string       `json:"keyAlgorithm"`  // Empty for the default device key algorithm
+	Profile       string       `json:"profile"`       // Empty for "tls-server-client"
+	Subject       SubjectInput `json:"subject"`       // Empty components are inherited from the CA's default subject
+	KeyPassphrase string       `json:"keyPassphrase"` // Encrypts the device key when set
 }
 
 // CertDetails holds the inspected information for a certificate.
 type CertDetails struct {
-	Subject      string   `json:"subject"`
-	Issuer       string   `json:"issuer"`
-	ValidFrom    string   `json:"validFrom"`
-	ValidUntil   string   `json:"validUntil"`
-	SerialNumber string   `json:"serialNumber"`
-	IPAddresses  []string `json:"ipAddresses"`
-	DNSNames     []string `json:"dnsNames"`
+	Subject            string          `json:"subject"`
+	Issuer             string          `json:"issuer"`
+	ValidFrom          string          `json:"validFrom"`
+	ValidUntil         string          `json:"validUntil"`
+	SerialNumber       string          `json:"serialNumber"`
+	IPAddresses        []string        `json:"ipAddresses"`
+	DNSNames           []string        `json:"dnsNames"`
+	Emails             []string        `json:"emails"`
+	URIs               []string        `json:"uris"`
+	UPNs               []string        `json:"upns"`
+	Status             string          `json:"status"`
+	Revoked            bool            `json:"revoked"`
+	RevokeReason       string          `json:"revokeReason"`
+	RevokedAt          string          `json:"revokedAt"`
+	SubjectDN          string          `json:"subjectDn"`
+	IssuerDN           string          `json:"issuerDn"`
+	SelfSigned         bool            `json:"selfSigned"`
+	KeyAlgorithm       string          `json:"keyAlgorithm"`
+	KeyBits            int             `json:"keyBits"`
+	SignatureAlgorithm string          `json:"signatureAlgorithm"`
+	KeyUsage           []string        `json:"keyUsage"`
+	ExtKeyUsage        []string        `json:"extKeyUsage"`
+	IsCA               bool            `json:"isCA"`
+	PathLen            int             `json:"pathLen"` // -1 when not limited
+	SubjectKeyID       string          `json:"subjectKeyId"`
+	AuthorityKeyID     string          `json:"authorityKeyId"`
+	CRLURLs            []string        `json:"crlUrls"`
+	OCSPURLs           []string        `json:"ocspUrls"`
+	CAIssuerURLs       []string        `json:"caIssuerUrls"`
+	Policies           []string        `json:"policies"`
+	Extensions         []CertExtension `json:"extensions"` // Every extension, including ones this app does not know
+	SHA1Fingerprint    string          `json:"sha1Fingerprint"`
+	SHA256Fingerprint  string          `json:"sha256Fingerprint"`
+	SPKIPin            string          `json:"spkiPin"` // Base64 SHA-256 of the SubjectPublicKeyInfo, for HPKP-style pinning
+	Text               string          `json:"text"`    // Full dump in the style of "openssl x509 -text"
 }
 
-// ListCAs scans the output directory for CA files.
-func (a *App) ListCAs() []string {
-	var cas []string
-	files, err := os.ReadDir(outputDir)
-	if err != nil {
-		log.Printf("Could not read output directory: %v", err)
-		return cas
-	}
-
-	for _, file := range files {
-		fileName := file.Name()
-		if !file.IsDir() && strings.HasSuffix(fileName, ".pem") && !strings.Contains(fileName, "_signed-by_") {
-			keyFileName := strings.TrimSuffix(fileName, ".pem") + ".key"
-			if fileExists(filepath.Join(outputDir, keyFileName)) {
-				cas = append(cas, strings.TrimSuffix(fileName, ".pem"))
-			}
-		}
-	}
-	return cas
+// ListCAs returns the CAs in the inventory as parent/child trees.
+func (a *App) ListCAs() []CANode {
+	return buildCATree(storeCAs())
 }
 
 // CreateCA generates the root CA key and certificate.
 func (a *App) CreateCA(input CAInput) string {
+	return createCA(input, "")
+}
+
+// CreateIntermediateCA generates an intermediate CA signed by an existing CA.
+func (a *App) CreateIntermediateCA(input CAInput, parentName string) string {
+	if parentName == "" {
+		return "Error: You must select a parent CA to sign the intermediate with."
+	}
+	return createCA(input, parentName)
+}
+
+// createCA creates a CA signed by parentName, or a self-signed root when parentName is empty.
+func createCA(input CAInput, parentName string) string {
 	if input.CommonName == "" {
 		return "Error: CA Common Name cannot be empty."
 	}
+	profile, err := findProfile(input.Profile, defaultCAProfile)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if !profile.IsCA {
+		return fmt.Sprintf("Error: Profile '%s' is not a CA profile.", profile.Name)
+	}
+	if input.ExpiryDays <= 0 {
+		input.ExpiryDays = profile.ValidityDays
+	}
 	if input.ExpiryDays <= 0 {
 		input.ExpiryDays = 3650 // Default to 10 years
 	}
+	if input.Passphrase != "" && len(input.Passphrase) < minPassphraseLength {
+		return fmt.Sprintf("Error: The CA passphrase must be at least %d characters.", minPassphraseLength)
+	}
+	onToken := input.Token.Module != ""
+	if onToken && input.Passphrase != "" {
+		return "Error: A key on a PKCS#11 token is protected by the token PIN; leave the passphrase blank."
+	}
 
-	caKeyPath := filepath.Join(outputDir, fmt.Sprintf("%s.key", input.CommonName))
-	caCertPath := filepath.Join(outputDir, fmt.Sprintf("%s.pem", input.CommonName))
+	caName := safeFileName(input.CommonName)
+	caKeyFile := fmt.Sprintf("%s.key", caName)
+	caCertFile := fmt.Sprintf("%s.pem", caName)
 
-	if fileExists(caKeyPath) || fileExists(caCertPath) {
-		return fmt.Sprintf("Error: A CA with the name '%s' already exists.", input.CommonName)
+	if _, exists := storeCAs()[caName]; exists || fileExists(filepath.Join(outputDir, caKeyFile)) || fileExists(filepath.Join(outputDir, caCertFile)) {
+		return fmt.Sprintf("Error: A CA with the name '%s' already exists.", caName)
+	}
+
+	serial, err := newSerialNumber(parentName)
+	if err != nil {
+		return fmt.Sprintf("Error generating serial number: %v", err)
 	}
 
 	template := &x509.Certificate{
-		SerialNumber: big.NewInt(time.Now().Unix()),
+		SerialNumber: serial,
 		Subject: pkix.Name{
 			Country:      []string{input.Country},
 			Province:     []string{input.State},
@@ -106,249 +175,221 @@ func (a *App) CreateCA(input CAInput) string {
 			Organization: []string{input.Org},
 			CommonName:   input.CommonName,
 		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(time.Duration(input.ExpiryDays) * 24 * time.Hour),
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign, // Removed CRLSign
-		BasicConstraintsValid: true,
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Duration(input.ExpiryDays) * 24 * time.Hour),
 	}
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, rsaBitsCA)
-	if err != nil {
-		return fmt.Sprintf("Error generating private key: %v", err)
+	var privateKey crypto.Signer
+	var tokenRef *pkcs11Ref
+	var tokenKey *tokenSigner
+	if onToken {
+		tokenRef, tokenKey, err = generateTokenKey(input.Token, caName, input.KeyAlgorithm, defaultCAKeyAlgorithm)
+		if err != nil {
+			return fmt.Sprintf("Error generating private key: %v", err)
+		}
+		// Remove the key from the token again if the CA is not saved
+		defer func() {
+			if tokenKey != nil {
+				tokenKey.discard()
+			}
+		}()
+		privateKey = tokenKey
+	} else {
+		privateKey, err = generateKey(input.KeyAlgorithm, defaultCAKeyAlgorithm)
+		if err != nil {
+			return fmt.Sprintf("Error generating private key: %v", err)
+		}
+	}
+	if err := profile.apply(template, privateKey.Public()); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := input.Constraints.apply(template); err != nil {
+		return fmt.Sprintf("Error: Invalid name constraints: %v", err)
+	}
+	if parentName == "" && input.PathLen >= 0 {
+		template.MaxPathLen = input.PathLen
+		template.MaxPathLenZero = input.PathLen == 0
 	}
 
-	caBytes, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	// A root signs itself; an intermediate is signed by its parent
+	parentCert, parentKey := template, privateKey
+	if parentName != "" {
+		parentCert, parentKey, err = loadCA(parentName)
+		if err != nil {
+			return fmt.Sprintf("Error loading parent CA: %v", err)
+		}
+		pathLen, err := childPathLen(parentCert, input.PathLen)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		if pathLen >= 0 {
+			template.MaxPathLen = pathLen
+			template.MaxPathLenZero = pathLen == 0
+		}
+		// An intermediate cannot outlive the CA that signed it
+		if template.NotAfter.After(parentCert.NotAfter) {
+			template.NotAfter = parentCert.NotAfter
+		}
+		if err := checkIssuerEKU(parentCert, template); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		applyRevocationURLs(template, parentName)
+	}
+
+	caBytes, err := x509.CreateCertificate(rand.Reader, template, parentCert, privateKey.Public(), parentKey)
 	if err != nil {
 		return fmt.Sprintf("Error creating certificate: %v", err)
 	}
 
-	certOut, err := os.Create(caCertPath)
-	if err != nil {
+	if err := writeCertPEM(caCertFile, caBytes); err != nil {
 		return fmt.Sprintf("Error saving CA cert: %v", err)
 	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: caBytes})
-	certOut.Close()
-
-	keyOut, err := os.OpenFile(caKeyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if onToken {
+		err = os.WriteFile(filepath.Join(outputDir, caKeyFile), []byte(tokenRef.String()+"\n"), 0600)
+	} else {
+		err = writeKeyPEM(caKeyFile, privateKey, input.Passphrase)
+	}
 	if err != nil {
 		return fmt.Sprintf("Error saving CA key: %v", err)
 	}
-	// Marshal to PKCS#8 for modern compatibility
-	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
-	if err != nil {
-		return fmt.Sprintf("Error converting key to PKCS#8: %v", err)
+	if tokenKey != nil {
+		// Stay logged in so the new CA can sign straight away
+		holdKey(caKeyFile, tokenKey)
+		tokenKey = nil
+	}
+	if err := recordIssued(kindCA, caName, caBytes, parentName, caCertFile, caKeyFile); err != nil {
+		return fmt.Sprintf("Error: CA '%s' was created but could not be added to the inventory: %v", caName, err)
 	}
-	pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes})
-	keyOut.Close()
 
-	return fmt.Sprintf("Success! CA '%s' created in the 'output' folder.", input.CommonName)
+	if onToken {
+		return fmt.Sprintf("Success! CA '%s' created in the 'output' folder with its key on token '%s'.", caName, input.Token.Token)
+	}
+	if parentName != "" {
+		return fmt.Sprintf("Success! Intermediate CA '%s' signed by '%s' created in the 'output' folder.", caName, parentName)
+	}
+	return fmt.Sprintf("Success! CA '%s' created in the 'output' folder.", caName)
 }
 
-// CreateCert generates a server/device certificate with a CN and SANs, signed by a chosen CA.
-func (a *App) CreateCert(cn string, sans string, caName string, expiryDays int) string {
+// CreateCert generates a server/device certificate with a CN, SANs and subject, signed by a chosen CA.
+func (a *App) CreateCert(input CertInput) string {
+	cn, sans, caName := input.CommonName, input.SANs, input.CAName
 	if caName == "" {
 		return "Error: You must select a CA to sign the certificate with."
 	}
-	if expiryDays <= 0 {
-		expiryDays = 730 // Default to 2 years
+	profile, err := leafProfile(input.Profile)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
 	}
 	if cn == "" {
 		return "Error: Common Name (CN) cannot be empty."
 	}
-
-	// The full list of SANs must include the CN
-	allSans := []string{cn}
-	if sans != "" {
-		sanList := strings.Split(sans, ",")
-		for _, s := range sanList {
-			trimmed := strings.TrimSpace(s)
-			if trimmed != "" {
-				// Avoid duplicates
-				isDuplicate := false
-				for _, existingSan := range allSans {
-					if existingSan == trimmed {
-						isDuplicate = true
-						break
-					}
-				}
-				if !isDuplicate {
-					allSans = append(allSans, trimmed)
-				}
-			}
-		}
-	}
-
-	caCert, caPrivateKey, err := loadCA(caName)
-	if err != nil {
-		return fmt.Sprintf("Error loading CA: %v", err)
+	if input.KeyPassphrase != "" && len(input.KeyPassphrase) < minPassphraseLength {
+		return fmt.Sprintf("Error: The key passphrase must be at least %d characters.", minPassphraseLength)
 	}
-
-	template := &x509.Certificate{
-		SerialNumber: big.NewInt(time.Now().Unix()),
-		Subject:      pkix.Name{CommonName: cn},
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(0, 0, expiryDays),
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:     x509.KeyUsageDigitalSignature,
+	settings := loadCASettings(caName)
+	subject := input.Subject.clean().withDefaults(settings.DefaultSubject)
+	if err := subject.validate(); err != nil {
+		return fmt.Sprintf("Error: Invalid subject: %v", err)
 	}
-
-	for _, san := range allSans {
-		ip := net.ParseIP(san)
-		if ip != nil {
-			template.IPAddresses = append(template.IPAddresses, ip)
-		} else {
-			template.DNSNames = append(template.DNSNames, san)
-		}
-	}
-
-	deviceKey, err := rsa.GenerateKey(rand.Reader, rsaBitsSrv)
+	rawSubject, err := subjectDN(cn, subject)
 	if err != nil {
-		return fmt.Sprintf("Error generating device key: %v", err)
+		return fmt.Sprintf("Error encoding subject: %v", err)
 	}
 
-	deviceCertBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, &deviceKey.PublicKey, caPrivateKey)
+	// The full list of SANs must include the CN when it is a hostname or IP
+	allSans, err := parseSANs(cn, sans)
 	if err != nil {
-		return fmt.Sprintf("Error signing device cert: %v", err)
+		return fmt.Sprintf("Error: Invalid SAN: %v", err)
 	}
 
-	safeFilename := strings.ReplaceAll(cn, "*", "_wildcard")
-	deviceCertFile := filepath.Join(outputDir, fmt.Sprintf("%s_signed-by_%s.pem", safeFilename, caName))
-	deviceKeyFile := filepath.Join(outputDir, fmt.Sprintf("%s_signed-by_%s.key", safeFilename, caName))
-
-	certOut, err := os.Create(deviceCertFile)
+	caCert, caPrivateKey, err := loadCA(caName)
 	if err != nil {
-		return fmt.Sprintf("Error saving device cert: %v", err)
+		return fmt.Sprintf("Error loading CA: %v", err)
 	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: deviceCertBytes})
-	certOut.Close()
 
-	keyOut, err := os.OpenFile(deviceKeyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	notAfter, err := settings.Policy.leafNotAfter(caCert, profile, input.ExpiryDays)
 	if err != nil {
-		return fmt.Sprintf("Error saving device key: %v", err)
+		return fmt.Sprintf("Error: Refused by the policy of CA '%s': %v", caName, err)
 	}
-	// Marshal to PKCS#8 for modern compatibility
-	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(deviceKey)
+
+	serial, err := newSerialNumber(caName)
 	if err != nil {
-		return fmt.Sprintf("Error converting key to PKCS#8: %v", err)
+		return fmt.Sprintf("Error generating serial number: %v", err)
 	}
-	pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes})
-	keyOut.Close()
 
-	return fmt.Sprintf("Success! Certificate for %s created.", cn)
-}
-
-// SignCSR signs a Certificate Signing Request and saves the private key if provided.
-func (a *App) SignCSR(pastedText string, caName string, expiryDays int) string {
-	if caName == "" {
-		return "Error: You must select a CA to sign the request with."
-	}
-	if expiryDays <= 0 {
-		expiryDays = 730 // Default to 2 years
-	}
-	if pastedText == "" {
-		return "Error: Pasted text cannot be empty."
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		RawSubject:   rawSubject,
+		NotBefore:    time.Now(),
+		NotAfter:     notAfter,
 	}
 
-	// Find and separate CSR and Private Key from the pasted text
-	var csrBlock, keyBlock *pem.Block
-	rest := []byte(pastedText)
-	for {
-		var block *pem.Block
-		block, rest = pem.Decode(rest)
-		if block == nil {
-			break
-		}
-		if block.Type == "CERTIFICATE REQUEST" {
-			csrBlock = block
-		} else if strings.Contains(block.Type, "PRIVATE KEY") {
-			keyBlock = block
-		}
-	}
+	applyRevocationURLs(template, caName)
 
-	if csrBlock == nil {
-		return "Error: No valid CSR found in the pasted text."
+	if err := allSans.apply(template); err != nil {
+		return fmt.Sprintf("Error encoding SANs: %v", err)
 	}
 
-	csr, err := x509.ParseCertificateRequest(csrBlock.Bytes)
+	deviceKey, err := generateKey(input.KeyAlgorithm, defaultCertKeyAlgorithm)
 	if err != nil {
-		return fmt.Sprintf("Error parsing CSR: %v", err)
+		return fmt.Sprintf("Error generating device key: %v", err)
 	}
-
-	if err := csr.CheckSignature(); err != nil {
-		return fmt.Sprintf("Error verifying CSR signature: %v", err)
+	if err := profile.apply(template, deviceKey.Public()); err != nil {
+		return fmt.Sprintf("Error: %v", err)
 	}
-
-	caCert, caPrivateKey, err := loadCA(caName)
-	if err != nil {
-		return fmt.Sprintf("Error loading CA: %v", err)
+	if err := settings.Policy.check(cn, template, deviceKey.Public(), profile.Name); err != nil {
+		return fmt.Sprintf("Error: Refused by the policy of CA '%s': %v", caName, err)
+	}
+	if err := checkNameConstraints(caName, cn, template); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := checkIssuerEKU(caCert, template); err != nil {
+		return fmt.Sprintf("Error: %v", err)
 	}
 
-	template := &x509.Certificate{
-		SerialNumber:    big.NewInt(time.Now().Unix()),
-		Subject:         csr.Subject,
-		DNSNames:        csr.DNSNames,
-		IPAddresses:     csr.IPAddresses,
-		EmailAddresses:  csr.EmailAddresses,
-		NotBefore:       time.Now(),
-		NotAfter:        time.Now().AddDate(0, 0, expiryDays),
-		KeyUsage:        x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
-	}
-
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caPrivateKey)
+	deviceCertBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, deviceKey.Public(), caPrivateKey)
 	if err != nil {
-		return fmt.Sprintf("Error signing certificate from CSR: %v", err)
+		return fmt.Sprintf("Error signing device cert: %v", err)
 	}
 
-	cn := csr.Subject.CommonName
-	if cn == "" {
-		cn = "signed_cert" // Fallback filename
+	baseName := uniqueCertFileBase(cn, caName, template.SerialNumber.Text(16))
+	deviceCertFile := baseName + ".pem"
+	deviceKeyFile := baseName + ".key"
+
+	if err := writeCertPEM(deviceCertFile, deviceCertBytes); err != nil {
+		return fmt.Sprintf("Error saving device cert: %v", err)
 	}
-	safeFilename := strings.ReplaceAll(cn, "*", "_wildcard")
-	
-	// Save the signed certificate
-	certFile := filepath.Join(outputDir, fmt.Sprintf("%s_signed-by_%s.pem", safeFilename, caName))
-	certOut, err := os.Create(certFile)
-	if err != nil {
-		return fmt.Sprintf("Error saving signed certificate: %v", err)
+	if err := writeKeyPEM(deviceKeyFile, deviceKey, input.KeyPassphrase); err != nil {
+		return fmt.Sprintf("Error saving device key: %v", err)
 	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
-	certOut.Close()
-
-	// If a private key was also pasted, save it with a matching name
-	if keyBlock != nil {
-		keyFile := filepath.Join(outputDir, fmt.Sprintf("%s_signed-by_%s.key", safeFilename, caName))
-		keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-		if err != nil {
-			return fmt.Sprintf("Success! Certificate for %s signed. However, failed to save private key: %v", cn, err)
-		}
-		pem.Encode(keyOut, keyBlock)
-		keyOut.Close()
-		return fmt.Sprintf("Success! Certificate for %s signed and private key was saved.", cn)
+	if err := recordIssued(kindCert, deviceCertFile, deviceCertBytes, caName, deviceCertFile, deviceKeyFile); err != nil {
+		return fmt.Sprintf("Error: Certificate '%s' was created but could not be added to the inventory: %v", deviceCertFile, err)
 	}
 
-	return fmt.Sprintf("Success! Certificate for %s signed and created.", cn)
+	return fmt.Sprintf("Success! Certificate for %s created as '%s'.", cn, deviceCertFile)
 }
 
+// SignCSR signs a Certificate Signing Request and saves the private key if provided.
+// An empty profile selects "tls-server-client"; expiryDays of 0 uses the profile's validity.
+// A pasted plaintext key is encrypted with keyPassphrase when one is given.
+func (a *App) SignCSR(pastedText string, caName string, expiryDays int, profileName string, keyPassphrase string) string {
+	return a.SignReviewedCSR(CSRSignInput{
+		CSR:           pastedText,
+		CAName:        caName,
+		ExpiryDays:    expiryDays,
+		Profile:       profileName,
+		KeyPassphrase: keyPassphrase,
+	})
+}
 
-// ListCerts scans the output directory and returns a list of generated device .pem files.
-func (a *App) ListCerts() []string {
-	var certs []string
-	files, err := os.ReadDir(outputDir)
-	if err != nil {
-		log.Printf("Could not read output directory: %v", err)
-		return certs
-	}
 
-	for _, file := range files {
-		fileName := file.Name()
-		if !file.IsDir() && strings.HasSuffix(fileName, ".pem") && strings.Contains(fileName, "_signed-by_") {
-			certs = append(certs, fileName)
-		}
+// ListCerts returns the device certificates in the inventory with their current status.
+func (a *App) ListCerts() []InventoryRecord {
+	records := inventoryRecords(kindCert)
+	for i := range records {
+		records[i].Status = records[i].displayStatus()
 	}
-	return certs
+	return records
 }
 
 // DeleteCA deletes the .pem and .key file for a given CA.
@@ -356,11 +397,27 @@ func (a *App) DeleteCA(caName string) string {
 	if caName == "" {
 		return "Error: No CA name provided for deletion."
 	}
+	for name, ca := range storeCAs() {
+		if name != caName && ca.issuer == caName {
+			return fmt.Sprintf("Error: CA '%s' has signed the intermediate CA '%s'. Delete the intermediate first.", caName, name)
+		}
+	}
 	caKeyPath := filepath.Join(outputDir, fmt.Sprintf("%s.key", caName))
 	caCertPath := filepath.Join(outputDir, fmt.Sprintf("%s.pem", caName))
 
+	lockKey(fmt.Sprintf("%s.key", caName))
 	os.Remove(caKeyPath)
 	os.Remove(caCertPath)
+	if err := removeFromInventory(kindCA, caName); err != nil {
+		log.Printf("Could not remove CA '%s' from the inventory: %v", caName, err)
+	}
+	os.Remove(filepath.Join(outputDir, fmt.Sprintf("%s.crl", caName)))
+	os.Remove(filepath.Join(outputDir, fmt.Sprintf("%s.crl.pem", caName)))
+	os.Remove(filepath.Join(outputDir, fmt.Sprintf("%s.ocsp-signer.pem", caName)))
+	os.Remove(filepath.Join(outputDir, fmt.Sprintf("%s.ocsp-signer.key", caName)))
+	if err := removeCASettings(caName); err != nil {
+		log.Printf("Could not remove settings for CA '%s': %v", caName, err)
+	}
 
 	return fmt.Sprintf("Success! CA '%s' and all related files have been deleted.", caName)
 }
@@ -370,14 +427,27 @@ func (a *App) DeleteCert(certName string) string {
 	if certName == "" {
 		return "Error: No certificate name provided for deletion."
 	}
-	keyName := strings.TrimSuffix(certName, ".pem") + ".key"
-	certPath := filepath.Join(outputDir, certName)
-	keyPath := filepath.Join(outputDir, keyName)
+	certFile, keyFile := certName, certKeyFile(certName)
+	for _, r := range inventoryRecords(kindCert) {
+		if r.Name == certName {
+			certFile = r.CertFile
+		}
+	}
 
-	errCert := os.Remove(certPath)
-	errKey := os.Remove(keyPath)
+	errCert := os.Remove(filepath.Join(outputDir, certFile))
+	var errKey error
+	if keyFile != "" {
+		lockKey(keyFile)
+		errKey = os.Remove(filepath.Join(outputDir, keyFile))
+		if os.IsNotExist(errKey) {
+			errKey = nil
+		}
+	}
+	if err := removeFromInventory(kindCert, certName); err != nil {
+		log.Printf("Could not remove certificate '%s' from the inventory: %v", certName, err)
+	}
 
-	if errKey != nil || errCert != nil {
+	if errKey != nil || (errCert != nil && !os.IsNotExist(errCert)) {
 		return fmt.Sprintf("Error deleting files for certificate '%s'.", certName)
 	}
 	return fmt.Sprintf("Success! Certificate '%s' has been deleted.", certName)
@@ -389,11 +459,19 @@ func (a *App) InspectCert(certName string) (*CertDetails, error) {
 	if err != nil {
 		return nil, err
 	}
+	return certDetails(cert), nil
+}
 
+// certDetails describes a certificate in full, with its status in the store.
+func certDetails(cert *x509.Certificate) *CertDetails {
 	var ips []string
 	for _, ip := range cert.IPAddresses {
 		ips = append(ips, ip.String())
 	}
+	var uris []string
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
 
 	details := &CertDetails{
 		Subject:      cert.Subject.CommonName,
@@ -403,51 +481,19 @@ func (a *App) InspectCert(certName string) (*CertDetails, error) {
 		SerialNumber: cert.SerialNumber.String(),
 		IPAddresses:  ips,
 		DNSNames:     cert.DNSNames,
+		Emails:       cert.EmailAddresses,
+		URIs:         uris,
+		UPNs:         upnsOf(cert),
+		Status:       certStatus(cert),
 	}
-
-	return details, nil
-}
-
-// ExportToPFX exports a certificate and its key to a PFX/P12 file.
-func (a *App) ExportToPFX(certName string, password string) string {
-	// Load the device certificate
-	cert, err := loadCert(certName)
-	if err != nil {
-		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
-	}
-
-	// Load the device's private key
-	keyPath := filepath.Join(outputDir, strings.TrimSuffix(certName, ".pem")+".key")
-	keyData, err := os.ReadFile(keyPath)
-	if err != nil {
-		return fmt.Sprintf("Error loading private key: %v", err)
-	}
-	keyBlock, _ := pem.Decode(keyData)
-	privateKey, err := parsePrivateKey(keyBlock.Bytes)
-	if err != nil {
-		return fmt.Sprintf("Error parsing private key: %v", err)
+	if entry := revocationStatus(cert); entry != nil {
+		details.Revoked = true
+		details.RevokeReason = entry.Reason
+		details.RevokedAt = entry.RevokedAt.Format(time.RFC1123)
 	}
+	describeCert(cert, details)
 
-	// Load the issuing CA to include in the chain
-	caCert, _, err := loadCA(cert.Issuer.CommonName)
-	if err != nil {
-		return fmt.Sprintf("Error loading issuing CA '%s': %v", cert.Issuer.CommonName, err)
-	}
-
-	// Create the PFX data using the sslmate package.
-	pfxData, err := pkcs12.Encode(rand.Reader, privateKey, cert, []*x509.Certificate{caCert}, password)
-	if err != nil {
-		return fmt.Sprintf("Error creating PFX file: %v", err)
-	}
-
-	// Save the PFX file
-	pfxPath := filepath.Join(outputDir, strings.TrimSuffix(certName, ".pem")+".pfx")
-	err = os.WriteFile(pfxPath, pfxData, 0644)
-	if err != nil {
-		return fmt.Sprintf("Error saving PFX file: %v", err)
-	}
-
-	return fmt.Sprintf("Success! Exported to '%s'.", pfxPath)
+	return details
 }
 
 // GenerateInstaller creates a zip file with the CA cert and an installation script.
@@ -464,6 +510,10 @@ func (a *App) GenerateInstaller(caName string) string {
 	if !fileExists(caCertPath) {
 		return fmt.Sprintf("Error: CA certificate for '%s' not found.", caName)
 	}
+	caCert, err := loadCert(caName + ".pem")
+	if err != nil {
+		return fmt.Sprintf("Error reading CA certificate: %v", err)
+	}
 
 	// Create the zip file
 	zipFile, err := os.Create(zipPath)
@@ -475,6 +525,15 @@ func (a *App) GenerateInstaller(caName string) string {
 	zipWriter := zip.NewWriter(zipFile)
 	defer zipWriter.Close()
 
+	// Only roots belong in the Trusted Root store; intermediates go in the Intermediate store alone
+	rootStep := ""
+	if isSelfSigned(caCert) {
+		rootStep = fmt.Sprintf(`echo [*] Attempting to install '%s' certificate into Trusted Root store...
+certutil.exe -addstore -f "ROOT" "%%~dp0%s.pem"
+
+echo.`, caName, caName)
+	}
+
 	// Add the install script to the zip with the new, more reliable admin check and embedded filename
 	batchContent := fmt.Sprintf(`@echo off
 setlocal
@@ -486,17 +545,14 @@ if %%errorLevel%% == 1 (
 	goto :eof
 )
 
-echo [*] Attempting to install '%s' certificate into Trusted Root store...
-certutil.exe -addstore -f "ROOT" "%%~dp0%s.pem"
-
-echo.
+%s
 echo [*] Attempting to install '%s' certificate into Intermediate store...
 certutil.exe -addstore -f "CA" "%%~dp0%s.pem"
 
 echo.
 echo [INFO] Installation process complete. Check for errors above.
 pause
-endlocal`, caName, caName, caName, caName)
+endlocal`, rootStep, caName, caName)
 
 	scriptWriter, err := zipWriter.Create("install-ca.bat")
 	if err != nil {
@@ -530,6 +586,24 @@ func (a *App) OpenOutputDir() string {
 }
 
 // --- Helper Functions ---
+
+// certStatus summarises a certificate as valid, expired, revoked, onHold or superseded.
+func certStatus(cert *x509.Certificate) string {
+	if record := inventoryRecordFor(cert); record != nil {
+		return record.displayStatus()
+	}
+	if entry := revocationStatus(cert); entry != nil {
+		if entry.Reason == reasonCertificateHold {
+			return "onHold"
+		}
+		return "revoked"
+	}
+	if time.Now().After(cert.NotAfter) {
+		return "expired"
+	}
+	return "valid"
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -538,45 +612,63 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-func parsePrivateKey(derBytes []byte) (*rsa.PrivateKey, error) {
-	// Try PKCS#8 first
-	if key, err := x509.ParsePKCS8PrivateKey(derBytes); err == nil {
-		rsaKey, ok := key.(*rsa.PrivateKey)
-		if !ok {
-			return nil, fmt.Errorf("key is not an RSA private key")
-		}
-		return rsaKey, nil
+// writeCertPEM saves a DER certificate in the output folder as PEM.
+func writeCertPEM(fileName string, der []byte) error {
+	return os.WriteFile(filepath.Join(outputDir, fileName), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// writeKeyPEM saves a private key in the output folder as PKCS#8 PEM, readable only by the owner.
+// A non-empty passphrase writes an encrypted PKCS#8 key instead. An existing key file is
+// replaced only once the new one is fully written.
+func writeKeyPEM(fileName string, key crypto.Signer, passphrase string) error {
+	block := &pem.Block{Type: "PRIVATE KEY"}
+	var err error
+	if passphrase != "" {
+		block.Type = encryptedKeyPEMType
+		block.Bytes, err = encryptPrivateKey(key, []byte(passphrase))
+	} else {
+		// Marshal to PKCS#8 for modern compatibility
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(key)
 	}
-	// Then try PKCS#1
-	if key, err := x509.ParsePKCS1PrivateKey(derBytes); err == nil {
-		return key, nil
+	if err != nil {
+		return fmt.Errorf("could not convert key to PKCS#8: %w", err)
 	}
-	return nil, fmt.Errorf("failed to parse private key: unsupported format")
+	return replaceFile(fileName, pem.EncodeToMemory(block), 0600)
 }
 
-func loadCA(caName string) (*x509.Certificate, *rsa.PrivateKey, error) {
-	cert, err := loadCert(caName + ".pem")
+// recordIssued adds a newly written CA or certificate to the inventory.
+func recordIssued(kind, name string, der []byte, issuer, certFile, keyFile string) error {
+	cert, err := x509.ParseCertificate(der)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
+	return addToInventory(kind, name, cert, issuer, certFile, keyFile)
+}
 
-	caKeyPath := filepath.Join(outputDir, fmt.Sprintf("%s.key", caName))
-	keyData, err := os.ReadFile(caKeyPath)
+func loadCA(caName string) (*x509.Certificate, crypto.Signer, error) {
+	cert, err := loadCert(caName + ".pem")
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not read key file: %w", err)
-	}
-	keyBlock, _ := pem.Decode(keyData)
-	if keyBlock == nil {
-		return nil, nil, fmt.Errorf("could not decode PEM block from key")
+		return nil, nil, err
 	}
-	privateKey, err := parsePrivateKey(keyBlock.Bytes)
+
+	privateKey, err := loadPrivateKey(fmt.Sprintf("%s.key", caName))
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not parse private key: %w", err)
+		return nil, nil, err
 	}
 
 	return cert, privateKey, nil
 }
 
+// certKeyFile returns the key file of a device certificate, or "" if it has none.
+func certKeyFile(certName string) string {
+	for _, r := range inventoryRecords(kindCert) {
+		if r.Name == certName {
+			return r.KeyFile
+		}
+	}
+	return existingKeyFile(strings.TrimSuffix(certName, ".pem"))
+}
+
 func loadCert(certFileName string) (*x509.Certificate, error) {
 	certPath := filepath.Join(outputDir, certFileName)
 	pemData, err := os.ReadFile(certPath)
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sync"
+)
+
+// caSettingsFile holds per-CA settings inside the output directory.
+const caSettingsFile = "ca-settings.json"
+
+// CASettings holds the configurable options of a single CA.
+type CASettings struct {
+	CRLURL          string       `json:"crlUrl"`          // CRL Distribution Point added to issued certificates
+	CRLValidityDays int          `json:"crlValidityDays"` // Days until a generated CRL's nextUpdate
+	CRLNumber       int64        `json:"crlNumber"`       // Last CRL number issued; managed by the app
+	OCSPURL         string       `json:"ocspUrl"`         // OCSP responder URL added to issued certificates (AIA)
+	OCSPDelegated   bool         `json:"ocspDelegated"`   // Sign OCSP responses with a delegated signer instead of the CA key
+	DefaultSubject  SubjectInput `json:"defaultSubject"`  // Subject components inherited by new device certificates
+	Policy          CAPolicy     `json:"policy"`          // Limits on the certificates the CA issues
+}
+
+// caSettingsMu serialises access to the settings file.
+var caSettingsMu sync.Mutex
+
+func loadAllCASettings() (map[string]*CASettings, error) {
+	all := make(map[string]*CASettings)
+	if err := readJSON(caSettingsFile, &all); err != nil {
+		return nil, fmt.Errorf("could not read CA settings: %w", err)
+	}
+	return all, nil
+}
+
+// loadCASettings returns the settings for a CA, or defaults if none are saved.
+func loadCASettings(caName string) CASettings {
+	caSettingsMu.Lock()
+	defer caSettingsMu.Unlock()
+	all, err := loadAllCASettings()
+	if err != nil || all[caName] == nil {
+		return CASettings{}
+	}
+	return *all[caName]
+}
+
+// updateCASettings applies fn to the stored settings for a CA and saves the result.
+func updateCASettings(caName string, fn func(*CASettings) error) error {
+	caSettingsMu.Lock()
+	defer caSettingsMu.Unlock()
+	all, err := loadAllCASettings()
+	if err != nil {
+		return err
+	}
+	settings := all[caName]
+	if settings == nil {
+		settings = &CASettings{}
+		all[caName] = settings
+	}
+	if err := fn(settings); err != nil {
+		return err
+	}
+	if err := writeJSON(caSettingsFile, all); err != nil {
+		return fmt.Errorf("could not save CA settings: %w", err)
+	}
+	return nil
+}
+
+// removeCASettings forgets all settings for a deleted CA.
+func removeCASettings(caName string) error {
+	caSettingsMu.Lock()
+	defer caSettingsMu.Unlock()
+	all, err := loadAllCASettings()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[caName]; !ok {
+		return nil
+	}
+	delete(all, caName)
+	return writeJSON(caSettingsFile, all)
+}
+
+// GetCASettings returns the saved settings for a CA.
+func (a *App) GetCASettings(caName string) CASettings {
+	return loadCASettings(caName)
+}
+
+// SetCASettings saves the user-editable settings for a CA.
+func (a *App) SetCASettings(caName string, input CASettings) string {
+	if caName == "" {
+		return "Error: You must select a CA."
+	}
+	if !fileExists(filepath.Join(outputDir, fmt.Sprintf("%s.pem", caName))) {
+		return fmt.Sprintf("Error: CA '%s' not found.", caName)
+	}
+	if input.CRLValidityDays < 0 {
+		return "Error: CRL validity cannot be negative."
+	}
+	if err := checkURL(input.CRLURL); err != nil {
+		return fmt.Sprintf("Error: Invalid CRL URL: %v", err)
+	}
+	if err := checkURL(input.OCSPURL); err != nil {
+		return fmt.Sprintf("Error: Invalid OCSP URL: %v", err)
+	}
+	defaultSubject := input.DefaultSubject.clean()
+	if err := defaultSubject.validate(); err != nil {
+		return fmt.Sprintf("Error: Invalid default subject: %v", err)
+	}
+	policy, err := input.Policy.clean()
+	if err != nil {
+		return fmt.Sprintf("Error: Invalid policy: %v", err)
+	}
+	err = updateCASettings(caName, func(s *CASettings) error {
+		s.CRLURL = input.CRLURL
+		s.CRLValidityDays = input.CRLValidityDays
+		s.OCSPURL = input.OCSPURL
+		s.OCSPDelegated = input.OCSPDelegated
+		s.DefaultSubject = defaultSubject
+		s.Policy = policy
+		return nil
+	})
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return fmt.Sprintf("Success! Settings for CA '%s' saved.", caName)
+}
+
+// checkURL accepts an empty string or an absolute http, https or ldap URL.
+func checkURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "ldap":
+	default:
+		return fmt.Errorf("'%s' must start with http://, https:// or ldap://", raw)
+	}
+	if u.Host == "" && u.Scheme != "ldap" {
+		return fmt.Errorf("'%s' has no host", raw)
+	}
+	return nil
+}
+
+// applyRevocationURLs adds the issuing CA's CRL Distribution Point and OCSP
+// responder URLs, if configured, to a new certificate.
+func applyRevocationURLs(template *x509.Certificate, caName string) {
+	settings := loadCASettings(caName)
+	if settings.CRLURL != "" {
+		template.CRLDistributionPoints = []string{settings.CRLURL}
+	}
+	if settings.OCSPURL != "" {
+		template.OCSPServer = []string{settings.OCSPURL}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultCRLValidityDays is used when a CA has no CRL validity configured.
+const defaultCRLValidityDays = 7
+
+// GenerateCRL signs a new CRL for the CA listing every certificate it has revoked.
+// nextUpdateDays overrides the CA's configured CRL validity when positive.
+func (a *App) GenerateCRL(caName string, nextUpdateDays int) string {
+	if caName == "" {
+		return "Error: You must select a CA to generate a CRL for."
+	}
+	crlPath, err := generateCRL(caName, nextUpdateDays)
+	if err != nil {
+		return fmt.Sprintf("Error generating CRL: %v", err)
+	}
+	return fmt.Sprintf("Success! CRL for '%s' written to '%s'.", caName, crlPath)
+}
+
+// generateCRL writes <caName>.crl (DER) and a <caName>.crl.pem copy and
+// returns the DER path.
+func generateCRL(caName string, nextUpdateDays int) (string, error) {
+	caCert, caKey, err := loadCA(caName)
+	if err != nil {
+		return "", err
+	}
+	if caCert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return "", fmt.Errorf("CA '%s' was created without the CRL Sign key usage and cannot sign CRLs; create a new CA to use CRLs", caName)
+	}
+
+	settings := loadCASettings(caName)
+	if nextUpdateDays <= 0 {
+		nextUpdateDays = settings.CRLValidityDays
+	}
+	if nextUpdateDays <= 0 {
+		nextUpdateDays = defaultCRLValidityDays
+	}
+
+	revocationMu.Lock()
+	reg, err := loadRevocations()
+	revocationMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	var entries []x509.RevocationListEntry
+	for _, e := range reg.forIssuer(caName) {
+		serial, ok := new(big.Int).SetString(e.Serial, 16)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: e.RevokedAt,
+			ReasonCode:     e.ReasonCode,
+		})
+	}
+
+	// Every CRL needs a number strictly greater than the last one issued
+	var crlNumber int64
+	err = updateCASettings(caName, func(s *CASettings) error {
+		s.CRLNumber++
+		crlNumber = s.CRLNumber
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	template := &x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		Number:                    big.NewInt(crlNumber),
+		ThisUpdate:                now,
+		NextUpdate:                now.AddDate(0, 0, nextUpdateDays),
+	}
+	crlBytes, err := x509.CreateRevocationList(rand.Reader, template, caCert, caKey)
+	if err != nil {
+		return "", err
+	}
+
+	crlPath := filepath.Join(outputDir, fmt.Sprintf("%s.crl", caName))
+	if err := os.WriteFile(crlPath, crlBytes, 0644); err != nil {
+		return "", fmt.Errorf("could not save CRL: %w", err)
+	}
+	pemPath := filepath.Join(outputDir, fmt.Sprintf("%s.crl.pem", caName))
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes})
+	if err := os.WriteFile(pemPath, pemBytes, 0644); err != nil {
+		return "", fmt.Errorf("could not save CRL: %w", err)
+	}
+	return crlPath, nil
+}
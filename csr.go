@@ -0,0 +1,320 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pendingFile lists the certificate requests waiting for an external CA.
+const pendingFile = "pending.json"
+
+var (
+	oidBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+)
+
+// CSRInput holds the details of a new key and certificate request for an external CA.
+type CSRInput struct {
+	CommonName    string       `json:"commonName"`
+	SANs          string       `json:"sans"` // Comma-separated
+	KeyAlgorithm  string       `json:"keyAlgorithm"`
+	Subject       SubjectInput `json:"subject"`
+	IsCA          bool         `json:"isCA"`          // Request an intermediate CA certificate
+	PathLen       int          `json:"pathLen"`       // CA requests only; -1 for no limit
+	KeyPassphrase string       `json:"keyPassphrase"` // Encrypts the pending key when set
+}
+
+// PendingRequest is a certificate request whose key is held until the
+// external CA returns the certificate.
+type PendingRequest struct {
+	Name         string    `json:"name"`
+	CommonName   string    `json:"commonName"`
+	IsCA         bool      `json:"isCA"`
+	KeyAlgorithm string    `json:"keyAlgorithm"`
+	CSRFile      string    `json:"csrFile"`
+	KeyFile      string    `json:"keyFile"`
+	CSR          string    `json:"csr"` // PEM, filled in when listing
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type pendingRequests struct {
+	Requests []PendingRequest `json:"requests"`
+}
+
+// pendingMu serialises access to the pending requests file.
+var pendingMu sync.Mutex
+
+func loadPending() (*pendingRequests, error) {
+	pending := &pendingRequests{}
+	if err := readJSON(pendingFile, pending); err != nil {
+		return nil, fmt.Errorf("could not read pending requests: %w", err)
+	}
+	return pending, nil
+}
+
+func (p *pendingRequests) find(name string) int {
+	for i, r := range p.Requests {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// GenerateCSR creates a new key and a certificate request for it, to be
+// signed by a CA outside this app. The key is kept as a pending key until
+// CompleteCSR files the returned certificate.
+func (a *App) GenerateCSR(input CSRInput) string {
+	if input.CommonName == "" {
+		return "Error: Common Name (CN) cannot be empty."
+	}
+	if input.KeyPassphrase != "" && len(input.KeyPassphrase) < minPassphraseLength {
+		return fmt.Sprintf("Error: The key passphrase must be at least %d characters.", minPassphraseLength)
+	}
+	subject := input.Subject.clean()
+	if err := subject.validate(); err != nil {
+		return fmt.Sprintf("Error: Invalid subject: %v", err)
+	}
+	rawSubject, err := subjectDN(input.CommonName, subject)
+	if err != nil {
+		return fmt.Sprintf("Error encoding subject: %v", err)
+	}
+	template := &x509.CertificateRequest{RawSubject: rawSubject}
+	if !input.IsCA {
+		sans, err := parseSANs(input.CommonName, input.SANs)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		template.DNSNames = sans.dnsNames
+		template.IPAddresses = sans.ips
+		template.EmailAddresses = sans.emails
+		template.URIs = sans.uris
+		if len(sans.upns) > 0 {
+			ext, err := sans.marshal()
+			if err != nil {
+				return fmt.Sprintf("Error: %v", err)
+			}
+			template.ExtraExtensions = append(template.ExtraExtensions, ext)
+		}
+	} else {
+		exts, err := caRequestExtensions(input.PathLen)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, exts...)
+	}
+
+	fallback := defaultCertKeyAlgorithm
+	if input.IsCA {
+		fallback = defaultCAKeyAlgorithm
+	}
+	privateKey, err := generateKey(input.KeyAlgorithm, fallback)
+	if err != nil {
+		return fmt.Sprintf("Error generating private key: %v", err)
+	}
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, template, privateKey)
+	if err != nil {
+		return fmt.Sprintf("Error creating certificate request: %v", err)
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending, err := loadPending()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	name := safeFileName(input.CommonName)
+	for n := 2; pending.find(name) >= 0 || fileExists(filepath.Join(outputDir, name+".csr")); n++ {
+		name = fmt.Sprintf("%s-%d", safeFileName(input.CommonName), n)
+	}
+	request := PendingRequest{
+		Name:         name,
+		CommonName:   input.CommonName,
+		IsCA:         input.IsCA,
+		KeyAlgorithm: describePublicKey(privateKey.Public()),
+		CSRFile:      name + ".csr",
+		KeyFile:      name + ".pending.key",
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := writeKeyPEM(request.KeyFile, privateKey, input.KeyPassphrase); err != nil {
+		return fmt.Sprintf("Error saving private key: %v", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
+	if err := os.WriteFile(filepath.Join(outputDir, request.CSRFile), csrPEM, 0644); err != nil {
+		return fmt.Sprintf("Error saving certificate request: %v", err)
+	}
+	pending.Requests = append(pending.Requests, request)
+	if err := writeJSON(pendingFile, pending); err != nil {
+		return fmt.Sprintf("Error: could not save pending requests: %v", err)
+	}
+	return fmt.Sprintf("Success! Certificate request written to '%s'. Send it to your CA, then complete it with the certificate it returns.", filepath.Join(outputDir, request.CSRFile))
+}
+
+// ListPendingRequests returns the requests waiting for a certificate, with their CSRs.
+func (a *App) ListPendingRequests() []PendingRequest {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending, err := loadPending()
+	if err != nil {
+		log.Printf("%v", err)
+		return []PendingRequest{}
+	}
+	requests := []PendingRequest{}
+	for _, r := range pending.Requests {
+		if data, err := os.ReadFile(filepath.Join(outputDir, r.CSRFile)); err == nil {
+			r.CSR = string(data)
+		}
+		requests = append(requests, r)
+	}
+	return requests
+}
+
+// CompleteCSR files the certificate returned by an external CA for a pending
+// request. certFile is the base64 of a PEM or DER certificate, optionally with
+// its chain, or of a PKCS#7 (.p7b) chain as AD CS returns; the certificate must match the pending key. A CA certificate is
+// added as a CA that can sign straight away, anything else as an issued
+// certificate. Chain certificates not yet in the store are added as CAs
+// without keys.
+func (a *App) CompleteCSR(name string, certFile string) string {
+	if name == "" {
+		return "Error: You must select a pending request."
+	}
+	certData, err := base64.StdEncoding.DecodeString(certFile)
+	if err != nil || len(certData) == 0 {
+		return "Error: You must provide the certificate returned by the CA."
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending, err := loadPending()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	i := pending.find(name)
+	if i < 0 {
+		return fmt.Sprintf("Error: There is no pending request named '%s'.", name)
+	}
+	request := pending.Requests[i]
+	csr, err := loadCSR(request.CSRFile)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate request: %v", err)
+	}
+
+	found, err := decodeImport(certData, nil, "")
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	bundle := &importBundle{pendingKeyFile: request.KeyFile}
+	for _, cert := range append([]*x509.Certificate{found.cert}, found.chain...) {
+		if bundle.cert == nil && publicKeysEqual(csr.PublicKey, cert.PublicKey) {
+			bundle.cert = cert
+		} else {
+			bundle.chain = append(bundle.chain, cert)
+		}
+	}
+	if bundle.cert == nil {
+		return fmt.Sprintf("Error: The certificate does not match the key of request '%s'.", name)
+	}
+	if existing := inventoryRecordFor(bundle.cert); existing != nil {
+		return fmt.Sprintf("Error: This certificate is already in the store as '%s'.", existing.Name)
+	}
+	if err := importChain(bundle.chain); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	var result string
+	if bundle.cert.IsCA {
+		caName, err := importCAName("", bundle.cert)
+		if err == nil {
+			err = importCA(caName, bundle, "", storeCAs())
+		}
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		result = fmt.Sprintf("Success! CA '%s' added; it can now issue certificates.", caName)
+	} else {
+		certFileName, err := importCert(bundle, "", storeCAs())
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		result = fmt.Sprintf("Success! Certificate filed as '%s' with its private key.", certFileName)
+	}
+
+	os.Remove(filepath.Join(outputDir, request.CSRFile))
+	pending.Requests = append(pending.Requests[:i], pending.Requests[i+1:]...)
+	if err := writeJSON(pendingFile, pending); err != nil {
+		log.Printf("Could not save pending requests: %v", err)
+	}
+	return result
+}
+
+// DeletePendingRequest abandons a pending request, deleting its key and CSR.
+func (a *App) DeletePendingRequest(name string) string {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending, err := loadPending()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	i := pending.find(name)
+	if i < 0 {
+		return fmt.Sprintf("Error: There is no pending request named '%s'.", name)
+	}
+	request := pending.Requests[i]
+	lockKey(request.KeyFile)
+	os.Remove(filepath.Join(outputDir, request.KeyFile))
+	os.Remove(filepath.Join(outputDir, request.CSRFile))
+	pending.Requests = append(pending.Requests[:i], pending.Requests[i+1:]...)
+	if err := writeJSON(pendingFile, pending); err != nil {
+		return fmt.Sprintf("Error: could not save pending requests: %v", err)
+	}
+	return fmt.Sprintf("Success! Pending request '%s' and its key have been deleted.", name)
+}
+
+func loadCSR(fileName string) (*x509.CertificateRequest, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, fileName))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || !strings.HasSuffix(block.Type, "CERTIFICATE REQUEST") {
+		return nil, fmt.Errorf("could not decode PEM block from '%s'", fileName)
+	}
+	return x509.ParseCertificateRequest(block.Bytes)
+}
+
+// caRequestExtensions returns the Basic Constraints and Key Usage extensions
+// that ask the external CA for a CA certificate.
+func caRequestExtensions(pathLen int) ([]pkix.Extension, error) {
+	type basicConstraints struct {
+		IsCA       bool `asn1:"optional"`
+		MaxPathLen int  `asn1:"optional,default:-1"`
+	}
+	if pathLen < 0 {
+		pathLen = -1
+	}
+	bc, err := asn1.Marshal(basicConstraints{IsCA: true, MaxPathLen: pathLen})
+	if err != nil {
+		return nil, err
+	}
+	// digitalSignature (0), keyCertSign (5) and cRLSign (6)
+	ku, err := asn1.Marshal(asn1.BitString{Bytes: []byte{0x86}, BitLength: 7})
+	if err != nil {
+		return nil, err
+	}
+	return []pkix.Extension{
+		{Id: oidBasicConstraints, Critical: true, Value: bc},
+		{Id: oidKeyUsage, Critical: true, Value: ku},
+	}, nil
+}
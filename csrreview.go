@@ -0,0 +1,577 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// keyUsageOrder lists the key usage names by bit, for display.
+var keyUsageOrder = []string{
+	"digitalSignature", "contentCommitment", "keyEncipherment", "dataEncipherment",
+	"keyAgreement", "keyCertSign", "cRLSign", "encipherOnly", "decipherOnly",
+}
+
+// extensionNames names the extensions commonly found in certificates and CSRs.
+var extensionNames = map[string]string{
+	"2.5.29.14":               "Subject Key Identifier",
+	"2.5.29.15":               "Key Usage",
+	"2.5.29.17":               "Subject Alternative Name",
+	"2.5.29.18":               "Issuer Alternative Name",
+	"2.5.29.19":               "Basic Constraints",
+	"2.5.29.30":               "Name Constraints",
+	"2.5.29.31":               "CRL Distribution Points",
+	"2.5.29.32":               "Certificate Policies",
+	"2.5.29.35":               "Authority Key Identifier",
+	"2.5.29.36":               "Policy Constraints",
+	"2.5.29.37":               "Extended Key Usage",
+	"2.5.29.54":               "Inhibit anyPolicy",
+	"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+	"1.3.6.1.5.5.7.1.24":      "TLS Feature (OCSP Must-Staple)",
+	"1.3.6.1.5.5.7.48.1.5":    "OCSP No Check",
+	"1.2.840.113549.1.9.15":   "S/MIME Capabilities",
+	"1.3.6.1.4.1.311.20.2":    "Microsoft Certificate Template Name",
+	"1.3.6.1.4.1.311.21.7":    "Microsoft Certificate Template",
+	"1.3.6.1.4.1.311.21.10":   "Microsoft Application Policies",
+	"2.16.840.1.113730.1.1":   "Netscape Certificate Type",
+	"1.3.6.1.4.1.11129.2.4.2": "CT Precertificate SCTs",
+	"1.3.6.1.4.1.11129.2.4.3": "CT Precertificate Poison",
+}
+
+// CertExtension is an extension of a certificate, or one requested in a CSR.
+type CertExtension struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name"` // Empty when unknown
+	Critical bool   `json:"critical"`
+	Value    string `json:"value"` // Decoded for the extensions the signer can honour, else hex
+}
+
+// CSRDetails is the content of a CSR, for review before it is signed.
+type CSRDetails struct {
+	Subject            string          `json:"subject"`
+	CommonName         string          `json:"commonName"`
+	SubjectFields      SubjectInput    `json:"subjectFields"` // The subject split into fields, to start an override from
+	SANs               []string        `json:"sans"`          // Typed, e.g. "DNS:host.example.com"
+	KeyAlgorithm       string          `json:"keyAlgorithm"`
+	KeyBits            int             `json:"keyBits"`
+	SignatureAlgorithm string          `json:"signatureAlgorithm"`
+	SignatureValid     bool            `json:"signatureValid"`
+	KeyUsage           []string        `json:"keyUsage"`         // Requested Key Usage, if any
+	ExtKeyUsage        []string        `json:"extKeyUsage"`      // Requested Extended Key Usage, if any
+	BasicConstraints   bool            `json:"basicConstraints"` // Basic Constraints were requested
+	IsCA               bool            `json:"isCA"`
+	PathLen            int             `json:"pathLen"` // -1 when not limited
+	Extensions         []CertExtension `json:"extensions"`
+	HasKey             bool            `json:"hasKey"` // A private key was pasted with the CSR
+	Warnings           []string        `json:"warnings"`
+}
+
+// CSRSignInput is a CSR with the edits to apply when signing it. With no
+// edits the certificate gets the CSR's subject and names, and the profile's
+// key usages and basic constraints.
+type CSRSignInput struct {
+	CSR                    string       `json:"csr"` // Pasted PEM, optionally followed by the private key
+	CAName                 string       `json:"caName"`
+	ExpiryDays             int          `json:"expiryDays"`      // 0 uses the profile's validity, or the CA profile's for a CA
+	Profile                string       `json:"profile"`         // Empty for "tls-server-client"
+	KeyPassphrase          string       `json:"keyPassphrase"`   // Opens an encrypted pasted key, and encrypts the saved key when set
+	OverrideSubject        bool         `json:"overrideSubject"` // Use CommonName and Subject instead of the CSR's subject
+	CommonName             string       `json:"commonName"`
+	Subject                SubjectInput `json:"subject"`
+	RemoveSANs             []string     `json:"removeSans"` // Entries from CSRDetails.SANs to leave out
+	AddSANs                string       `json:"addSans"`    // Comma-separated, as for new certificates
+	HonourKeyUsage         bool         `json:"honourKeyUsage"`
+	HonourExtKeyUsage      bool         `json:"honourExtKeyUsage"`
+	HonourBasicConstraints bool         `json:"honourBasicConstraints"` // Issues a CA certificate when the CSR asks for one
+}
+
+// csrRequest is what the CSR asks for in the extensions the signer can honour.
+type csrRequest struct {
+	keyUsage    x509.KeyUsage
+	hasKeyUsage bool
+	extKeyUsage *pkix.Extension
+	hasBC       bool
+	isCA        bool
+	pathLen     int
+}
+
+// ReviewCSR decodes a pasted CSR and reports everything it asks for, with
+// warnings about anything that should be fixed before or while signing it.
+func (a *App) ReviewCSR(pastedText string) (*CSRDetails, error) {
+	csr, keyBlock, err := parsePastedCSR(pastedText)
+	if err != nil {
+		return nil, err
+	}
+	return csrDetails(csr, keyBlock != nil), nil
+}
+
+// csrDetails describes a CSR; hasKey records that its private key came with it.
+func csrDetails(csr *x509.CertificateRequest, hasKey bool) *CSRDetails {
+	sans := csrSANs(csr)
+	request := parseCSRRequest(csr)
+	details := &CSRDetails{
+		Subject:            csr.Subject.String(),
+		CommonName:         csr.Subject.CommonName,
+		SubjectFields:      subjectInputOf(csr.Subject),
+		SANs:               sans.list(),
+		KeyAlgorithm:       describePublicKey(csr.PublicKey),
+		KeyBits:            publicKeyBits(csr.PublicKey),
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		SignatureValid:     csr.CheckSignature() == nil,
+		BasicConstraints:   request.hasBC,
+		IsCA:               request.isCA,
+		PathLen:            request.pathLen,
+		HasKey:             hasKey,
+		Extensions:         []CertExtension{},
+	}
+	if request.hasKeyUsage {
+		for bit, name := range keyUsageOrder {
+			if request.keyUsage&(1<<bit) != 0 {
+				details.KeyUsage = append(details.KeyUsage, name)
+			}
+		}
+	}
+	for _, oid := range extKeyUsageOIDs(csr.Extensions) {
+		details.ExtKeyUsage = append(details.ExtKeyUsage, extKeyUsageName(oid))
+	}
+	for _, ext := range csr.Extensions {
+		details.Extensions = append(details.Extensions, describeCSRExtension(ext, details))
+	}
+	details.Warnings = lintCSR(csr, sans, request, details)
+	return details
+}
+
+// SignReviewedCSR signs a CSR with the operator's edits from the review
+// applied, and saves the private key if one was pasted with it.
+func (a *App) SignReviewedCSR(input CSRSignInput) string {
+	caName := input.CAName
+	if caName == "" {
+		return "Error: You must select a CA to sign the request with."
+	}
+	profile, err := leafProfile(input.Profile)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if input.CSR == "" {
+		return "Error: Pasted text cannot be empty."
+	}
+	if input.KeyPassphrase != "" && len(input.KeyPassphrase) < minPassphraseLength {
+		return fmt.Sprintf("Error: The key passphrase must be at least %d characters.", minPassphraseLength)
+	}
+
+	csr, keyBlock, err := parsePastedCSR(input.CSR)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Sprintf("Error verifying CSR signature: %v", err)
+	}
+	// A pasted key is checked before signing, so a wrong paste never yields an unusable pair
+	var pastedKey crypto.Signer
+	if keyBlock != nil {
+		if pastedKey, err = decodeKeyBlock(keyBlock, input.KeyPassphrase); err != nil {
+			return fmt.Sprintf("Error reading the pasted private key: %v", err)
+		}
+		if !publicKeysEqual(pastedKey.Public(), csr.PublicKey) {
+			return "Error: The pasted private key does not match the CSR."
+		}
+	}
+	request := parseCSRRequest(csr)
+
+	cn, rawSubject := csr.Subject.CommonName, csr.RawSubject
+	if input.OverrideSubject {
+		cn = strings.TrimSpace(input.CommonName)
+		subject := input.Subject.clean()
+		if err := subject.validate(); err != nil {
+			return fmt.Sprintf("Error: Invalid subject: %v", err)
+		}
+		if rawSubject, err = subjectDN(cn, subject); err != nil {
+			return fmt.Sprintf("Error encoding subject: %v", err)
+		}
+	}
+	sans := csrSANs(csr)
+	for _, entry := range input.RemoveSANs {
+		if err := sans.remove(entry); err != nil {
+			return fmt.Sprintf("Error: Invalid SAN: %v", err)
+		}
+	}
+	for _, entry := range strings.Split(input.AddSANs, ",") {
+		if err := sans.add(strings.TrimSpace(entry)); err != nil {
+			return fmt.Sprintf("Error: Invalid SAN: %v", err)
+		}
+	}
+	issueCA := input.HonourBasicConstraints && request.isCA
+	caFileName := ""
+	if issueCA {
+		caFileName = safeFileName(cn)
+		if caFileName == "" {
+			return "Error: A CA certificate needs a Common Name."
+		}
+		if _, exists := storeCAs()[caFileName]; exists || fileExists(filepath.Join(outputDir, caFileName+".pem")) || fileExists(filepath.Join(outputDir, caFileName+".key")) {
+			return fmt.Sprintf("Error: A CA with the name '%s' already exists.", caFileName)
+		}
+	}
+	// The policy's allowed profiles name leaf profiles, so it is checked
+	// against the chosen one even when the CSR is issued as a CA
+	profileName := profile.Name
+	if issueCA {
+		// An intermediate takes the CA profile's usages and validity; the leaf
+		// profile's Extended Key Usage would restrict what it may issue
+		if profile, err = findProfile("", defaultCAProfile); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+	}
+
+	caCert, caPrivateKey, err := loadCA(caName)
+	if err != nil {
+		return fmt.Sprintf("Error loading CA: %v", err)
+	}
+	policy := loadCASettings(caName).Policy
+	notAfter, err := policy.leafNotAfter(caCert, profile, input.ExpiryDays)
+	if err != nil {
+		return fmt.Sprintf("Error: Refused by the policy of CA '%s': %v", caName, err)
+	}
+
+	serial, err := newSerialNumber(caName)
+	if err != nil {
+		return fmt.Sprintf("Error generating serial number: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		RawSubject:   rawSubject,
+		NotBefore:    time.Now(),
+		NotAfter:     notAfter,
+	}
+	if err := sans.apply(template); err != nil {
+		return fmt.Sprintf("Error encoding SANs: %v", err)
+	}
+	if err := profile.apply(template, csr.PublicKey); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := request.honour(template, caCert, input); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := policy.check(cn, template, csr.PublicKey, profileName); err != nil {
+		return fmt.Sprintf("Error: Refused by the policy of CA '%s': %v", caName, err)
+	}
+	if err := checkNameConstraints(caName, cn, template); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := checkIssuerEKU(caCert, template); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	applyRevocationURLs(template, caName)
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caPrivateKey)
+	if err != nil {
+		return fmt.Sprintf("Error signing certificate from CSR: %v", err)
+	}
+
+	if cn == "" {
+		cn = "signed_cert" // Fallback filename
+	}
+	kind, baseName := kindCert, uniqueCertFileBase(cn, caName, template.SerialNumber.Text(16))
+	if issueCA {
+		kind, baseName = kindCA, caFileName
+	}
+
+	// Save the signed certificate
+	certFile := baseName + ".pem"
+	if err := writeCertPEM(certFile, certBytes); err != nil {
+		return fmt.Sprintf("Error saving signed certificate: %v", err)
+	}
+
+	// If a private key was also pasted, save it with a matching name
+	keyFile := ""
+	var keyErr error
+	if pastedKey != nil {
+		keyFile = baseName + ".key"
+		if err := writeKeyPEM(keyFile, pastedKey, input.KeyPassphrase); err != nil {
+			keyFile, keyErr = "", err
+		}
+	}
+	name := certFile
+	if issueCA {
+		name = caFileName
+	}
+	if err := recordIssued(kind, name, certBytes, caName, certFile, keyFile); err != nil {
+		return fmt.Sprintf("Error: Certificate '%s' was signed but could not be added to the inventory: %v", certFile, err)
+	}
+
+	if issueCA {
+		cn = fmt.Sprintf("intermediate CA '%s'", caFileName)
+	}
+	if keyErr != nil {
+		return fmt.Sprintf("Success! Certificate for %s signed. However, failed to save private key: %v", cn, keyErr)
+	}
+	if keyFile != "" {
+		return fmt.Sprintf("Success! Certificate for %s signed and private key was saved.", cn)
+	}
+	return fmt.Sprintf("Success! Certificate for %s signed and created.", cn)
+}
+
+// parsePastedCSR finds the CSR, and any private key, in pasted PEM text.
+func parsePastedCSR(pastedText string) (*x509.CertificateRequest, *pem.Block, error) {
+	var csrBlock, keyBlock *pem.Block
+	rest := []byte(pastedText)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if strings.HasSuffix(block.Type, "CERTIFICATE REQUEST") {
+			csrBlock = block
+		} else if strings.Contains(block.Type, "PRIVATE KEY") {
+			keyBlock = block
+		}
+	}
+	if csrBlock == nil {
+		return nil, nil, fmt.Errorf("no valid CSR found in the pasted text")
+	}
+	csr, err := x509.ParseCertificateRequest(csrBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse CSR: %w", err)
+	}
+	return csr, keyBlock, nil
+}
+
+// csrSANs returns every name a CSR asks for, including the UPNs that
+// crypto/x509 does not parse.
+func csrSANs(csr *x509.CertificateRequest) *subjectAltNames {
+	return &subjectAltNames{
+		dnsNames: csr.DNSNames,
+		ips:      csr.IPAddresses,
+		emails:   csr.EmailAddresses,
+		uris:     csr.URIs,
+		upns:     upnsIn(csr.Extensions),
+	}
+}
+
+// parseCSRRequest decodes the Key Usage, Extended Key Usage and Basic
+// Constraints a CSR asks for; crypto/x509 only parses them in certificates.
+func parseCSRRequest(csr *x509.CertificateRequest) csrRequest {
+	request := csrRequest{pathLen: -1}
+	for i, ext := range csr.Extensions {
+		switch {
+		case ext.Id.Equal(oidKeyUsage):
+			var bits asn1.BitString
+			if _, err := asn1.Unmarshal(ext.Value, &bits); err == nil {
+				request.hasKeyUsage = true
+				for bit := range keyUsageOrder {
+					if bits.At(bit) != 0 {
+						request.keyUsage |= 1 << bit
+					}
+				}
+			}
+		case ext.Id.Equal(oidExtKeyUsage):
+			request.extKeyUsage = &csr.Extensions[i]
+		case ext.Id.Equal(oidBasicConstraints):
+			var bc struct {
+				IsCA       bool `asn1:"optional"`
+				MaxPathLen int  `asn1:"optional,default:-1"`
+			}
+			if _, err := asn1.Unmarshal(ext.Value, &bc); err == nil {
+				request.hasBC, request.isCA, request.pathLen = true, bc.IsCA, bc.MaxPathLen
+			}
+		}
+	}
+	return request
+}
+
+// honour replaces the profile's key usages and basic constraints on template
+// with the ones the CSR asks for, where the operator chose to.
+func (r csrRequest) honour(template *x509.Certificate, caCert *x509.Certificate, input CSRSignInput) error {
+	if input.HonourKeyUsage && r.hasKeyUsage {
+		if r.keyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 && !(input.HonourBasicConstraints && r.isCA) {
+			return fmt.Errorf("the CSR asks for keyCertSign or cRLSign, which only a CA certificate may have")
+		}
+		template.KeyUsage = r.keyUsage
+	}
+	if input.HonourExtKeyUsage && r.extKeyUsage != nil {
+		template.ExtKeyUsage, template.UnknownExtKeyUsage = nil, nil
+		var extensions []pkix.Extension
+		for _, ext := range template.ExtraExtensions {
+			if !ext.Id.Equal(oidExtKeyUsage) {
+				extensions = append(extensions, ext)
+			}
+		}
+		template.ExtraExtensions = append(extensions, *r.extKeyUsage)
+	}
+	if input.HonourBasicConstraints && r.hasBC {
+		template.BasicConstraintsValid = true
+		template.IsCA = r.isCA
+		template.MaxPathLen, template.MaxPathLenZero = 0, false
+		if r.isCA {
+			pathLen, err := childPathLen(caCert, r.pathLen)
+			if err != nil {
+				return err
+			}
+			if pathLen >= 0 {
+				template.MaxPathLen = pathLen
+				template.MaxPathLenZero = pathLen == 0
+			}
+		}
+	}
+	return nil
+}
+
+// lintCSR returns warnings about a CSR that the operator should look at.
+func lintCSR(csr *x509.CertificateRequest, sans *subjectAltNames, request csrRequest, details *CSRDetails) []string {
+	warnings := []string{}
+	if !details.SignatureValid {
+		warnings = append(warnings, "The CSR signature does not verify; it may have been altered, and cannot be signed.")
+	}
+	switch k := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			warnings = append(warnings, fmt.Sprintf("The RSA key is only %d bits; use at least 2048.", k.N.BitLen()))
+		}
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		warnings = append(warnings, fmt.Sprintf("The key type %s is not supported by most clients.", details.KeyAlgorithm))
+	}
+	switch csr.SignatureAlgorithm {
+	case x509.MD5WithRSA, x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1:
+		warnings = append(warnings, fmt.Sprintf("The CSR is signed with %s, which is no longer considered secure.", csr.SignatureAlgorithm))
+	}
+	if len(details.SANs) == 0 {
+		warnings = append(warnings, "The CSR has no Subject Alternative Names; clients ignore the Common Name, so add the names when signing.")
+	} else if cn := csr.Subject.CommonName; cn != "" {
+		dnsNames, ips := certNames(cn, &x509.Certificate{})
+		missing := false
+		for _, name := range dnsNames {
+			missing = missing || !containsFold(sans.dnsNames, name)
+		}
+		for _, ip := range ips {
+			found := false
+			for _, existing := range sans.ips {
+				found = found || existing.Equal(ip)
+			}
+			missing = missing || !found
+		}
+		if missing {
+			warnings = append(warnings, fmt.Sprintf("The Common Name '%s' is not among the Subject Alternative Names.", cn))
+		}
+	}
+	for _, name := range sans.dnsNames {
+		if normalized, err := normalizeHostname(name); err != nil || normalized != name {
+			warnings = append(warnings, fmt.Sprintf("'%s' is not a valid DNS name.", name))
+		} else if strings.HasPrefix(name, "*.") {
+			warnings = append(warnings, fmt.Sprintf("The CSR asks for the wildcard name '%s'.", name))
+		}
+	}
+	for _, ip := range sans.ips {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			warnings = append(warnings, fmt.Sprintf("The IP address %s is not publicly routable.", ip))
+		}
+	}
+	if request.isCA {
+		warnings = append(warnings, "The CSR asks for a CA certificate; it is only issued as a CA if you honour its basic constraints.")
+	}
+	if request.keyUsage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 && !request.isCA {
+		warnings = append(warnings, "The CSR asks for keyCertSign or cRLSign without asking to be a CA.")
+	}
+	for _, ext := range details.Extensions {
+		if ext.Critical && ext.Name == "" {
+			warnings = append(warnings, fmt.Sprintf("The CSR asks for the unknown critical extension %s, which will not be copied.", ext.OID))
+		}
+	}
+	return warnings
+}
+
+// describeCSRExtension summarises a requested extension.
+func describeCSRExtension(ext pkix.Extension, details *CSRDetails) CertExtension {
+	described := CertExtension{OID: ext.Id.String(), Name: extensionNames[ext.Id.String()], Critical: ext.Critical}
+	switch {
+	case ext.Id.Equal(oidSubjectAltName):
+		described.Value = strings.Join(details.SANs, ", ")
+	case ext.Id.Equal(oidKeyUsage):
+		described.Value = strings.Join(details.KeyUsage, ", ")
+	case ext.Id.Equal(oidExtKeyUsage):
+		described.Value = strings.Join(details.ExtKeyUsage, ", ")
+	case ext.Id.Equal(oidBasicConstraints):
+		described.Value = "CA:FALSE"
+		if details.IsCA {
+			described.Value = "CA:TRUE"
+			if details.PathLen >= 0 {
+				described.Value += fmt.Sprintf(", pathlen:%d", details.PathLen)
+			}
+		}
+	default:
+		value := ext.Value
+		if len(value) > 64 {
+			value = value[:64]
+		}
+		described.Value = hex.EncodeToString(value)
+		if len(value) < len(ext.Value) {
+			described.Value += "..."
+		}
+	}
+	return described
+}
+
+// extKeyUsageName returns the name of an extended key usage, or its dotted OID.
+func extKeyUsageName(oid asn1.ObjectIdentifier) string {
+	for name, known := range extKeyUsageNames {
+		if known.Equal(oid) {
+			return name
+		}
+	}
+	return oid.String()
+}
+
+// publicKeyBits returns the size of a public key: the modulus for RSA and the
+// curve for ECDSA and Ed25519.
+func publicKeyBits(pub interface{}) int {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
+
+// subjectInputOf splits a subject into the fields of SubjectInput.
+func subjectInputOf(name pkix.Name) SubjectInput {
+	first := func(values []string) string {
+		if len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	s := SubjectInput{
+		Country:      first(name.Country),
+		State:        first(name.Province),
+		Locality:     first(name.Locality),
+		Org:          first(name.Organization),
+		OrgUnits:     name.OrganizationalUnit,
+		SerialNumber: name.SerialNumber,
+	}
+	for _, atv := range name.Names {
+		value, _ := atv.Value.(string)
+		switch {
+		case atv.Type.Equal(oidEmailAddress):
+			s.Email = value
+		case atv.Type.Equal(oidDomainComponent):
+			// Encoded most significant first; SubjectInput keeps it last
+			s.DomainComponents = append([]string{value}, s.DomainComponents...)
+		}
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignReviewedCSRPastedKey checks that a key pasted with a CSR is
+// refused before signing when it does not match or cannot be opened.
+func TestSignReviewedCSRPastedKey(t *testing.T) {
+	t.Chdir(t.TempDir())
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "csr.example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	otherDER, err := x509.MarshalPKCS8PrivateKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedDER, err := encryptPrivateKey(key, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		keyBlock   *pem.Block
+		passphrase string
+		want       string
+	}{
+		{"mismatched key", &pem.Block{Type: "PRIVATE KEY", Bytes: otherDER}, "", "does not match the CSR"},
+		{"encrypted key without passphrase", &pem.Block{Type: encryptedKeyPEMType, Bytes: encryptedDER}, "", "enter its password"},
+		{"encrypted key with wrong passphrase", &pem.Block{Type: encryptedKeyPEMType, Bytes: encryptedDER}, "passphrasf", "password is incorrect"},
+	}
+	for _, test := range tests {
+		result := (&App{}).SignReviewedCSR(CSRSignInput{
+			CSR:           csrPEM + string(pem.EncodeToMemory(test.keyBlock)),
+			CAName:        "missing",
+			KeyPassphrase: test.passphrase,
+		})
+		if !strings.Contains(result, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, result, test.want)
+		}
+	}
+}
+
+// TestSignReviewedCSRForCA checks that a CSR for a CA, with its Basic
+// Constraints honoured, is issued with the CA profile rather than the leaf
+// profile: no Extended Key Usage, and the CA validity.
+func TestSignReviewedCSRForCA(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root, rootKey := testIssue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(5, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	testStoreCA(t, "root", root, "")
+	if err := writeKeyPEM("root.key", rootKey, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := asn1.Marshal(struct{ IsCA bool }{true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:         pkix.Name{CommonName: "Issuing CA"},
+		ExtraExtensions: []pkix.Extension{{Id: oidBasicConstraints, Critical: true, Value: bc}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := (&App{}).SignReviewedCSR(CSRSignInput{
+		CSR:                    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})),
+		CAName:                 "root",
+		HonourBasicConstraints: true,
+	})
+	if !strings.HasPrefix(result, "Success!") {
+		t.Fatal(result)
+	}
+	ca, err := loadCert("Issuing CA.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.IsCA || ca.KeyUsage&x509.KeyUsageCertSign == 0 {
+		t.Error("the certificate is not a CA")
+	}
+	if len(ca.ExtKeyUsage) > 0 || len(ca.UnknownExtKeyUsage) > 0 {
+		t.Errorf("the CA is restricted to extended key usages %v", ca.ExtKeyUsage)
+	}
+	// The CA profile's ten years are cut to the root's five; a leaf gets two
+	if !ca.NotAfter.Equal(root.NotAfter) {
+		t.Errorf("the CA expires on %s, want the root's expiry %s", ca.NotAfter, root.NotAfter)
+	}
+	codeSigning := &x509.Certificate{}
+	if err := (&CertProfile{ExtKeyUsage: []string{"codeSigning"}}).apply(codeSigning, key.Public()); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkIssuerEKU(ca, codeSigning); err != nil {
+		t.Errorf("the CA cannot issue code signing certificates: %v", err)
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Kinds of object DecodeArtifact finds.
+const (
+	decodedCertificate = "certificate"
+	decodedCSR         = "csr"
+	decodedCRL         = "crl"
+	decodedPrivateKey  = "privateKey"
+	decodedPublicKey   = "publicKey"
+)
+
+// DecodeInput is an artifact to decode: pasted text, a file path, or the
+// base64 of a chosen file.
+type DecodeInput struct {
+	Text     string `json:"text"`     // PEM, or base64 of DER
+	Path     string `json:"path"`     // A file to read
+	FileData string `json:"fileData"` // Base64 of a chosen file
+	Password string `json:"password"` // For PKCS#12 files and encrypted keys
+}
+
+// DecodeResult lists everything found in an artifact.
+type DecodeResult struct {
+	Encoding string          `json:"encoding"` // PEM, DER or base64 DER
+	Objects  []DecodedObject `json:"objects"`
+	Notes    []string        `json:"notes"` // Chain order, undecodable blocks, missing passwords
+}
+
+// DecodedObject is one certificate, CSR, CRL or key found in an artifact.
+// Exactly one of the detail fields is set, according to Kind.
+type DecodedObject struct {
+	Kind        string       `json:"kind"`      // certificate, csr, crl, privateKey or publicKey
+	Source      string       `json:"source"`    // Where it was found, e.g. "PEM block 2" or "PKCS#12"
+	StoreName   string       `json:"storeName"` // The CA or certificate in the store, when already managed
+	Certificate *CertDetails `json:"certificate,omitempty"`
+	CSR         *CSRDetails  `json:"csr,omitempty"`
+	CRL         *CRLDetails  `json:"crl,omitempty"`
+	Key         *KeyDetails  `json:"key,omitempty"`
+}
+
+// CRLDetails is the content of a certificate revocation list.
+type CRLDetails struct {
+	Issuer             string          `json:"issuer"`
+	Number             string          `json:"number"`
+	ThisUpdate         string          `json:"thisUpdate"`
+	NextUpdate         string          `json:"nextUpdate"`
+	Expired            bool            `json:"expired"` // Past its next update
+	SignatureAlgorithm string          `json:"signatureAlgorithm"`
+	IssuerName         string          `json:"issuerName"`     // The CA in the store that signed it, if any
+	SignatureValid     bool            `json:"signatureValid"` // Checked only when IssuerName is set
+	Entries            []CRLEntry      `json:"entries"`
+	Extensions         []CertExtension `json:"extensions"`
+}
+
+// CRLEntry is one revoked certificate in a CRL.
+type CRLEntry struct {
+	Serial    string `json:"serial"` // Hexadecimal
+	RevokedAt string `json:"revokedAt"`
+	Reason    string `json:"reason"`
+}
+
+// KeyDetails describes a key by its parameters; the key material itself is
+// never returned.
+type KeyDetails struct {
+	Format    string   `json:"format"` // e.g. PKCS#8, PKCS#1, SEC1, encrypted PKCS#8, SubjectPublicKeyInfo
+	Encrypted bool     `json:"encrypted"`
+	Locked    bool     `json:"locked"`    // Encrypted and not opened, so only the format is known
+	Algorithm string   `json:"algorithm"` // e.g. "RSA 2048", "ECDSA P-256"
+	Bits      int      `json:"bits"`
+	SPKIPin   string   `json:"spkiPin"` // Base64 SHA-256 of the public key
+	Matches   []string `json:"matches"` // CAs and certificates in the store with this public key
+}
+
+// DecodeArtifact identifies and describes every certificate, chain, CSR,
+// CRL, PKCS#7 bundle, PKCS#12 file and key in pasted text or a file, whether
+// PEM, DER or base64.
+func (a *App) DecodeArtifact(input DecodeInput) (*DecodeResult, error) {
+	var data []byte
+	switch {
+	case input.FileData != "":
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(input.FileData); err != nil {
+			return nil, fmt.Errorf("could not read the chosen file")
+		}
+	case strings.TrimSpace(input.Path) != "":
+		// Windows' "Copy as path" wraps the path in quotes
+		path := strings.Trim(strings.TrimSpace(input.Path), `"`)
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("could not read '%s': %w", path, err)
+		}
+	default:
+		data = []byte(input.Text)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("paste something to decode, or choose a file")
+	}
+
+	d := &artifactDecoder{password: input.Password, result: &DecodeResult{Objects: []DecodedObject{}, Notes: []string{}}}
+	switch {
+	case bytes.Contains(data, []byte("-----BEGIN")):
+		d.result.Encoding = "PEM"
+		d.decodePEM(data)
+	default:
+		der := data
+		d.result.Encoding = "DER"
+		compact := strings.Join(strings.Fields(string(data)), "")
+		if decoded, err := base64.StdEncoding.DecodeString(compact); err == nil && len(decoded) > 0 {
+			der = decoded
+			d.result.Encoding = "base64 DER"
+		}
+		if err := d.decodeDER(der, d.result.Encoding); err != nil {
+			return nil, err
+		}
+	}
+	if len(d.result.Objects) == 0 && len(d.result.Notes) == 0 {
+		return nil, fmt.Errorf("nothing recognisable was found")
+	}
+	d.noteChainOrder()
+	return d.result, nil
+}
+
+// artifactDecoder collects what DecodeArtifact finds.
+type artifactDecoder struct {
+	password string
+	result   *DecodeResult
+	certs    []*x509.Certificate // In the order found, to check chain order
+}
+
+func (d *artifactDecoder) note(format string, args ...interface{}) {
+	d.result.Notes = append(d.result.Notes, fmt.Sprintf(format, args...))
+}
+
+// decodePEM decodes each PEM block by its type, falling back to sniffing its
+// content for types it does not know.
+func (d *artifactDecoder) decodePEM(data []byte) {
+	n := 0
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		n++
+		source := fmt.Sprintf("PEM block %d (%s)", n, block.Type)
+		var err error
+		switch block.Type {
+		case "CERTIFICATE", "X509 CERTIFICATE", "TRUSTED CERTIFICATE":
+			err = d.addCertificates(block.Bytes, source)
+		case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+			err = d.addCSR(block.Bytes, source)
+		case "X509 CRL":
+			err = d.addCRL(block.Bytes, source)
+		case "PKCS7":
+			err = d.addPKCS7(block.Bytes, source)
+		case "PUBLIC KEY", "RSA PUBLIC KEY":
+			err = d.addPublicKey(block.Bytes, source)
+		case "EC PARAMETERS":
+			continue
+		default:
+			if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+				d.addPrivateKeyBlock(block, source)
+				continue
+			}
+			err = d.decodeDER(block.Bytes, source)
+		}
+		if err != nil {
+			d.note("%s: %v", source, err)
+		}
+	}
+	if n == 0 {
+		d.note("The text looks like PEM but no complete block was found.")
+	}
+}
+
+// decodeDER works out what a DER object is by trying each parser in turn.
+func (d *artifactDecoder) decodeDER(der []byte, source string) error {
+	if _, err := x509.ParseCertificates(der); err == nil {
+		return d.addCertificates(der, source)
+	}
+	if _, err := x509.ParseCertificateRequest(der); err == nil {
+		return d.addCSR(der, source)
+	}
+	if _, err := x509.ParseRevocationList(der); err == nil {
+		return d.addCRL(der, source)
+	}
+	if _, err := pkcs7Certs(der); err == nil {
+		return d.addPKCS7(der, source)
+	}
+	if key, err := parsePrivateKey(der); err == nil {
+		d.addKey(decodedPrivateKey, key.Public(), privateKeyFormat(der), source)
+		return nil
+	}
+	if err := d.addPublicKey(der, source); err == nil {
+		return nil
+	}
+	if looksLikePKCS12(der) {
+		return d.addPKCS12(der, source)
+	}
+	if looksLikeEncryptedKey(der) {
+		d.addPrivateKeyBlock(&pem.Block{Type: encryptedKeyPEMType, Bytes: der}, source)
+		return nil
+	}
+	return fmt.Errorf("not a certificate, CSR, CRL, PKCS#7, PKCS#12 or key")
+}
+
+func (d *artifactDecoder) addCertificates(der []byte, source string) error {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil {
+		return fmt.Errorf("could not parse certificate: %w", err)
+	}
+	for _, cert := range certs {
+		d.addCertificate(cert, source)
+	}
+	return nil
+}
+
+func (d *artifactDecoder) addCertificate(cert *x509.Certificate, source string) {
+	object := DecodedObject{Kind: decodedCertificate, Source: source, Certificate: certDetails(cert)}
+	if record := inventoryRecordFor(cert); record != nil {
+		object.StoreName = record.Name
+	}
+	d.result.Objects = append(d.result.Objects, object)
+	d.certs = append(d.certs, cert)
+}
+
+func (d *artifactDecoder) addCSR(der []byte, source string) error {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return fmt.Errorf("could not parse CSR: %w", err)
+	}
+	d.result.Objects = append(d.result.Objects, DecodedObject{Kind: decodedCSR, Source: source, CSR: csrDetails(csr, false)})
+	return nil
+}
+
+func (d *artifactDecoder) addCRL(der []byte, source string) error {
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return fmt.Errorf("could not parse CRL: %w", err)
+	}
+	details := &CRLDetails{
+		Issuer:             crl.Issuer.String(),
+		ThisUpdate:         crl.ThisUpdate.Format(time.RFC1123),
+		SignatureAlgorithm: crl.SignatureAlgorithm.String(),
+		Entries:            []CRLEntry{},
+		Extensions:         []CertExtension{},
+	}
+	if crl.Number != nil {
+		details.Number = crl.Number.String()
+	}
+	if !crl.NextUpdate.IsZero() {
+		details.NextUpdate = crl.NextUpdate.Format(time.RFC1123)
+		details.Expired = time.Now().After(crl.NextUpdate)
+	}
+	for name, ca := range storeCAs() {
+		if bytes.Equal(ca.cert.RawSubject, crl.RawIssuer) && crl.CheckSignatureFrom(ca.cert) == nil {
+			details.IssuerName = name
+			details.SignatureValid = true
+			break
+		}
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		details.Entries = append(details.Entries, CRLEntry{
+			Serial:    entry.SerialNumber.Text(16),
+			RevokedAt: entry.RevocationTime.Format(time.RFC1123),
+			Reason:    revocationReasonName(entry.ReasonCode),
+		})
+	}
+	for _, ext := range crl.Extensions {
+		details.Extensions = append(details.Extensions, CertExtension{
+			OID:      ext.Id.String(),
+			Name:     extensionNames[ext.Id.String()],
+			Critical: ext.Critical,
+			Value:    hex.EncodeToString(ext.Value),
+		})
+	}
+	d.result.Objects = append(d.result.Objects, DecodedObject{Kind: decodedCRL, Source: source, CRL: details})
+	return nil
+}
+
+func (d *artifactDecoder) addPKCS7(der []byte, source string) error {
+	certs, err := pkcs7Certs(der)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		d.note("%s: the PKCS#7 bundle holds no certificates.", source)
+	}
+	for _, cert := range certs {
+		d.addCertificate(cert, source+", PKCS#7")
+	}
+	return nil
+}
+
+// addPKCS12 opens a PKCS#12 file with the password, as a key with its
+// chain or, failing that, as a truststore.
+func (d *artifactDecoder) addPKCS12(der []byte, source string) error {
+	key, leaf, chain, err := pkcs12.DecodeChain(der, d.password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		if d.password == "" {
+			return fmt.Errorf("this is a PKCS#12 (PFX) file; enter its password to open it")
+		}
+		return fmt.Errorf("this is a PKCS#12 (PFX) file, but the password is incorrect")
+	}
+	if err != nil {
+		certs, trustErr := pkcs12.DecodeTrustStore(der, d.password)
+		if trustErr != nil {
+			return fmt.Errorf("could not open PKCS#12 file: %w", err)
+		}
+		for _, cert := range certs {
+			d.addCertificate(cert, source+", PKCS#12 truststore")
+		}
+		return nil
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		d.addKey(decodedPrivateKey, signer.Public(), "PKCS#12", source+", PKCS#12")
+		d.result.Objects[len(d.result.Objects)-1].Key.Encrypted = true
+	}
+	for _, cert := range append([]*x509.Certificate{leaf}, chain...) {
+		d.addCertificate(cert, source+", PKCS#12")
+	}
+	return nil
+}
+
+// addPrivateKeyBlock reports a private key, opening it with the password
+// when it is encrypted.
+func (d *artifactDecoder) addPrivateKeyBlock(block *pem.Block, source string) {
+	encrypted := block.Type == encryptedKeyPEMType || x509.IsEncryptedPEMBlock(block)
+	format := privateKeyFormat(block.Bytes)
+	if block.Type == encryptedKeyPEMType {
+		format = "encrypted PKCS#8"
+	} else if encrypted {
+		format = "legacy encrypted PEM (" + block.Type + ")"
+	}
+	if encrypted && d.password == "" {
+		d.result.Objects = append(d.result.Objects, DecodedObject{
+			Kind:   decodedPrivateKey,
+			Source: source,
+			Key:    &KeyDetails{Format: format, Encrypted: true, Locked: true, Matches: []string{}},
+		})
+		d.note("%s: the private key is encrypted; enter its password to see its parameters.", source)
+		return
+	}
+	key, err := decodeKeyBlock(block, d.password)
+	if err != nil {
+		d.result.Objects = append(d.result.Objects, DecodedObject{
+			Kind:   decodedPrivateKey,
+			Source: source,
+			Key:    &KeyDetails{Format: format, Encrypted: encrypted, Locked: true, Matches: []string{}},
+		})
+		d.note("%s: %v", source, err)
+		return
+	}
+	d.addKey(decodedPrivateKey, key.Public(), format, source)
+	d.result.Objects[len(d.result.Objects)-1].Key.Encrypted = encrypted
+}
+
+func (d *artifactDecoder) addPublicKey(der []byte, source string) error {
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		d.addKey(decodedPublicKey, pub, "SubjectPublicKeyInfo", source)
+		return nil
+	}
+	pub, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return fmt.Errorf("could not parse public key: %w", err)
+	}
+	d.addKey(decodedPublicKey, pub, "PKCS#1", source)
+	return nil
+}
+
+// addKey reports a key's parameters and the managed certificates that
+// carry its public key.
+func (d *artifactDecoder) addKey(kind string, pub crypto.PublicKey, format, source string) {
+	details := &KeyDetails{Format: format, Algorithm: describePublicKey(pub), Bits: publicKeyBits(pub), Matches: []string{}}
+	if spki, err := x509.MarshalPKIXPublicKey(pub); err == nil {
+		sum := sha256.Sum256(spki)
+		details.SPKIPin = base64.StdEncoding.EncodeToString(sum[:])
+		fingerprint := hex.EncodeToString(sum[:])
+		for _, record := range append(inventoryRecords(kindCA), inventoryRecords(kindCert)...) {
+			if record.KeyFingerprint == fingerprint {
+				details.Matches = append(details.Matches, record.Name)
+			}
+		}
+	}
+	d.result.Objects = append(d.result.Objects, DecodedObject{Kind: kind, Source: source, Key: details})
+}
+
+// noteChainOrder says whether the certificates found form a chain, each
+// issued by the next.
+func (d *artifactDecoder) noteChainOrder() {
+	if len(d.certs) < 2 {
+		return
+	}
+	for i := 0; i+1 < len(d.certs); i++ {
+		if d.certs[i].CheckSignatureFrom(d.certs[i+1]) != nil {
+			d.note("Certificate %d (%s) was not issued by certificate %d (%s); the certificates are not an ordered chain.",
+				i+1, d.certs[i].Subject.CommonName, i+2, d.certs[i+1].Subject.CommonName)
+			return
+		}
+	}
+	d.note("The %d certificates form an ordered chain, each issued by the next.", len(d.certs))
+}
+
+// pkcs7Certs returns the certificates in a PKCS#7 SignedData, such as a .p7b
+// bundle.
+func pkcs7Certs(der []byte) ([]*x509.Certificate, error) {
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"optional,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+		return nil, fmt.Errorf("not a PKCS#7 structure: %w", err)
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, fmt.Errorf("PKCS#7 content type %s is not SignedData", contentInfo.ContentType)
+	}
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+		CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+		SignerInfos      asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("could not parse PKCS#7 SignedData: %w", err)
+	}
+	return x509.ParseCertificates(signedData.Certificates.Bytes)
+}
+
+// looksLikePKCS12 reports whether der is a version 3 PFX.
+func looksLikePKCS12(der []byte) bool {
+	var pfx struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MacData  asn1.RawValue `asn1:"optional"`
+	}
+	_, err := asn1.Unmarshal(der, &pfx)
+	return err == nil && pfx.Version == 3
+}
+
+// looksLikeEncryptedKey reports whether der is a PKCS#8 EncryptedPrivateKeyInfo.
+func looksLikeEncryptedKey(der []byte) bool {
+	var info encryptedPrivateKeyInfo
+	rest, err := asn1.Unmarshal(der, &info)
+	return err == nil && len(rest) == 0 && len(info.Algorithm.Algorithm) > 0
+}
+
+// privateKeyFormat names the encoding of an unencrypted private key.
+func privateKeyFormat(der []byte) string {
+	if _, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return "PKCS#8"
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return "PKCS#1"
+	}
+	if _, err := x509.ParseECPrivateKey(der); err == nil {
+		return "SEC1"
+	}
+	return "unknown"
+}
+
+// revocationReasonName returns the name of a CRL reason code.
+func revocationReasonName(code int) string {
+	for name, c := range revocationReasons {
+		if c == code {
+			return name
+		}
+	}
+	return fmt.Sprintf("reason %d", code)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// testIssue creates a certificate from template, signed by parent and
+// parentKey, or self-signed when parent is nil. It returns the certificate
+// and its new ECDSA key.
+func testIssue(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.SerialNumber == nil {
+		template.SerialNumber, _ = rand.Int(rand.Reader, big.NewInt(1<<62))
+	}
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(24 * time.Hour)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// testHierarchy creates a root CA, an intermediate CA under it and a leaf
+// for leafName under the intermediate.
+func testHierarchy(t *testing.T, leafName string) (root, intermediate, leaf *x509.Certificate, rootKey, intermediateKey, leafKey *ecdsa.PrivateKey) {
+	t.Helper()
+	root, rootKey = testIssue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	intermediate, intermediateKey = testIssue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, root, rootKey)
+	leaf, leafKey = testIssue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: leafName},
+		DNSNames:    []string{leafName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediate, intermediateKey)
+	return
+}
+
+// TestDecodeArtifact decodes one of each kind of artifact.
+func TestDecodeArtifact(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root, intermediate, leaf, rootKey, _, leafKey := testHierarchy(t, "decode.example.com")
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "csr.example.com"}}, leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: intermediate.SerialNumber, RevocationTime: time.Now()}},
+	}, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7b, err := encodePKCS7Certs([]*x509.Certificate{leaf, intermediate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx, err := pkcs12.Modern.Encode(leafKey, leaf, []*x509.Certificate{intermediate}, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordlessPFX, err := pkcs12.Passwordless.Encode(leafKey, leaf, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey, err := encryptPrivateKey(leafKey, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toPEM := func(blockType string, der ...[]byte) string {
+		var text strings.Builder
+		for _, d := range der {
+			text.Write(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: d}))
+		}
+		return text.String()
+	}
+	tests := []struct {
+		name  string
+		input DecodeInput
+		kinds []string
+		note  string // A note that must be present, if any
+	}{
+		{"certificate", DecodeInput{Text: toPEM("CERTIFICATE", leaf.Raw)}, []string{decodedCertificate}, ""},
+		{"chain", DecodeInput{Text: toPEM("CERTIFICATE", leaf.Raw, intermediate.Raw, root.Raw)},
+			[]string{decodedCertificate, decodedCertificate, decodedCertificate}, "form an ordered chain"},
+		{"CSR", DecodeInput{Text: toPEM("CERTIFICATE REQUEST", csrDER)}, []string{decodedCSR}, ""},
+		{"CRL", DecodeInput{FileData: base64.StdEncoding.EncodeToString(crlDER)}, []string{decodedCRL}, ""},
+		{"PKCS#7", DecodeInput{FileData: base64.StdEncoding.EncodeToString(p7b)}, []string{decodedCertificate, decodedCertificate}, "form an ordered chain"},
+		{"PFX with password", DecodeInput{FileData: base64.StdEncoding.EncodeToString(pfx), Password: "password"},
+			[]string{decodedPrivateKey, decodedCertificate, decodedCertificate}, ""},
+		{"PFX without password", DecodeInput{FileData: base64.StdEncoding.EncodeToString(passwordlessPFX)},
+			[]string{decodedPrivateKey, decodedCertificate}, ""},
+		{"encrypted key", DecodeInput{Text: toPEM(encryptedKeyPEMType, encryptedKey), Password: "password"}, []string{decodedPrivateKey}, ""},
+		{"locked key", DecodeInput{Text: toPEM(encryptedKeyPEMType, encryptedKey)}, []string{decodedPrivateKey}, "enter its password"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := (&App{}).DecodeArtifact(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Notes == nil {
+				t.Error("notes are nil")
+			}
+			var kinds []string
+			for _, object := range result.Objects {
+				kinds = append(kinds, object.Kind)
+			}
+			if strings.Join(kinds, ",") != strings.Join(test.kinds, ",") {
+				t.Errorf("got %v, want %v", kinds, test.kinds)
+			}
+			if test.note != "" && !strings.Contains(strings.Join(result.Notes, "\n"), test.note) {
+				t.Errorf("notes %q do not mention %q", result.Notes, test.note)
+			}
+		})
+	}
+
+	// A password-protected PFX asks for its password
+	if _, err := (&App{}).DecodeArtifact(DecodeInput{FileData: base64.StdEncoding.EncodeToString(pfx)}); err == nil || !strings.Contains(err.Error(), "enter its password") {
+		t.Errorf("PFX without its password: got %v", err)
+	}
+	// An unlocked key reports its parameters, a locked one only its format
+	result, err := (&App{}).DecodeArtifact(DecodeInput{Text: toPEM(encryptedKeyPEMType, encryptedKey), Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := result.Objects[0].Key; key.Algorithm != "ECDSA P-256" || !key.Encrypted || key.Locked {
+		t.Errorf("unlocked key: got %+v", key)
+	}
+}
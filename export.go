@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats written by ExportCert.
+const (
+	exportCert      = "cert"      // The certificate alone, PEM
+	exportDER       = "der"       // The certificate alone, DER
+	exportChain     = "chain"     // The issuing CAs, PEM
+	exportFullChain = "fullchain" // The certificate followed by its issuing CAs, PEM
+	exportCombined  = "combined"  // Private key, certificate and issuing CAs in one PEM, as HAProxy wants
+	exportPKCS7     = "p7b"       // Certificate and issuing CAs as a certs-only PKCS#7 bundle
+	exportKeyPKCS1  = "key-pkcs1" // Unencrypted PKCS#1 (RSA) or SEC1 (ECDSA) key
+	exportKeyPKCS8  = "key-pkcs8" // Password-encrypted PKCS#8 key
+)
+
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+// ExportInput selects a certificate and the format to export it in.
+type ExportInput struct {
+	CertName    string `json:"certName"`
+	Format      string `json:"format"`      // One of cert, der, chain, fullchain, combined, p7b, key-pkcs1, key-pkcs8
+	IncludeRoot bool   `json:"includeRoot"` // Add the root CA to chains; servers usually leave it out
+	Password    string `json:"password"`    // Encrypts a key-pkcs8 export
+}
+
+// ExportCert writes a certificate, its chain or its key in another format
+// next to the certificate in the output folder. Chains follow the issuer
+// hierarchy in the store from the certificate's issuer up to the root.
+func (a *App) ExportCert(input ExportInput) string {
+	certName := input.CertName
+	if certName == "" {
+		return "Error: You must select a certificate to export."
+	}
+	cert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+	base := strings.TrimSuffix(certName, ".pem")
+
+	chain := func() ([]*x509.Certificate, error) {
+		cas, err := issuerChain(certName, cert)
+		if err != nil {
+			return nil, err
+		}
+		if !input.IncludeRoot && len(cas) > 0 && isSelfSigned(cas[len(cas)-1]) {
+			cas = cas[:len(cas)-1]
+		}
+		return cas, nil
+	}
+	key := func() (crypto.Signer, error) {
+		keyFile := certKeyFile(certName)
+		if keyFile == "" {
+			return nil, fmt.Errorf("certificate '%s' has no private key", certName)
+		}
+		return loadPrivateKey(keyFile)
+	}
+
+	var fileName string
+	var data []byte
+	mode := os.FileMode(0644)
+	switch input.Format {
+	case exportCert:
+		fileName, data = base+".crt", encodeCertsPEM([]*x509.Certificate{cert})
+	case exportDER:
+		fileName, data = base+".cer", cert.Raw
+	case exportChain, exportFullChain, exportPKCS7:
+		cas, err := chain()
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		switch input.Format {
+		case exportChain:
+			if len(cas) == 0 {
+				return "Error: The chain is empty; tick 'include root' to export the root CA."
+			}
+			fileName, data = base+".chain.pem", encodeCertsPEM(cas)
+		case exportFullChain:
+			fileName, data = base+".fullchain.pem", encodeCertsPEM(append([]*x509.Certificate{cert}, cas...))
+		default:
+			if data, err = encodePKCS7Certs(append([]*x509.Certificate{cert}, cas...)); err != nil {
+				return fmt.Sprintf("Error creating PKCS#7 bundle: %v", err)
+			}
+			fileName = base + ".p7b"
+		}
+	case exportCombined:
+		cas, err := chain()
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		privateKey, err := key()
+		if err != nil {
+			return fmt.Sprintf("Error loading private key: %v", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return fmt.Sprintf("Error encoding private key: %v", err)
+		}
+		data = append(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), encodeCertsPEM(append([]*x509.Certificate{cert}, cas...))...)
+		fileName, mode = base+".combined.pem", 0600
+	case exportKeyPKCS1:
+		privateKey, err := key()
+		if err != nil {
+			return fmt.Sprintf("Error loading private key: %v", err)
+		}
+		block := &pem.Block{}
+		switch k := privateKey.(type) {
+		case *rsa.PrivateKey:
+			block.Type, block.Bytes = "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k)
+		case *ecdsa.PrivateKey:
+			block.Type = "EC PRIVATE KEY"
+			if block.Bytes, err = x509.MarshalECPrivateKey(k); err != nil {
+				return fmt.Sprintf("Error encoding private key: %v", err)
+			}
+		default:
+			return fmt.Sprintf("Error: %s keys have no PKCS#1 form; export them as PKCS#8.", describePublicKey(cert.PublicKey))
+		}
+		fileName, data, mode = base+".pkcs1.key", pem.EncodeToMemory(block), 0600
+	case exportKeyPKCS8:
+		if len(input.Password) < minPassphraseLength {
+			return fmt.Sprintf("Error: The key password must be at least %d characters.", minPassphraseLength)
+		}
+		privateKey, err := key()
+		if err != nil {
+			return fmt.Sprintf("Error loading private key: %v", err)
+		}
+		der, err := encryptPrivateKey(privateKey, []byte(input.Password))
+		if err != nil {
+			return fmt.Sprintf("Error encrypting private key: %v", err)
+		}
+		fileName, data, mode = base+".p8", pem.EncodeToMemory(&pem.Block{Type: encryptedKeyPEMType, Bytes: der}), 0600
+	default:
+		return fmt.Sprintf("Error: Unknown export format '%s'.", input.Format)
+	}
+
+	// Key exports replace any earlier file rather than being written into it, so a
+	// world-readable file never briefly holds the key
+	path := filepath.Join(outputDir, fileName)
+	if err := replaceFile(fileName, data, mode); err != nil {
+		return fmt.Sprintf("Error saving '%s': %v", path, err)
+	}
+	return fmt.Sprintf("Success! Exported to '%s'.", path)
+}
+
+// issuerChain returns the CAs above an issued certificate, from its issuer up
+// to the root, following the issuer recorded in the store rather than the
+// issuer's Common Name, which need not be unique.
+func issuerChain(certName string, cert *x509.Certificate) ([]*x509.Certificate, error) {
+	issuer := ""
+	if record := inventoryRecordFor(cert); record != nil {
+		issuer = record.Issuer
+	}
+	if issuer == "" {
+		issuer = findParent(cert, storeCAs())
+	}
+	if issuer == "" {
+		return nil, fmt.Errorf("the CA that issued '%s' is not in the store", certName)
+	}
+	return caChain(issuer)
+}
+
+// encodeCertsPEM encodes certificates as concatenated PEM blocks.
+func encodeCertsPEM(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// encodePKCS7Certs encodes certificates as a degenerate, certs-only PKCS#7
+// SignedData (RFC 2315), the .p7b format Windows and Java import.
+func encodePKCS7Certs(certs []*x509.Certificate) ([]byte, error) {
+	var raw []byte
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw...)
+	}
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      struct{ ContentType asn1.ObjectIdentifier }{oidPKCS7Data},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:      emptySet,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: oidPKCS7SignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+}
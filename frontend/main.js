@@ -13,12 +13,46 @@ const caLocality = document.getElementById('ca-locality');
 const caOrg = document.getElementById('ca-org');
 const caCommon = document.getElementById('ca-common');
 const caExpiry = document.getElementById('ca-expiry');
+const caKeyAlgorithm = document.getElementById('ca-key-algorithm');
+const caParent = document.getElementById('ca-parent');
+const caPathLen = document.getElementById('ca-path-len');
+const caProfile = document.getElementById('ca-profile');
+const caPassphrase = document.getElementById('ca-passphrase');
+const caTokenModule = document.getElementById('ca-token-module');
+const caTokenLabel = document.getElementById('ca-token-label');
+const caTokenPin = document.getElementById('ca-token-pin');
+
+// Import section
+const btnImport = document.getElementById('btn-import');
+const importCertFile = document.getElementById('import-cert-file');
+const importKeyFile = document.getElementById('import-key-file');
+const importPassword = document.getElementById('import-password');
+const importName = document.getElementById('import-name');
+const importPassphrase = document.getElementById('import-passphrase');
+
+// External CA request section
+const btnGenerateCsr = document.getElementById('btn-generate-csr');
+const requestCn = document.getElementById('request-cn');
+const requestSans = document.getElementById('request-sans');
+const requestKeyAlgorithm = document.getElementById('request-key-algorithm');
+const requestIsCa = document.getElementById('request-is-ca');
+const requestPathLen = document.getElementById('request-path-len');
+const requestKeyPassphrase = document.getElementById('request-key-passphrase');
+const pendingSelector = document.getElementById('pending-selector');
+const btnDeletePending = document.getElementById('btn-delete-pending');
+const pendingCsr = document.getElementById('pending-csr');
+const pendingCertFile = document.getElementById('pending-cert-file');
+const pendingCertText = document.getElementById('pending-cert-text');
+const btnCompleteCsr = document.getElementById('btn-complete-csr');
 
 // Create Device Cert section
 const btnCreateCert = document.getElementById('btn-create-cert');
 const caSelectorDevice = document.getElementById('ca-selector-device');
 const certCn = document.getElementById('cert-cn');
 const deviceExpiry = document.getElementById('device-expiry');
+const deviceKeyAlgorithm = document.getElementById('device-key-algorithm');
+const deviceProfile = document.getElementById('device-profile');
+const deviceKeyPassphrase = document.getElementById('device-key-passphrase');
 const btnDeleteCaDevice = document.getElementById('btn-delete-ca-device');
 const sansContainer = document.getElementById('sans-container');
 const certSansInput = document.getElementById('cert-sans-input');
@@ -28,7 +62,35 @@ const btnSignCsr = document.getElementById('btn-sign-csr');
 const caSelectorCsr = document.getElementById('ca-selector-csr');
 const csrInput = document.getElementById('csr-input');
 const csrExpiry = document.getElementById('csr-expiry');
-
+const csrProfile = document.getElementById('csr-profile');
+const csrKeyPassphrase = document.getElementById('csr-key-passphrase');
+const btnReviewCsr = document.getElementById('btn-review-csr');
+const csrReview = document.getElementById('csr-review');
+const csrReviewSummary = document.getElementById('csr-review-summary');
+const csrReviewSans = document.getElementById('csr-review-sans');
+const csrAddSans = document.getElementById('csr-add-sans');
+const csrOverrideSubject = document.getElementById('csr-override-subject');
+const csrOverrideCn = document.getElementById('csr-override-cn');
+const csrHonourKu = document.getElementById('csr-honour-ku');
+const csrHonourEku = document.getElementById('csr-honour-eku');
+const csrHonourBc = document.getElementById('csr-honour-bc');
+
+// Manage CA section
+const caSelectorManage = document.getElementById('ca-selector-manage');
+const caCrlUrl = document.getElementById('ca-crl-url');
+const caCrlDays = document.getElementById('ca-crl-days');
+const btnSaveCaSettings = document.getElementById('btn-save-ca-settings');
+const btnGenerateCrl = document.getElementById('btn-generate-crl');
+const btnInspectCa = document.getElementById('btn-inspect-ca');
+const caOcspUrl = document.getElementById('ca-ocsp-url');
+const caOcspDelegated = document.getElementById('ca-ocsp-delegated');
+const btnUnlockCa = document.getElementById('btn-unlock-ca');
+const btnChangePassphrase = document.getElementById('btn-change-passphrase');
+const btnLockKeys = document.getElementById('btn-lock-keys');
+
+// OCSP Responder section
+const ocspAddress = document.getElementById('ocsp-address');
+const btnOcspToggle = document.getElementById('btn-ocsp-toggle');
 
 // Install CA section
 const installCaSection = document.getElementById('install-ca-section');
@@ -40,8 +102,59 @@ const btnGenerateInstaller = document.getElementById('btn-generate-installer');
 
 // Generated Certs section
 const btnRefreshCerts = document.getElementById('btn-refresh-certs');
+const btnRebuildInventory = document.getElementById('btn-rebuild-inventory');
 const btnOpenOutput = document.getElementById('btn-open-output');
 const certList = document.getElementById('cert-list');
+const renewCert = document.getElementById('renew-cert');
+const renewSans = document.getElementById('renew-sans');
+const renewAddSans = document.getElementById('renew-add-sans');
+const renewProfile = document.getElementById('renew-profile');
+const renewExpiry = document.getElementById('renew-expiry');
+const renewReuseKey = document.getElementById('renew-reuse-key');
+const renewKeyOptions = document.getElementById('renew-key-options');
+const renewKeyAlgorithm = document.getElementById('renew-key-algorithm');
+const renewKeyPassphrase = document.getElementById('renew-key-passphrase');
+const renewRevokeOld = document.getElementById('renew-revoke-old');
+const btnRenewCert = document.getElementById('btn-renew-cert');
+const exportCert = document.getElementById('export-cert');
+const exportFormat = document.getElementById('export-format');
+const exportIncludeRoot = document.getElementById('export-include-root');
+const exportPassword = document.getElementById('export-password');
+const exportPfxOptions = document.getElementById('export-pfx-options');
+const exportPfxEncryption = document.getElementById('export-pfx-encryption');
+const exportFriendlyName = document.getElementById('export-friendly-name');
+const exportLeafOnly = document.getElementById('export-leaf-only');
+const ksFormat = document.getElementById('ks-format');
+const ksStorePassword = document.getElementById('ks-store-password');
+const ksAlias = document.getElementById('ks-alias');
+const ksCert = document.getElementById('ks-cert');
+const ksKeyPassword = document.getElementById('ks-key-password');
+const caSelectorTruststore = document.getElementById('ca-selector-truststore');
+const ksIncludeChain = document.getElementById('ks-include-chain');
+const btnExportKeystore = document.getElementById('btn-export-keystore');
+const btnExportTruststore = document.getElementById('btn-export-truststore');
+const btnExport = document.getElementById('btn-export');
+const verifyCert = document.getElementById('verify-cert');
+const verifyCa = document.getElementById('verify-ca');
+const verifyHostname = document.getElementById('verify-hostname');
+const verifyUsage = document.getElementById('verify-usage');
+const verifyAt = document.getElementById('verify-at');
+const verifyKeyPath = document.getElementById('verify-key-path');
+const verifyKeyPassword = document.getElementById('verify-key-password');
+const btnVerify = document.getElementById('btn-verify');
+const verifyReport = document.getElementById('verify-report');
+const probeAddress = document.getElementById('probe-address');
+const probeServerName = document.getElementById('probe-server-name');
+const probeStartTls = document.getElementById('probe-starttls');
+const btnProbe = document.getElementById('btn-probe');
+const probeReport = document.getElementById('probe-report');
+const btnProbeImport = document.getElementById('btn-probe-import');
+const decodeInput = document.getElementById('decode-input');
+const decodePath = document.getElementById('decode-path');
+const decodeFile = document.getElementById('decode-file');
+const decodePassword = document.getElementById('decode-password');
+const btnDecode = document.getElementById('btn-decode');
+const decodeResults = document.getElementById('decode-results');
 
 // Modal section
 const inspectModal = document.getElementById('inspect-modal');
@@ -59,7 +172,10 @@ window.addEventListener('load', () => {
     populateYearDropdowns();
     checkAdminStatus();
     refreshCAList();
+    refreshProfiles();
     refreshCertList();
+    refreshPendingRequests();
+    refreshOCSPStatus();
     setCopyright();
     setupSanInput();
 });
@@ -77,11 +193,122 @@ btnCreateCA.addEventListener('click', () => {
         org: caOrg.value || "IQX Limited",
         commonName: caCommon.value,
         expiryDays: parseInt(caExpiry.value) * 365,
+        keyAlgorithm: caKeyAlgorithm.value,
+        pathLen: caPathLen.value === '' ? -1 : parseInt(caPathLen.value),
+        profile: caProfile.value,
+        passphrase: caPassphrase.value,
+        token: {
+            module: caTokenModule.value.trim(),
+            token: caTokenLabel.value.trim(),
+            pin: caTokenPin.value,
+        },
+        constraints: readNameConstraints(),
     };
+    caPassphrase.value = '';
+    caTokenPin.value = '';
+    if (caParent.value) {
+        const parentName = caParent.value;
+        logMessage(`Creating intermediate CA '${caInput.commonName}' signed by '${parentName}'...`);
+        withUnlockedCA(parentName, () => window.go.main.App.CreateIntermediateCA(caInput, parentName)).then(handleResult).then(refreshCAList);
+        return;
+    }
     logMessage(`Creating CA '${caInput.commonName}'...`);
     window.go.main.App.CreateCA(caInput).then(handleResult).then(refreshCAList);
 });
 
+// Generate CSR button
+btnGenerateCsr.addEventListener('click', () => {
+    if (!requestCn.value) {
+        showToast("Please enter a Common Name (CN).", "error");
+        return;
+    }
+    const csrInput = {
+        commonName: requestCn.value.trim(),
+        sans: requestSans.value,
+        keyAlgorithm: requestKeyAlgorithm.value,
+        subject: readSubject('request'),
+        isCA: requestIsCa.checked,
+        pathLen: requestPathLen.value === '' ? -1 : parseInt(requestPathLen.value),
+        keyPassphrase: requestKeyPassphrase.value,
+    };
+    requestKeyPassphrase.value = '';
+    logMessage(`Generating key and CSR for '${csrInput.commonName}'...`);
+    window.go.main.App.GenerateCSR(csrInput).then(handleResult).then(result => {
+        if (result && result.toLowerCase().startsWith("success")) {
+            requestCn.value = '';
+            requestSans.value = '';
+            fillSubject('request', {});
+        }
+        refreshPendingRequests(csrInput.commonName);
+    });
+});
+
+pendingSelector.addEventListener('change', showPendingCSR);
+
+// Complete Request button
+btnCompleteCsr.addEventListener('click', () => {
+    const name = pendingSelector.value;
+    if (!name) {
+        showToast("Please select a pending request.", "error");
+        return;
+    }
+    const file = pendingCertFile.files[0];
+    const text = pendingCertText.value.trim();
+    if (!file && !text) {
+        showToast("Please choose or paste the certificate returned by the CA.", "error");
+        return;
+    }
+    const certData = file ? readFileBase64(file) : Promise.resolve(btoa(text));
+    logMessage(`Completing request '${name}'...`);
+    certData.then(data => window.go.main.App.CompleteCSR(name, data)).then(handleResult).then(result => {
+        if (result && result.toLowerCase().startsWith("success")) {
+            pendingCertFile.value = '';
+            pendingCertText.value = '';
+        }
+        refreshPendingRequests();
+        refreshCAList();
+        refreshCertList();
+    });
+});
+
+btnDeletePending.addEventListener('click', () => {
+    const name = pendingSelector.value;
+    if (!name || !confirm(`Delete the pending request '${name}' and its private key?`)) {
+        return;
+    }
+    window.go.main.App.DeletePendingRequest(name).then(handleResult).then(() => refreshPendingRequests());
+});
+
+// Import button
+btnImport.addEventListener('click', () => {
+    const certFile = importCertFile.files[0];
+    if (!certFile) {
+        showToast("Please choose a certificate or PFX file to import.", "error");
+        return;
+    }
+    Promise.all([readFileBase64(certFile), readFileBase64(importKeyFile.files[0])]).then(([certData, keyData]) => {
+        const importInput = {
+            certFile: certData,
+            keyFile: keyData,
+            password: importPassword.value,
+            name: importName.value.trim(),
+            passphrase: importPassphrase.value,
+        };
+        importPassword.value = '';
+        importPassphrase.value = '';
+        logMessage(`Importing '${certFile.name}'...`);
+        return window.go.main.App.ImportCertificate(importInput);
+    }).then(handleResult).then(result => {
+        if (result && result.toLowerCase().startsWith("success")) {
+            importCertFile.value = '';
+            importKeyFile.value = '';
+            importName.value = '';
+        }
+        refreshCAList();
+        refreshCertList();
+    });
+});
+
 // Create Certificate button
 btnCreateCert.addEventListener('click', () => {
     const cn = certCn.value;
@@ -102,13 +329,25 @@ btnCreateCert.addEventListener('click', () => {
         return;
     }
 
+    const certInput = {
+        commonName: cn,
+        sans: sans,
+        caName: selectedCA,
+        expiryDays: expiry,
+        keyAlgorithm: deviceKeyAlgorithm.value,
+        profile: deviceProfile.value,
+        subject: readSubject('device'),
+        keyPassphrase: deviceKeyPassphrase.value,
+    };
     logMessage(`Creating certificate for ${cn}...`);
-    window.go.main.App.CreateCert(cn, sans, selectedCA, expiry)
+    withUnlockedCA(selectedCA, () => window.go.main.App.CreateCert(certInput))
         .then(result => {
             handleResult(result);
             if (result && result.toLowerCase().startsWith("success")) {
                 certCn.value = '';
                 sansContainer.querySelectorAll('.san-pill').forEach(pill => pill.remove());
+                fillSubject('device', {});
+                deviceKeyPassphrase.value = '';
             }
         })
         .then(refreshCertList);
@@ -129,17 +368,135 @@ btnSignCsr.addEventListener('click', () => {
         return;
     }
 
+    // Edits from the review apply only while it is shown
+    const reviewed = csrReview.style.display !== 'none';
+    const signInput = {
+        csr: csr,
+        caName: selectedCA,
+        expiryDays: expiry,
+        profile: csrProfile.value,
+        keyPassphrase: csrKeyPassphrase.value,
+        overrideSubject: reviewed && csrOverrideSubject.checked,
+        commonName: csrOverrideCn.value.trim(),
+        subject: readSubject('csr'),
+        removeSans: reviewed ? [...csrReviewSans.querySelectorAll('input:not(:checked)')].map(box => box.value) : [],
+        addSans: reviewed ? csrAddSans.value : '',
+        honourKeyUsage: reviewed && csrHonourKu.checked,
+        honourExtKeyUsage: reviewed && csrHonourEku.checked,
+        honourBasicConstraints: reviewed && csrHonourBc.checked,
+    };
+
     logMessage(`Signing CSR...`);
-    window.go.main.App.SignCSR(csr, selectedCA, expiry)
+    withUnlockedCA(selectedCA, () => window.go.main.App.SignReviewedCSR(signInput))
         .then(result => {
             handleResult(result);
             if (result && result.toLowerCase().startsWith("success")) {
                 csrInput.value = '';
+                csrKeyPassphrase.value = '';
+                csrReview.style.display = 'none';
             }
         })
-        .then(refreshCertList);
+        .then(refreshCertList)
+        .then(refreshCAList);
 });
 
+// Review CSR button
+btnReviewCsr.addEventListener('click', () => {
+    if (!csrInput.value) {
+        showToast("Please paste the CSR content.", "error");
+        return;
+    }
+    window.go.main.App.ReviewCSR(csrInput.value).then(details => {
+        showCSRReview(details);
+        logMessage(`CSR for '${details.commonName || details.subject}' decoded; review it before signing.`);
+    }).catch(err => {
+        csrReview.style.display = 'none';
+        logMessage(`Error reviewing CSR: ${err}`, "error");
+        showToast(`Error reviewing CSR: ${err}`, "error");
+    });
+});
+
+// A new CSR needs a new review
+csrInput.addEventListener('input', () => csrReview.style.display = 'none');
+
+// Manage CA selector, settings and CRL buttons
+caSelectorManage.addEventListener('change', loadCASettings);
+
+btnSaveCaSettings.addEventListener('click', () => {
+    const selectedCA = caSelectorManage.value;
+    if (!selectedCA) {
+        showToast("Please select a CA.", "error");
+        return;
+    }
+    const settings = {
+        crlUrl: caCrlUrl.value.trim(),
+        crlValidityDays: parseInt(caCrlDays.value) || 0,
+        ocspUrl: caOcspUrl.value.trim(),
+        ocspDelegated: caOcspDelegated.checked,
+        defaultSubject: readSubject('ca-default'),
+        policy: readPolicy(),
+    };
+    logMessage(`Saving settings for CA '${selectedCA}'...`);
+    window.go.main.App.SetCASettings(selectedCA, settings).then(handleResult);
+});
+
+btnInspectCa.addEventListener('click', () => inspectCA(caSelectorManage.value));
+
+btnGenerateCrl.addEventListener('click', () => {
+    const selectedCA = caSelectorManage.value;
+    if (!selectedCA) {
+        showToast("Please select a CA to generate a CRL for.", "error");
+        return;
+    }
+    logMessage(`Generating CRL for CA '${selectedCA}'...`);
+    withUnlockedCA(selectedCA, () => window.go.main.App.GenerateCRL(selectedCA, parseInt(caCrlDays.value) || 0)).then(handleResult);
+});
+
+btnUnlockCa.addEventListener('click', () => {
+    const selectedCA = caSelectorManage.value;
+    if (!selectedCA) {
+        showToast("Please select a CA to unlock.", "error");
+        return;
+    }
+    const passphrase = prompt(`Enter the passphrase or token PIN for CA '${selectedCA}':`);
+    if (passphrase === null) {
+        return;
+    }
+    window.go.main.App.UnlockCA(selectedCA, passphrase).then(handleResult).then(refreshCAList);
+});
+
+btnChangePassphrase.addEventListener('click', () => {
+    const selectedCA = caSelectorManage.value;
+    if (!selectedCA) {
+        showToast("Please select a CA.", "error");
+        return;
+    }
+    const oldPassphrase = prompt(`Current passphrase for CA '${selectedCA}' (leave blank if the key is not encrypted):`);
+    if (oldPassphrase === null) {
+        return;
+    }
+    const newPassphrase = prompt("New passphrase (leave blank to remove the protection):");
+    if (newPassphrase === null) {
+        return;
+    }
+    logMessage(`Changing passphrase for CA '${selectedCA}'...`);
+    window.go.main.App.ChangeCAPassphrase(selectedCA, oldPassphrase, newPassphrase).then(handleResult).then(refreshCAList);
+});
+
+btnLockKeys.addEventListener('click', () => {
+    window.go.main.App.LockKeys().then(handleResult).then(refreshCAList);
+});
+// OCSP responder start/stop button
+btnOcspToggle.addEventListener('click', () => {
+    window.go.main.App.OCSPResponderAddress().then(address => {
+        if (address) {
+            logMessage("Stopping OCSP responder...");
+            return window.go.main.App.StopOCSPResponder();
+        }
+        logMessage(`Starting OCSP responder on ${ocspAddress.value}...`);
+        return window.go.main.App.StartOCSPResponder(ocspAddress.value.trim());
+    }).then(handleResult).then(refreshOCSPStatus);
+});
 
 // Install CA button
 btnInstallCA.addEventListener('click', () => {
@@ -167,6 +524,12 @@ btnGenerateInstaller.addEventListener('click', () => {
 // Refresh device certificate list button
 btnRefreshCerts.addEventListener('click', refreshCertList);
 
+// Rescan output folder button
+btnRebuildInventory.addEventListener('click', () => {
+    logMessage("Rescanning output folder...");
+    window.go.main.App.RebuildInventory().then(handleResult).then(refreshCAList).then(refreshProfiles).then(refreshCertList).then(() => refreshPendingRequests());
+});
+
 // Open output directory button
 btnOpenOutput.addEventListener('click', () => {
     logMessage("Opening output directory...");
@@ -240,27 +603,185 @@ function setCopyright() {
 
 function inspectCert(certName) {
     logMessage(`Inspecting certificate '${certName}'...`);
-    window.go.main.App.InspectCert(certName).then(details => {
-        let addresses = '';
-        if (details.ipAddresses && details.ipAddresses.length > 0) {
-            addresses += `<p><strong>IP Addresses:</strong> ${details.ipAddresses.join(', ')}</p>`;
+    window.go.main.App.InspectCert(certName).then(showCertDetails).catch(err => {
+        handleResult(`Error inspecting certificate: ${err}`);
+    });
+}
+
+function inspectCA(caName) {
+    if (!caName) {
+        showToast("Please select a CA to inspect.", "error");
+        return;
+    }
+    logMessage(`Inspecting CA '${caName}'...`);
+    window.go.main.App.InspectCA(caName).then(showCertDetails).catch(err => {
+        handleResult(`Error inspecting CA: ${err}`);
+    });
+}
+
+// showCertDetails fills the inspect modal with the details of a certificate.
+function showCertDetails(details) {
+    const row = (label, values) => {
+        const list = Array.isArray(values) ? values : (values ? [values] : []);
+        return list.length > 0 ? `<p><strong>${label}:</strong> ${list.join(', ')}</p>` : '';
+    };
+    let basicConstraints = details.isCA ? 'CA' : 'Not a CA';
+    if (details.isCA && details.pathLen >= 0) {
+        basicConstraints += `, path length ${details.pathLen}`;
+    }
+    const extensions = (details.extensions || []).map(ext =>
+        `<li>${ext.name || ext.oid}${ext.critical ? ' (critical)' : ''}: ${ext.value}</li>`).join('');
+
+    modalBody.innerHTML = `
+        <p><strong>Subject (CN):</strong> ${details.subject}</p>
+        ${row('Subject DN', details.subjectDn)}
+        <p><strong>Issuer:</strong> ${details.issuer}${details.selfSigned ? ' (self-signed)' : ''}</p>
+        ${row('Issuer DN', details.issuerDn)}
+        ${row('IP Addresses', details.ipAddresses)}
+        ${row('DNS Names (SANs)', details.dnsNames)}
+        ${row('Email Addresses', details.emails)}
+        ${row('URIs', details.uris)}
+        ${row('User Principal Names', details.upns)}
+        <p><strong>Valid From:</strong> ${details.validFrom}</p>
+        <p><strong>Valid Until:</strong> ${details.validUntil}</p>
+        <p><strong>Serial Number:</strong> ${details.serialNumber}</p>
+        <p><strong>Status:</strong> ${details.status}</p>
+        ${details.revoked ? `<p><strong>Revoked:</strong> ${details.revokeReason} on ${details.revokedAt}</p>` : ''}
+        <p><strong>Key:</strong> ${details.keyAlgorithm}${details.keyBits ? ` (${details.keyBits} bits)` : ''}</p>
+        <p><strong>Signature Algorithm:</strong> ${details.signatureAlgorithm}</p>
+        ${row('Key Usage', details.keyUsage)}
+        ${row('Extended Key Usage', details.extKeyUsage)}
+        <p><strong>Basic Constraints:</strong> ${basicConstraints}</p>
+        ${row('Subject Key ID', details.subjectKeyId)}
+        ${row('Authority Key ID', details.authorityKeyId)}
+        ${row('CRL Distribution Points', details.crlUrls)}
+        ${row('OCSP Responders', details.ocspUrls)}
+        ${row('CA Issuers', details.caIssuerUrls)}
+        ${row('Policies', details.policies)}
+        <p><strong>SHA-1 Fingerprint:</strong> <code>${details.sha1Fingerprint}</code></p>
+        <p><strong>SHA-256 Fingerprint:</strong> <code>${details.sha256Fingerprint}</code></p>
+        <p><strong>SPKI Pin (pin-sha256):</strong> <code>${details.spkiPin}</code></p>
+        ${extensions ? `<details><summary>Extensions</summary><ul>${extensions}</ul></details>` : ''}
+        <details><summary>Text Dump</summary><pre class="cert-text"></pre></details>
+    `;
+    modalBody.querySelector('.cert-text').textContent = details.text;
+    inspectModal.style.display = 'flex';
+}
+
+// showVerifyReport lists each check made by VerifyCert, failures in red
+function showVerifyReport(report) {
+    verifyReport.textContent = `${report.valid ? 'VALID' : 'NOT VALID'} at ${report.at}\nChain: ${report.chain.join('  \u2192  ')}\n`;
+    report.checks.forEach(check => {
+        const line = document.createElement('span');
+        if (!check.passed) {
+            line.className = 'error';
         }
-        if (details.dnsNames && details.dnsNames.length > 0) {
-            addresses += `<p><strong>DNS Names (SANs):</strong> ${details.dnsNames.join(', ')}</p>`;
+        line.textContent = `\n${check.passed ? '\u2713' : '\u2717'} ${check.name}: ${check.detail}`;
+        verifyReport.appendChild(line);
+    });
+    verifyReport.style.display = 'block';
+}
+
+// showProbeResult shows an endpoint's chain and its problems, issues in red
+function showProbeResult(result) {
+    const lines = [
+        `${result.address} (SNI ${result.serverName}), ${result.tlsVersion}, ${result.cipherSuite}`,
+        result.trusted ? `Trusted by '${result.trustedBy}': ${result.verifiedChain.join(' \u2192 ')}` : 'Not trusted by any CA in the store',
+        '',
+        'Presented chain:',
+    ];
+    result.chain.forEach((cert, i) => {
+        const d = cert.details;
+        lines.push(`  ${i}. ${d.subjectDn}`);
+        lines.push(`     issued by ${d.issuerDn}, valid until ${d.validUntil}${cert.storeName ? `, in store as '${cert.storeName}'` : ''}`);
+    });
+    probeReport.textContent = lines.join('\n') + '\n';
+    const append = (text, isError) => {
+        const line = document.createElement('span');
+        if (isError) {
+            line.className = 'error';
         }
+        line.textContent = `\n${text}`;
+        probeReport.appendChild(line);
+    };
+    result.issues.forEach(issue => append(`Issue: ${issue}`, true));
+    result.warnings.forEach(warning => append(`Warning: ${warning}`, false));
+    probeReport.style.display = 'block';
 
-        modalBody.innerHTML = `
-            <p><strong>Subject (CN):</strong> ${details.subject}</p>
-            <p><strong>Issuer:</strong> ${details.issuer}</p>
-            ${addresses}
-            <p><strong>Valid From:</strong> ${details.validFrom}</p>
-            <p><strong>Valid Until:</strong> ${details.validUntil}</p>
-            <p><strong>Serial Number:</strong> ${details.serialNumber}</p>
-        `;
-        inspectModal.style.display = 'flex';
-    }).catch(err => {
-        handleResult(`Error inspecting certificate: ${err}`);
+    probedLeaf = result.chain[0].data;
+    btnProbeImport.style.display = result.chain[0].storeName ? 'none' : '';
+}
+
+// showDecodeResult lists what DecodeArtifact found, one summary per object;
+// certificates open the details modal.
+function showDecodeResult(result) {
+    const kinds = { certificate: 'Certificate', csr: 'Certificate Request', crl: 'CRL', privateKey: 'Private Key', publicKey: 'Public Key' };
+    decodeResults.innerHTML = '';
+    result.objects.forEach((object, i) => {
+        let lines = [];
+        if (object.certificate) {
+            const c = object.certificate;
+            lines = [
+                `Subject:    ${c.subjectDn}`,
+                `Issuer:     ${c.issuerDn}${c.selfSigned ? ' (self-signed)' : ''}`,
+                `Valid:      ${c.validFrom} to ${c.validUntil}`,
+                `Serial:     ${c.serialNumber}`,
+                `Key:        ${c.keyAlgorithm}`,
+                `CA:         ${c.isCA ? 'yes' : 'no'}`,
+                `SHA-256:    ${c.sha256Fingerprint}`,
+            ];
+        } else if (object.csr) {
+            const r = object.csr;
+            lines = [
+                `Subject:    ${r.subject || '(empty)'}`,
+                `Key:        ${r.keyAlgorithm}`,
+                `Signature:  ${r.signatureAlgorithm}${r.signatureValid ? '' : ' (does not verify)'}`,
+                `SANs:       ${(r.sans || []).join(', ') || '(none)'}`,
+            ];
+        } else if (object.crl) {
+            const l = object.crl;
+            lines = [
+                `Issuer:      ${l.issuer}${l.issuerName ? ` (CA '${l.issuerName}', signature verified)` : ''}`,
+                `CRL Number:  ${l.number || '(none)'}`,
+                `This Update: ${l.thisUpdate}`,
+                `Next Update: ${l.nextUpdate || '(none)'}${l.expired ? ' (expired)' : ''}`,
+                `Revoked:     ${l.entries.length} certificate(s)`,
+            ];
+            l.entries.forEach(entry => lines.push(`  ${entry.serial}  ${entry.revokedAt}  ${entry.reason}`));
+        } else if (object.key) {
+            const k = object.key;
+            lines = [`Format:     ${k.format}${k.encrypted ? ' (encrypted)' : ''}`];
+            if (k.locked) {
+                lines.push('Parameters: (enter the password to see them)');
+            } else {
+                lines.push(`Algorithm:  ${k.algorithm}`, `SPKI Pin:   ${k.spkiPin}`);
+                if (k.matches.length > 0) {
+                    lines.push(`Matches:    ${k.matches.join(', ')}`);
+                }
+            }
+        }
+        if (object.storeName) {
+            lines.push(`In store as '${object.storeName}'.`);
+        }
+
+        const heading = document.createElement('h3');
+        heading.textContent = `${i + 1}. ${kinds[object.kind]} (${object.source})`;
+        const summary = document.createElement('pre');
+        summary.textContent = lines.join('\n');
+        decodeResults.append(heading, summary);
+        if (object.certificate) {
+            const button = document.createElement('button');
+            button.className = 'btn-secondary';
+            button.textContent = 'Details';
+            button.addEventListener('click', () => showCertDetails(object.certificate));
+            decodeResults.appendChild(button);
+        }
     });
+    if (result.notes.length > 0) {
+        const notes = document.createElement('pre');
+        notes.textContent = result.notes.join('\n');
+        decodeResults.appendChild(notes);
+    }
 }
 
 function deleteCA(caName) {
@@ -285,17 +806,365 @@ function deleteCert(certName) {
     }
 }
 
+function revokeCert(certName) {
+    window.go.main.App.ListRevocationReasons().then(reasons => {
+        const reason = prompt(`Revocation reason for '${certName}':\n${reasons.join(', ')}\n\nUse 'certificateHold' to suspend it temporarily.`, 'unspecified');
+        if (reason === null) { // User clicked cancel
+            return;
+        }
+        logMessage(`Revoking certificate '${certName}' (${reason})...`, "error");
+        window.go.main.App.RevokeCert(certName, reason.trim()).then(handleResult).then(refreshCertList);
+    });
+}
+
+function releaseCertHold(certName) {
+    logMessage(`Releasing hold on certificate '${certName}'...`);
+    window.go.main.App.ReleaseCertHold(certName).then(handleResult).then(refreshCertList);
+}
+
+function writeOCSPStaple(certName, issuerName) {
+    logMessage(`Writing OCSP response for '${certName}'...`);
+    withUnlockedCA(issuerName, () => window.go.main.App.WriteOCSPStaple(certName)).then(handleResult);
+}
+
+// exportPfx opens the Export card with certName selected as a PFX.
 function exportPfx(certName) {
     if (!certName) {
         showToast("Cannot determine certificate to export.", "error");
         return;
     }
-    const password = prompt("Please enter a password for the PFX file. Leave blank for no password.");
-    if (password === null) { // User clicked cancel
+    exportCert.value = certName;
+    exportFormat.value = 'pfx';
+    exportPfxOptions.style.display = '';
+    exportCert.closest('.card').scrollIntoView({ behavior: 'smooth' });
+    exportPassword.focus();
+}
+
+exportFormat.addEventListener('change', () => {
+    exportPfxOptions.style.display = exportFormat.value === 'pfx' ? '' : 'none';
+});
+
+// certRecords is the last list of certificates from the inventory.
+let certRecords = [];
+
+// renewCertSelected fills the renewal SAN checkboxes from the selected certificate
+function renewCertSelected() {
+    renewSans.innerHTML = '';
+    renewAddSans.value = '';
+    const certName = renewCert.value;
+    if (!certName) {
+        return;
+    }
+    window.go.main.App.InspectCert(certName).then(details => {
+        const typed = (prefix, values) => (values || []).map(value => `${prefix}:${value}`);
+        [
+            ...typed('DNS', details.dnsNames),
+            ...typed('IP', details.ipAddresses),
+            ...typed('email', details.emails),
+            ...typed('URI', details.uris),
+            ...typed('UPN', details.upns),
+        ].forEach(san => {
+            const label = document.createElement('label');
+            label.className = 'checkbox-label';
+            const box = document.createElement('input');
+            box.type = 'checkbox';
+            box.value = san;
+            box.checked = true;
+            label.appendChild(box);
+            label.appendChild(document.createTextNode(` ${san}`));
+            renewSans.appendChild(label);
+        });
+    }).catch(err => {
+        logMessage(`Error reading certificate '${certName}': ${err}`, "error");
+    });
+}
+
+// renewCertFor selects a certificate in the renewal form and scrolls to it
+function renewCertFor(certName) {
+    renewCert.value = certName;
+    renewCertSelected();
+    renewCert.closest('.card').scrollIntoView({ behavior: 'smooth' });
+}
+
+renewCert.addEventListener('change', renewCertSelected);
+
+renewReuseKey.addEventListener('change', () => {
+    renewKeyOptions.style.display = renewReuseKey.checked ? 'none' : '';
+});
+
+btnRenewCert.addEventListener('click', () => {
+    const certName = renewCert.value;
+    if (!certName) {
+        showToast("Please select a certificate to renew.", "error");
+        return;
+    }
+    const record = certRecords.find(item => item.name === certName);
+    const input = {
+        certName: certName,
+        profile: renewProfile.value,
+        expiryDays: parseInt(renewExpiry.value) * 365,
+        reuseKey: renewReuseKey.checked,
+        keyAlgorithm: renewKeyAlgorithm.value,
+        keyPassphrase: renewKeyPassphrase.value,
+        removeSans: [...renewSans.querySelectorAll('input:not(:checked)')].map(box => box.value),
+        addSans: renewAddSans.value,
+        revokeOld: renewRevokeOld.checked,
+    };
+    logMessage(`Renewing certificate '${certName}'...`);
+    const renew = () => window.go.main.App.RenewCert(input);
+    // A reused key saved with a new passphrase must be unlocked too
+    const action = input.reuseKey && input.keyPassphrase && record && record.keyFile
+        ? () => withUnlocked(`the key of '${certName}'`,
+            passphrase => window.go.main.App.UnlockCertKey(certName, passphrase), renew, record.keyFile)
+        : renew;
+    withUnlockedCA(record ? record.issuer : '', action)
+        .then(result => {
+            handleResult(result);
+            if (result && result.toLowerCase().startsWith("success")) {
+                renewKeyPassphrase.value = '';
+                renewRevokeOld.checked = false;
+            }
+        })
+        .then(refreshCertList);
+});
+
+// Export button
+btnExport.addEventListener('click', () => {
+    const certName = exportCert.value;
+    if (!certName) {
+        showToast("Please select a certificate to export.", "error");
         return;
     }
-    logMessage(`Exporting '${certName}' to PFX...`);
-    window.go.main.App.ExportToPFX(certName, password).then(handleResult);
+    const input = {
+        certName: certName,
+        format: exportFormat.value,
+        includeRoot: exportIncludeRoot.checked,
+        password: exportPassword.value,
+    };
+    const pfx = exportFormat.value === 'pfx';
+    const pfxInput = {
+        certName: certName,
+        password: exportPassword.value,
+        encryption: exportPfxEncryption.value,
+        friendlyName: exportFriendlyName.value.trim(),
+        leafOnly: exportLeafOnly.checked,
+    };
+    logMessage(`Exporting '${certName}' as ${exportFormat.options[exportFormat.selectedIndex].text}...`);
+    withUnlocked(`the key of '${certName}'`,
+        passphrase => window.go.main.App.UnlockCertKey(certName, passphrase),
+        () => pfx ? window.go.main.App.ExportToPFX(pfxInput) : window.go.main.App.ExportCert(input))
+        .then(result => {
+            handleResult(result);
+            if (result && result.toLowerCase().startsWith("success")) {
+                exportPassword.value = '';
+            }
+        });
+});
+
+// readKeyStoreInput collects the fields shared by keystore and truststore exports.
+function readKeyStoreInput() {
+    return {
+        format: ksFormat.value,
+        alias: ksAlias.value.trim(),
+        storePassword: ksStorePassword.value,
+        keyPassword: ksKeyPassword.value,
+        includeChain: ksIncludeChain.checked,
+    };
+}
+
+btnExportKeystore.addEventListener('click', () => {
+    const certName = ksCert.value;
+    if (!certName) {
+        showToast("Please select a certificate for the keystore.", "error");
+        return;
+    }
+    const input = { ...readKeyStoreInput(), certName: certName };
+    logMessage(`Exporting '${certName}' to a ${ksFormat.value.toUpperCase()} keystore...`);
+    withUnlocked(`the key of '${certName}'`,
+        passphrase => window.go.main.App.UnlockCertKey(certName, passphrase),
+        () => window.go.main.App.ExportKeyStore(input))
+        .then(handleResult);
+});
+
+btnExportTruststore.addEventListener('click', () => {
+    const caName = caSelectorTruststore.value;
+    if (!caName) {
+        showToast("Please select a CA for the truststore.", "error");
+        return;
+    }
+    const input = { ...readKeyStoreInput(), caName: caName };
+    logMessage(`Exporting a ${ksFormat.value.toUpperCase()} truststore for '${caName}'...`);
+    window.go.main.App.ExportTrustStore(input).then(handleResult);
+});
+
+// Verify button
+btnVerify.addEventListener('click', () => {
+    const certName = verifyCert.value;
+    if (!certName) {
+        showToast("Please select a certificate to verify.", "error");
+        return;
+    }
+    const input = {
+        certName: certName,
+        caName: verifyCa.value,
+        hostname: verifyHostname.value.trim(),
+        usage: verifyUsage.value,
+        at: verifyAt.value,
+        keyPath: verifyKeyPath.value,
+        keyPassword: verifyKeyPassword.value,
+    };
+    verifyKeyPassword.value = '';
+    window.go.main.App.VerifyCert(input).then(report => {
+        showVerifyReport(report);
+        const failed = report.checks.filter(c => !c.passed).length;
+        if (report.valid) {
+            logMessage(`'${certName}' verified: all ${report.checks.length} checks passed.`, "success");
+        } else {
+            logMessage(`'${certName}' failed ${failed} of ${report.checks.length} checks.`, "error");
+        }
+    }).catch(err => {
+        verifyReport.style.display = 'none';
+        logMessage(`Error verifying: ${err}`, "error");
+        showToast(`Error verifying: ${err}`, "error");
+    });
+});
+
+// probedLeaf is the base64 DER of the last probed endpoint's certificate, for importing.
+let probedLeaf = '';
+
+// Probe button
+btnProbe.addEventListener('click', () => {
+    const address = probeAddress.value.trim();
+    if (!address) {
+        showToast("Please enter the host and port to probe.", "error");
+        return;
+    }
+    btnProbeImport.style.display = 'none';
+    logMessage(`Probing ${address}...`);
+    window.go.main.App.ProbeTLS({
+        address: address,
+        serverName: probeServerName.value.trim(),
+        startTls: probeStartTls.value,
+    }).then(result => {
+        showProbeResult(result);
+        if (result.issues.length === 0) {
+            logMessage(`${result.address} presents a trusted chain for '${result.serverName}'.`, "success");
+        } else {
+            logMessage(`${result.address} has ${result.issues.length} issue(s).`, "error");
+        }
+    }).catch(err => {
+        probeReport.style.display = 'none';
+        logMessage(`Error probing ${address}: ${err}`, "error");
+        showToast(`Error probing: ${err}`, "error");
+    });
+});
+
+btnProbeImport.addEventListener('click', () => {
+    window.go.main.App.ImportCertificate({ certFile: probedLeaf }).then(handleResult).then(result => {
+        if (result && result.toLowerCase().startsWith("success")) {
+            btnProbeImport.style.display = 'none';
+        }
+        refreshCAList();
+        refreshCertList();
+    });
+});
+
+// Decode button: a chosen file wins over a path, a path over pasted text
+btnDecode.addEventListener('click', () => {
+    const file = decodeFile.files[0];
+    if (!file && !decodePath.value.trim() && !decodeInput.value.trim()) {
+        showToast("Paste something to decode, or give a file.", "error");
+        return;
+    }
+    readFileBase64(file).then(fileData => window.go.main.App.DecodeArtifact({
+        text: decodeInput.value,
+        path: decodePath.value,
+        fileData: fileData,
+        password: decodePassword.value,
+    })).then(result => {
+        showDecodeResult(result);
+        logMessage(`Decoded ${result.encoding}: found ${result.objects.length} object(s).`, "success");
+    }).catch(err => {
+        decodeResults.innerHTML = '';
+        logMessage(`Error decoding: ${err}`, "error");
+        showToast(`Error decoding: ${err}`, "error");
+    });
+});
+
+// pendingRequests holds the last list of pending requests, for showing their CSRs.
+let pendingRequests = [];
+
+// refreshPendingRequests reloads the pending requests, selecting the one for
+// commonName if given.
+function refreshPendingRequests(commonName) {
+    window.go.main.App.ListPendingRequests().then(requests => {
+        pendingRequests = requests || [];
+        const previous = pendingSelector.value;
+        pendingSelector.innerHTML = '';
+        pendingRequests.forEach(request => {
+            const option = document.createElement('option');
+            option.value = request.name;
+            option.textContent = `${request.name}${request.isCA ? ' (CA)' : ''} \u2014 ${request.keyAlgorithm}`;
+            pendingSelector.appendChild(option);
+        });
+        const match = pendingRequests.filter(r => r.commonName === commonName).pop();
+        if (match) {
+            pendingSelector.value = match.name;
+        } else if (pendingRequests.some(r => r.name === previous)) {
+            pendingSelector.value = previous;
+        }
+        showPendingCSR();
+    }).catch(err => {
+        logMessage(`Error refreshing pending requests: ${err}`, "error");
+    });
+}
+
+// showPendingCSR shows the CSR of the selected pending request.
+function showPendingCSR() {
+    const request = pendingRequests.find(r => r.name === pendingSelector.value);
+    pendingCsr.value = request ? request.csr : '';
+}
+
+// readFileBase64 reads a chosen file as base64, or resolves to "" for no file.
+function readFileBase64(file) {
+    if (!file) {
+        return Promise.resolve('');
+    }
+    return new Promise((resolve, reject) => {
+        const reader = new FileReader();
+        reader.onload = () => resolve(reader.result.substring(reader.result.indexOf(',') + 1));
+        reader.onerror = () => reject(reader.error);
+        reader.readAsDataURL(file);
+    });
+}
+
+// withUnlocked runs action, and if it fails because a key is locked, asks for
+// the passphrase (or token PIN), unlocks the key with unlock and runs action once more.
+// Given keyFile, only that key being locked is handled.
+function withUnlocked(description, unlock, action, keyFile) {
+    return action().then(result => {
+        if (!result || !result.includes(keyFile ? `'${keyFile}' is locked` : 'is locked')) {
+            return result;
+        }
+        const what = result.includes('token PIN') ? 'token PIN' : 'passphrase';
+        const passphrase = prompt(`Enter the ${what} for ${description}:`);
+        if (passphrase === null) {
+            return result;
+        }
+        return unlock(passphrase).then(unlockResult => {
+            if (!unlockResult.toLowerCase().startsWith("success")) {
+                return unlockResult;
+            }
+            logMessage(unlockResult, 'success');
+            refreshCAList();
+            return action();
+        });
+    });
+}
+
+// withUnlockedCA runs action, unlocking the named CA first if its key is locked.
+function withUnlockedCA(caName, action) {
+    return withUnlocked(`CA '${caName}'`, passphrase => window.go.main.App.UnlockCA(caName, passphrase), action);
 }
 
 
@@ -314,7 +1183,14 @@ function checkAdminStatus() {
 }
 
 // populateYearDropdowns fills the expiry dropdowns with options from 1 to 30.
+// Device and CSR expiry default to the selected profile's validity.
 function populateYearDropdowns() {
+    const profileOption = document.createElement('option');
+    profileOption.value = 0;
+    profileOption.textContent = 'Profile default';
+    deviceExpiry.appendChild(profileOption.cloneNode(true));
+    renewExpiry.appendChild(profileOption.cloneNode(true));
+    csrExpiry.appendChild(profileOption);
     for (let i = 1; i <= 30; i++) {
         const caOption = document.createElement('option');
         caOption.value = i;
@@ -325,30 +1201,229 @@ function populateYearDropdowns() {
         deviceOption.value = i;
         deviceOption.textContent = `${i} Year${i > 1 ? 's' : ''}`;
         deviceExpiry.appendChild(deviceOption.cloneNode(true));
+        renewExpiry.appendChild(deviceOption.cloneNode(true));
         csrExpiry.appendChild(deviceOption);
     }
     caExpiry.value = 10;
-    deviceExpiry.value = 2;
-    csrExpiry.value = 2;
+    deviceExpiry.value = 0;
+    renewExpiry.value = 0;
+    csrExpiry.value = 0;
+}
+
+// readSubject collects the subject fields whose ids start with prefix
+function readSubject(prefix) {
+    const value = field => document.getElementById(`${prefix}-subject-${field}`).value.trim();
+    const list = (text, separator) => text.split(separator).map(v => v.trim()).filter(v => v);
+    return {
+        country: value('country'),
+        state: value('state'),
+        locality: value('locality'),
+        org: value('org'),
+        orgUnits: list(value('ou'), ','),
+        email: value('email'),
+        serialNumber: value('serial'),
+        domainComponents: list(value('dc'), '.'),
+    };
+}
+
+// fillSubject sets the subject fields whose ids start with prefix
+function fillSubject(prefix, subject) {
+    const set = (field, text) => document.getElementById(`${prefix}-subject-${field}`).value = text || '';
+    set('country', subject.country);
+    set('state', subject.state);
+    set('locality', subject.locality);
+    set('org', subject.org);
+    set('ou', (subject.orgUnits || []).join(', '));
+    set('email', subject.email);
+    set('serial', subject.serialNumber);
+    set('dc', (subject.domainComponents || []).join('.'));
+}
+
+// refreshProfiles fills the profile dropdowns with the built-in and user-defined profiles
+function refreshProfiles() {
+    window.go.main.App.ListProfiles().then(profiles => {
+        caProfile.innerHTML = '';
+        deviceProfile.innerHTML = '';
+        csrProfile.innerHTML = '';
+        renewProfile.innerHTML = '<option value="">Keep the certificate\'s profile</option>';
+        (profiles || []).forEach(profile => {
+            const option = document.createElement('option');
+            option.value = profile.name;
+            option.textContent = `${profile.description || profile.name}${profile.builtIn ? '' : ' (custom)'}`;
+            if (profile.isCA) {
+                caProfile.appendChild(option);
+                return;
+            }
+            deviceProfile.appendChild(option.cloneNode(true));
+            renewProfile.appendChild(option.cloneNode(true));
+            csrProfile.appendChild(option);
+        });
+        caProfile.value = 'ca';
+        deviceProfile.value = 'tls-server-client';
+        csrProfile.value = 'tls-server-client';
+    }).catch(err => {
+        logMessage(`Error loading certificate profiles: ${err}`, "error");
+    });
+}
+
+// flattenCATree walks the CA tree depth-first, returning each CA with its depth.
+function flattenCATree(nodes, depth = 0, out = []) {
+    (nodes || []).forEach(ca => {
+        out.push({ca, depth});
+        flattenCATree(ca.children, depth + 1, out);
+    });
+    return out;
+}
+
+// refreshOCSPStatus updates the OCSP responder button to match its state
+function refreshOCSPStatus() {
+    window.go.main.App.OCSPResponderAddress().then(address => {
+        btnOcspToggle.textContent = address ? `Stop Responder (${address})` : 'Start Responder';
+        ocspAddress.disabled = !!address;
+    });
+}
+
+// loadCASettings fills the Manage CA form with the selected CA's settings
+function loadCASettings() {
+    const selectedCA = caSelectorManage.value;
+    if (!selectedCA) {
+        return;
+    }
+    window.go.main.App.GetCASettings(selectedCA).then(settings => {
+        caCrlUrl.value = settings.crlUrl || '';
+        caCrlDays.value = settings.crlValidityDays || '';
+        caOcspUrl.value = settings.ocspUrl || '';
+        caOcspDelegated.checked = settings.ocspDelegated;
+        fillSubject('ca-default', settings.defaultSubject || {});
+        fillPolicy(settings.policy || {});
+    });
+}
+
+// readNameConstraints collects the name constraints for a new CA
+function readNameConstraints() {
+    const list = field => document.getElementById(`ca-nc-${field}`).value.split(',').map(v => v.trim()).filter(v => v);
+    return {
+        permittedDns: list('permitted-dns'),
+        excludedDns: list('excluded-dns'),
+        permittedIps: list('permitted-ips'),
+        excludedIps: list('excluded-ips'),
+        permittedEmails: list('permitted-emails'),
+        excludedEmails: list('excluded-emails'),
+        permittedUris: list('permitted-uris'),
+        excludedUris: list('excluded-uris'),
+    };
+}
+
+// showCSRReview shows the decoded CSR and resets the edits for signing it
+function showCSRReview(details) {
+    const lines = [
+        `Subject:    ${details.subject || '(empty)'}`,
+        `Key:        ${details.keyAlgorithm}`,
+        `Signature:  ${details.signatureAlgorithm}${details.signatureValid ? '' : ' (does not verify)'}`,
+        `Key Usage:  ${(details.keyUsage || []).join(', ') || '(not requested)'}`,
+        `Ext. Usage: ${(details.extKeyUsage || []).join(', ') || '(not requested)'}`,
+        `Basic Constraints: ${details.basicConstraints ? (details.isCA ? `CA${details.pathLen >= 0 ? `, path length ${details.pathLen}` : ''}` : 'not a CA') : '(not requested)'}`,
+    ];
+    if (details.hasKey) {
+        lines.push('A private key was pasted with the CSR.');
+    }
+    (details.extensions || []).forEach(ext => {
+        lines.push(`Extension ${ext.name || ext.oid}${ext.critical ? ' (critical)' : ''}: ${ext.value}`);
+    });
+    csrReviewSummary.textContent = lines.join('\n');
+    (details.warnings || []).forEach(warning => {
+        const line = document.createElement('span');
+        line.className = 'error';
+        line.textContent = `\nWarning: ${warning}`;
+        csrReviewSummary.appendChild(line);
+    });
+
+    csrReviewSans.innerHTML = '';
+    (details.sans || []).forEach(san => {
+        const label = document.createElement('label');
+        label.className = 'checkbox-label';
+        const box = document.createElement('input');
+        box.type = 'checkbox';
+        box.value = san;
+        box.checked = true;
+        label.appendChild(box);
+        label.appendChild(document.createTextNode(` ${san}`));
+        csrReviewSans.appendChild(label);
+    });
+    csrAddSans.value = '';
+    csrOverrideSubject.checked = false;
+    csrOverrideCn.value = details.commonName || '';
+    fillSubject('csr', details.subjectFields || {});
+    csrHonourKu.checked = false;
+    csrHonourEku.checked = false;
+    csrHonourBc.checked = false;
+    csrReview.style.display = 'block';
+}
+
+// readPolicy collects the CA policy fields
+function readPolicy() {
+    const value = field => document.getElementById(`ca-policy-${field}`).value.trim();
+    const list = field => value(field).split(',').map(v => v.trim()).filter(v => v);
+    return {
+        maxValidityDays: parseInt(value('max-days')) || 0,
+        allowedDomains: list('allowed-domains'),
+        forbiddenDomains: list('forbidden-domains'),
+        allowedIpRanges: list('allowed-ips'),
+        forbiddenIpRanges: list('forbidden-ips'),
+        forbidWildcards: document.getElementById('ca-policy-no-wildcards').checked,
+        minRsaBits: parseInt(value('min-rsa')) || 0,
+        minEcdsaBits: parseInt(value('min-ecdsa')) || 0,
+        allowedProfiles: list('profiles'),
+    };
+}
+
+// fillPolicy sets the CA policy fields
+function fillPolicy(policy) {
+    const set = (field, text) => document.getElementById(`ca-policy-${field}`).value = text || '';
+    set('max-days', policy.maxValidityDays);
+    set('allowed-domains', (policy.allowedDomains || []).join(', '));
+    set('forbidden-domains', (policy.forbiddenDomains || []).join(', '));
+    set('allowed-ips', (policy.allowedIpRanges || []).join(', '));
+    set('forbidden-ips', (policy.forbiddenIpRanges || []).join(', '));
+    document.getElementById('ca-policy-no-wildcards').checked = policy.forbidWildcards;
+    set('min-rsa', policy.minRsaBits);
+    set('min-ecdsa', policy.minEcdsaBits);
+    set('profiles', (policy.allowedProfiles || []).join(', '));
 }
 
 // refreshCAList calls the Go backend to get the list of CAs and updates the dropdowns
 function refreshCAList() {
     logMessage("Refreshing CA list...");
-    window.go.main.App.ListCAs().then(cas => {
+    window.go.main.App.ListCAs().then(tree => {
         caSelectorDevice.innerHTML = '';
         caSelectorInstall.innerHTML = '';
         caSelectorCsr.innerHTML = '';
-
-        if (cas && cas.length > 0) {
-            cas.forEach(caName => {
+        caSelectorManage.innerHTML = '';
+        caSelectorTruststore.innerHTML = '';
+        verifyCa.innerHTML = '<option value="">Every CA in the store</option>';
+        caParent.innerHTML = '<option value="">None (self-signed root)</option>';
+
+        const cas = flattenCATree(tree);
+        if (cas.length > 0) {
+            cas.forEach(({ca, depth}) => {
                 const option = document.createElement('option');
-                option.value = caName;
-                option.textContent = caName;
-                caSelectorDevice.appendChild(option.cloneNode(true));
+                option.value = ca.name;
+                option.textContent = `${'\u2014 '.repeat(depth)}${ca.name}${ca.hasKey ? '' : ' (offline)'}${ca.onToken ? ' (token)' : ''}${ca.constrained ? ' (constrained)' : ''}${ca.locked ? ' (locked)' : ''}`;
                 caSelectorInstall.appendChild(option.cloneNode(true));
-                caSelectorCsr.appendChild(option);
+                caSelectorManage.appendChild(option.cloneNode(true));
+                caSelectorTruststore.appendChild(option.cloneNode(true));
+                verifyCa.appendChild(option.cloneNode(true));
+                // Only CAs with a key on disk can sign anything
+                if (!ca.hasKey) {
+                    option.disabled = true;
+                }
+                caSelectorDevice.appendChild(option.cloneNode(true));
+                caSelectorCsr.appendChild(option.cloneNode(true));
+                if (ca.pathLen !== 0) {
+                    caParent.appendChild(option);
+                }
             });
+            loadCASettings();
             logMessage("CA list updated.");
             createCADetails.open = false;
         } else {
@@ -364,16 +1439,43 @@ function refreshCAList() {
 // refreshCertList calls the Go backend to get the list of device certs and updates the UI
 function refreshCertList() {
     logMessage("Refreshing device certificate list...");
-    window.go.main.App.ListCAs().then(cas => { // We need the list of CAs to enable/disable the export button
+    window.go.main.App.ListCAs().then(tree => { // We need the list of CAs to enable/disable the export button
+        const cas = flattenCATree(tree).map(({ca}) => ca.name);
         window.go.main.App.ListCerts().then(certs => {
             certList.innerHTML = ''; // Clear the list
+            certRecords = certs || [];
+            const renewing = renewCert.value;
+            [exportCert, ksCert, verifyCert, renewCert].forEach(select => {
+                const previous = select.value;
+                select.innerHTML = '';
+                (certs || []).forEach(item => {
+                    const option = document.createElement('option');
+                    option.value = item.name;
+                    option.textContent = item.name;
+                    select.appendChild(option);
+                });
+                if ((certs || []).some(item => item.name === previous)) {
+                    select.value = previous;
+                }
+            });
+            if (renewCert.value !== renewing || !renewSans.hasChildNodes()) {
+                renewCertSelected();
+            }
             if (certs && certs.length > 0) {
-                certs.forEach(certName => {
+                certs.forEach(item => {
+                    const certName = item.name;
                     const li = document.createElement('li');
                     
                     const span = document.createElement('span');
                     span.textContent = certName;
                     span.className = 'cert-name';
+                    span.title = `${item.subject}\nSerial: ${item.serial}\nKey: ${item.keyAlgorithm}`;
+                    if (item.status && item.status !== 'valid') {
+                        const badge = document.createElement('span');
+                        badge.className = `status-badge status-${item.status}`;
+                        badge.textContent = item.status === 'onHold' ? 'on hold' : item.status;
+                        span.appendChild(badge);
+                    }
                     
                     const actionsDiv = document.createElement('div');
                     actionsDiv.className = 'cert-actions';
@@ -388,7 +1490,7 @@ function refreshCertList() {
                     exportBtn.className = 'btn-secondary';
                     exportBtn.onclick = () => exportPfx(certName);
                     // Disable export if the issuing CA doesn't exist anymore
-                    const issuerName = certName.split('_signed-by_')[1].replace('.pem', '');
+                    const issuerName = item.issuer;
                     if (!cas.includes(issuerName)) {
                         exportBtn.disabled = true;
                         exportBtn.title = `Issuing CA '${issuerName}' not found.`;
@@ -401,8 +1503,40 @@ function refreshCertList() {
                     deleteBtn.title = `Delete ${certName}`;
                     deleteBtn.onclick = () => deleteCert(certName);
 
+                    const stapleBtn = document.createElement('button');
+                    stapleBtn.textContent = 'OCSP';
+                    stapleBtn.className = 'btn-secondary';
+                    stapleBtn.title = 'Write a pre-signed OCSP response file for OCSP stapling';
+                    stapleBtn.onclick = () => writeOCSPStaple(certName, item.issuer);
+
+                    const renewBtn = document.createElement('button');
+                    renewBtn.textContent = 'Renew';
+                    renewBtn.className = 'btn-secondary';
+                    renewBtn.onclick = () => renewCertFor(certName);
+                    if (item.supersededBy) {
+                        renewBtn.disabled = true;
+                        renewBtn.title = `Renewed as '${item.supersededBy}'`;
+                    } else if (!cas.includes(item.issuer)) {
+                        renewBtn.disabled = true;
+                        renewBtn.title = `Issuing CA '${item.issuer}' not found.`;
+                    }
+
+                    const revokeBtn = document.createElement('button');
+                    revokeBtn.className = 'btn-secondary';
+                    if (item.status === 'onHold') {
+                        revokeBtn.textContent = 'Release';
+                        revokeBtn.onclick = () => releaseCertHold(certName);
+                    } else {
+                        revokeBtn.textContent = 'Revoke';
+                        revokeBtn.disabled = item.status === 'revoked';
+                        revokeBtn.onclick = () => revokeCert(certName);
+                    }
+
                     actionsDiv.appendChild(inspectBtn);
                     actionsDiv.appendChild(exportBtn);
+                    actionsDiv.appendChild(renewBtn);
+                    actionsDiv.appendChild(stapleBtn);
+                    actionsDiv.appendChild(revokeBtn);
                     actionsDiv.appendChild(deleteBtn);
                     li.appendChild(span);
                     li.appendChild(actionsDiv);
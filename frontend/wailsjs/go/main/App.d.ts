@@ -2,28 +2,94 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function ChangeCAPassphrase(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function ChangeCertKeyPassphrase(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function CompleteCSR(arg1:string,arg2:string):Promise<string>;
+
 export function CreateCA(arg1:main.CAInput):Promise<string>;
 
-export function CreateCert(arg1:string,arg2:string,arg3:string,arg4:number):Promise<string>;
+export function CreateCert(arg1:main.CertInput):Promise<string>;
+
+export function CreateIntermediateCA(arg1:main.CAInput,arg2:string):Promise<string>;
+
+export function DecodeArtifact(arg1:main.DecodeInput):Promise<main.DecodeResult>;
 
 export function DeleteCA(arg1:string):Promise<string>;
 
 export function DeleteCert(arg1:string):Promise<string>;
 
-export function ExportToPFX(arg1:string,arg2:string):Promise<string>;
+export function DeletePendingRequest(arg1:string):Promise<string>;
+
+export function ExportCert(arg1:main.ExportInput):Promise<string>;
+
+export function ExportKeyStore(arg1:main.KeyStoreInput):Promise<string>;
+
+export function ExportToPFX(arg1:main.PFXInput):Promise<string>;
+
+export function ExportTrustStore(arg1:main.KeyStoreInput):Promise<string>;
+
+export function GenerateCRL(arg1:string,arg2:number):Promise<string>;
+
+export function GenerateCSR(arg1:main.CSRInput):Promise<string>;
 
 export function GenerateInstaller(arg1:string):Promise<string>;
 
+export function GetCASettings(arg1:string):Promise<main.CASettings>;
+
+export function ImportCertificate(arg1:main.ImportInput):Promise<string>;
+
+export function InspectCA(arg1:string):Promise<main.CertDetails>;
+
 export function InspectCert(arg1:string):Promise<main.CertDetails>;
 
 export function InstallCA(arg1:string):Promise<string>;
 
 export function IsAdmin():Promise<boolean>;
 
-export function ListCAs():Promise<Array<string>>;
+export function ListCAs():Promise<Array<main.CANode>>;
+
+export function ListCerts():Promise<Array<main.InventoryRecord>>;
+
+export function ListPendingRequests():Promise<Array<main.PendingRequest>>;
+
+export function ListProfiles():Promise<Array<main.CertProfile>>;
 
-export function ListCerts():Promise<Array<string>>;
+export function ListRevocationReasons():Promise<Array<string>>;
+
+export function LockKeys():Promise<string>;
+
+export function OCSPResponderAddress():Promise<string>;
 
 export function OpenOutputDir():Promise<string>;
 
-export function SignCSR(arg1:string,arg2:string,arg3:number):Promise<string>;
+export function ProbeTLS(arg1:main.ProbeInput):Promise<main.ProbeResult>;
+
+export function RebuildInventory():Promise<string>;
+
+export function ReleaseCertHold(arg1:string):Promise<string>;
+
+export function RenewCert(arg1:main.RenewInput):Promise<string>;
+
+export function ReviewCSR(arg1:string):Promise<main.CSRDetails>;
+
+export function RevokeCert(arg1:string,arg2:string):Promise<string>;
+
+export function SetCASettings(arg1:string,arg2:main.CASettings):Promise<string>;
+
+export function SignCSR(arg1:string,arg2:string,arg3:number,arg4:string,arg5:string):Promise<string>;
+
+export function SignReviewedCSR(arg1:main.CSRSignInput):Promise<string>;
+
+export function StartOCSPResponder(arg1:string):Promise<string>;
+
+export function StopOCSPResponder():Promise<string>;
+
+export function UnlockCA(arg1:string,arg2:string):Promise<string>;
+
+export function UnlockCertKey(arg1:string,arg2:string):Promise<string>;
+
+export function VerifyCert(arg1:main.VerifyInput):Promise<main.VerifyReport>;
+
+export function WriteOCSPStaple(arg1:string):Promise<string>;
@@ -2,12 +2,32 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function ChangeCAPassphrase(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ChangeCAPassphrase'](arg1, arg2, arg3);
+}
+
+export function ChangeCertKeyPassphrase(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ChangeCertKeyPassphrase'](arg1, arg2, arg3);
+}
+
+export function CompleteCSR(arg1, arg2) {
+  return window['go']['main']['App']['CompleteCSR'](arg1, arg2);
+}
+
 export function CreateCA(arg1) {
   return window['go']['main']['App']['CreateCA'](arg1);
 }
 
-export function CreateCert(arg1, arg2, arg3, arg4) {
-  return window['go']['main']['App']['CreateCert'](arg1, arg2, arg3, arg4);
+export function CreateCert(arg1) {
+  return window['go']['main']['App']['CreateCert'](arg1);
+}
+
+export function CreateIntermediateCA(arg1, arg2) {
+  return window['go']['main']['App']['CreateIntermediateCA'](arg1, arg2);
+}
+
+export function DecodeArtifact(arg1) {
+  return window['go']['main']['App']['DecodeArtifact'](arg1);
 }
 
 export function DeleteCA(arg1) {
@@ -18,14 +38,50 @@ export function DeleteCert(arg1) {
   return window['go']['main']['App']['DeleteCert'](arg1);
 }
 
-export function ExportToPFX(arg1, arg2) {
-  return window['go']['main']['App']['ExportToPFX'](arg1, arg2);
+export function DeletePendingRequest(arg1) {
+  return window['go']['main']['App']['DeletePendingRequest'](arg1);
+}
+
+export function ExportCert(arg1) {
+  return window['go']['main']['App']['ExportCert'](arg1);
+}
+
+export function ExportKeyStore(arg1) {
+  return window['go']['main']['App']['ExportKeyStore'](arg1);
+}
+
+export function ExportToPFX(arg1) {
+  return window['go']['main']['App']['ExportToPFX'](arg1);
+}
+
+export function ExportTrustStore(arg1) {
+  return window['go']['main']['App']['ExportTrustStore'](arg1);
+}
+
+export function GenerateCRL(arg1, arg2) {
+  return window['go']['main']['App']['GenerateCRL'](arg1, arg2);
+}
+
+export function GenerateCSR(arg1) {
+  return window['go']['main']['App']['GenerateCSR'](arg1);
 }
 
 export function GenerateInstaller(arg1) {
   return window['go']['main']['App']['GenerateInstaller'](arg1);
 }
 
+export function GetCASettings(arg1) {
+  return window['go']['main']['App']['GetCASettings'](arg1);
+}
+
+export function ImportCertificate(arg1) {
+  return window['go']['main']['App']['ImportCertificate'](arg1);
+}
+
+export function InspectCA(arg1) {
+  return window['go']['main']['App']['InspectCA'](arg1);
+}
+
 export function InspectCert(arg1) {
   return window['go']['main']['App']['InspectCert'](arg1);
 }
@@ -46,10 +102,86 @@ export function ListCerts() {
   return window['go']['main']['App']['ListCerts']();
 }
 
+export function ListPendingRequests() {
+  return window['go']['main']['App']['ListPendingRequests']();
+}
+
+export function ListProfiles() {
+  return window['go']['main']['App']['ListProfiles']();
+}
+
+export function ListRevocationReasons() {
+  return window['go']['main']['App']['ListRevocationReasons']();
+}
+
+export function LockKeys() {
+  return window['go']['main']['App']['LockKeys']();
+}
+
+export function OCSPResponderAddress() {
+  return window['go']['main']['App']['OCSPResponderAddress']();
+}
+
 export function OpenOutputDir() {
   return window['go']['main']['App']['OpenOutputDir']();
 }
 
-export function SignCSR(arg1, arg2, arg3) {
-  return window['go']['main']['App']['SignCSR'](arg1, arg2, arg3);
+export function ProbeTLS(arg1) {
+  return window['go']['main']['App']['ProbeTLS'](arg1);
+}
+
+export function RebuildInventory() {
+  return window['go']['main']['App']['RebuildInventory']();
+}
+
+export function ReleaseCertHold(arg1) {
+  return window['go']['main']['App']['ReleaseCertHold'](arg1);
+}
+
+export function RenewCert(arg1) {
+  return window['go']['main']['App']['RenewCert'](arg1);
+}
+
+export function ReviewCSR(arg1) {
+  return window['go']['main']['App']['ReviewCSR'](arg1);
+}
+
+export function RevokeCert(arg1, arg2) {
+  return window['go']['main']['App']['RevokeCert'](arg1, arg2);
+}
+
+export function SetCASettings(arg1, arg2) {
+  return window['go']['main']['App']['SetCASettings'](arg1, arg2);
+}
+
+export function SignCSR(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['SignCSR'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function SignReviewedCSR(arg1) {
+  return window['go']['main']['App']['SignReviewedCSR'](arg1);
+}
+
+export function StartOCSPResponder(arg1) {
+  return window['go']['main']['App']['StartOCSPResponder'](arg1);
+}
+
+export function StopOCSPResponder() {
+  return window['go']['main']['App']['StopOCSPResponder']();
+}
+
+export function UnlockCA(arg1, arg2) {
+  return window['go']['main']['App']['UnlockCA'](arg1, arg2);
+}
+
+export function UnlockCertKey(arg1, arg2) {
+  return window['go']['main']['App']['UnlockCertKey'](arg1, arg2);
+}
+
+export function VerifyCert(arg1) {
+  return window['go']['main']['App']['VerifyCert'](arg1);
+}
+
+export function WriteOCSPStaple(arg1) {
+  return window['go']['main']['App']['WriteOCSPStaple'](arg1);
 }
@@ -1,5 +1,47 @@
 export namespace main {
 	
+	export class NameConstraintsInput {
+	    permittedDns: string[];
+	    excludedDns: string[];
+	    permittedIps: string[];
+	    excludedIps: string[];
+	    permittedEmails: string[];
+	    excludedEmails: string[];
+	    permittedUris: string[];
+	    excludedUris: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new NameConstraintsInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.permittedDns = source["permittedDns"];
+	        this.excludedDns = source["excludedDns"];
+	        this.permittedIps = source["permittedIps"];
+	        this.excludedIps = source["excludedIps"];
+	        this.permittedEmails = source["permittedEmails"];
+	        this.excludedEmails = source["excludedEmails"];
+	        this.permittedUris = source["permittedUris"];
+	        this.excludedUris = source["excludedUris"];
+	    }
+	}
+	export class TokenInput {
+	    module: string;
+	    token: string;
+	    pin: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TokenInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.module = source["module"];
+	        this.token = source["token"];
+	        this.pin = source["pin"];
+	    }
+	}
 	export class CAInput {
 	    country: string;
 	    state: string;
@@ -7,6 +49,12 @@ export namespace main {
 	    org: string;
 	    commonName: string;
 	    expiryDays: number;
+	    keyAlgorithm: string;
+	    pathLen: number;
+	    profile: string;
+	    passphrase: string;
+	    token: TokenInput;
+	    constraints: NameConstraintsInput;
 	
 	    static createFrom(source: any = {}) {
 	        return new CAInput(source);
@@ -20,7 +68,418 @@ export namespace main {
 	        this.org = source["org"];
 	        this.commonName = source["commonName"];
 	        this.expiryDays = source["expiryDays"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.pathLen = source["pathLen"];
+	        this.profile = source["profile"];
+	        this.passphrase = source["passphrase"];
+	        this.token = this.convertValues(source["token"], TokenInput);
+	        this.constraints = this.convertValues(source["constraints"], NameConstraintsInput);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CANode {
+	    name: string;
+	    commonName: string;
+	    parent: string;
+	    isRoot: boolean;
+	    hasKey: boolean;
+	    encrypted: boolean;
+	    onToken: boolean;
+	    locked: boolean;
+	    pathLen: number;
+	    constrained: boolean;
+	    validUntil: string;
+	    children: CANode[];
+	
+	    static createFrom(source: any = {}) {
+	        return new CANode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.commonName = source["commonName"];
+	        this.parent = source["parent"];
+	        this.isRoot = source["isRoot"];
+	        this.hasKey = source["hasKey"];
+	        this.encrypted = source["encrypted"];
+	        this.onToken = source["onToken"];
+	        this.locked = source["locked"];
+	        this.pathLen = source["pathLen"];
+	        this.constrained = source["constrained"];
+	        this.validUntil = source["validUntil"];
+	        this.children = this.convertValues(source["children"], CANode);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CAPolicy {
+	    maxValidityDays: number;
+	    allowedDomains: string[];
+	    forbiddenDomains: string[];
+	    allowedIpRanges: string[];
+	    forbiddenIpRanges: string[];
+	    forbidWildcards: boolean;
+	    minRsaBits: number;
+	    minEcdsaBits: number;
+	    allowedProfiles: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new CAPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxValidityDays = source["maxValidityDays"];
+	        this.allowedDomains = source["allowedDomains"];
+	        this.forbiddenDomains = source["forbiddenDomains"];
+	        this.allowedIpRanges = source["allowedIpRanges"];
+	        this.forbiddenIpRanges = source["forbiddenIpRanges"];
+	        this.forbidWildcards = source["forbidWildcards"];
+	        this.minRsaBits = source["minRsaBits"];
+	        this.minEcdsaBits = source["minEcdsaBits"];
+	        this.allowedProfiles = source["allowedProfiles"];
+	    }
+	}
+	export class SubjectInput {
+	    country: string;
+	    state: string;
+	    locality: string;
+	    org: string;
+	    orgUnits: string[];
+	    email: string;
+	    serialNumber: string;
+	    domainComponents: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SubjectInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.country = source["country"];
+	        this.state = source["state"];
+	        this.locality = source["locality"];
+	        this.org = source["org"];
+	        this.orgUnits = source["orgUnits"];
+	        this.email = source["email"];
+	        this.serialNumber = source["serialNumber"];
+	        this.domainComponents = source["domainComponents"];
+	    }
+	}
+	export class CASettings {
+	    crlUrl: string;
+	    crlValidityDays: number;
+	    crlNumber: number;
+	    ocspUrl: string;
+	    ocspDelegated: boolean;
+	    defaultSubject: SubjectInput;
+	    policy: CAPolicy;
+	
+	    static createFrom(source: any = {}) {
+	        return new CASettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.crlUrl = source["crlUrl"];
+	        this.crlValidityDays = source["crlValidityDays"];
+	        this.crlNumber = source["crlNumber"];
+	        this.ocspUrl = source["ocspUrl"];
+	        this.ocspDelegated = source["ocspDelegated"];
+	        this.defaultSubject = this.convertValues(source["defaultSubject"], SubjectInput);
+	        this.policy = this.convertValues(source["policy"], CAPolicy);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CertExtension {
+	    oid: string;
+	    name: string;
+	    critical: boolean;
+	    value: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CertExtension(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.oid = source["oid"];
+	        this.name = source["name"];
+	        this.critical = source["critical"];
+	        this.value = source["value"];
+	    }
+	}
+	export class CRLEntry {
+	    serial: string;
+	    revokedAt: string;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CRLEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.serial = source["serial"];
+	        this.revokedAt = source["revokedAt"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class CRLDetails {
+	    issuer: string;
+	    number: string;
+	    thisUpdate: string;
+	    nextUpdate: string;
+	    expired: boolean;
+	    signatureAlgorithm: string;
+	    issuerName: string;
+	    signatureValid: boolean;
+	    entries: CRLEntry[];
+	    extensions: CertExtension[];
+	
+	    static createFrom(source: any = {}) {
+	        return new CRLDetails(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.issuer = source["issuer"];
+	        this.number = source["number"];
+	        this.thisUpdate = source["thisUpdate"];
+	        this.nextUpdate = source["nextUpdate"];
+	        this.expired = source["expired"];
+	        this.signatureAlgorithm = source["signatureAlgorithm"];
+	        this.issuerName = source["issuerName"];
+	        this.signatureValid = source["signatureValid"];
+	        this.entries = this.convertValues(source["entries"], CRLEntry);
+	        this.extensions = this.convertValues(source["extensions"], CertExtension);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class CSRDetails {
+	    subject: string;
+	    commonName: string;
+	    subjectFields: SubjectInput;
+	    sans: string[];
+	    keyAlgorithm: string;
+	    keyBits: number;
+	    signatureAlgorithm: string;
+	    signatureValid: boolean;
+	    keyUsage: string[];
+	    extKeyUsage: string[];
+	    basicConstraints: boolean;
+	    isCA: boolean;
+	    pathLen: number;
+	    extensions: CertExtension[];
+	    hasKey: boolean;
+	    warnings: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new CSRDetails(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.subject = source["subject"];
+	        this.commonName = source["commonName"];
+	        this.subjectFields = this.convertValues(source["subjectFields"], SubjectInput);
+	        this.sans = source["sans"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.keyBits = source["keyBits"];
+	        this.signatureAlgorithm = source["signatureAlgorithm"];
+	        this.signatureValid = source["signatureValid"];
+	        this.keyUsage = source["keyUsage"];
+	        this.extKeyUsage = source["extKeyUsage"];
+	        this.basicConstraints = source["basicConstraints"];
+	        this.isCA = source["isCA"];
+	        this.pathLen = source["pathLen"];
+	        this.extensions = this.convertValues(source["extensions"], CertExtension);
+	        this.hasKey = source["hasKey"];
+	        this.warnings = source["warnings"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CSRInput {
+	    commonName: string;
+	    sans: string;
+	    keyAlgorithm: string;
+	    subject: SubjectInput;
+	    isCA: boolean;
+	    pathLen: number;
+	    keyPassphrase: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CSRInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.commonName = source["commonName"];
+	        this.sans = source["sans"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.subject = this.convertValues(source["subject"], SubjectInput);
+	        this.isCA = source["isCA"];
+	        this.pathLen = source["pathLen"];
+	        this.keyPassphrase = source["keyPassphrase"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CSRSignInput {
+	    csr: string;
+	    caName: string;
+	    expiryDays: number;
+	    profile: string;
+	    keyPassphrase: string;
+	    overrideSubject: boolean;
+	    commonName: string;
+	    subject: SubjectInput;
+	    removeSans: string[];
+	    addSans: string;
+	    honourKeyUsage: boolean;
+	    honourExtKeyUsage: boolean;
+	    honourBasicConstraints: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new CSRSignInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.csr = source["csr"];
+	        this.caName = source["caName"];
+	        this.expiryDays = source["expiryDays"];
+	        this.profile = source["profile"];
+	        this.keyPassphrase = source["keyPassphrase"];
+	        this.overrideSubject = source["overrideSubject"];
+	        this.commonName = source["commonName"];
+	        this.subject = this.convertValues(source["subject"], SubjectInput);
+	        this.removeSans = source["removeSans"];
+	        this.addSans = source["addSans"];
+	        this.honourKeyUsage = source["honourKeyUsage"];
+	        this.honourExtKeyUsage = source["honourExtKeyUsage"];
+	        this.honourBasicConstraints = source["honourBasicConstraints"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class CertDetails {
 	    subject: string;
@@ -30,6 +489,34 @@ export namespace main {
 	    serialNumber: string;
 	    ipAddresses: string[];
 	    dnsNames: string[];
+	    emails: string[];
+	    uris: string[];
+	    upns: string[];
+	    status: string;
+	    revoked: boolean;
+	    revokeReason: string;
+	    revokedAt: string;
+	    subjectDn: string;
+	    issuerDn: string;
+	    selfSigned: boolean;
+	    keyAlgorithm: string;
+	    keyBits: number;
+	    signatureAlgorithm: string;
+	    keyUsage: string[];
+	    extKeyUsage: string[];
+	    isCA: boolean;
+	    pathLen: number;
+	    subjectKeyId: string;
+	    authorityKeyId: string;
+	    crlUrls: string[];
+	    ocspUrls: string[];
+	    caIssuerUrls: string[];
+	    policies: string[];
+	    extensions: CertExtension[];
+	    sha1Fingerprint: string;
+	    sha256Fingerprint: string;
+	    spkiPin: string;
+	    text: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new CertDetails(source);
@@ -44,7 +531,650 @@ export namespace main {
 	        this.serialNumber = source["serialNumber"];
 	        this.ipAddresses = source["ipAddresses"];
 	        this.dnsNames = source["dnsNames"];
+	        this.emails = source["emails"];
+	        this.uris = source["uris"];
+	        this.upns = source["upns"];
+	        this.status = source["status"];
+	        this.revoked = source["revoked"];
+	        this.revokeReason = source["revokeReason"];
+	        this.revokedAt = source["revokedAt"];
+	        this.subjectDn = source["subjectDn"];
+	        this.issuerDn = source["issuerDn"];
+	        this.selfSigned = source["selfSigned"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.keyBits = source["keyBits"];
+	        this.signatureAlgorithm = source["signatureAlgorithm"];
+	        this.keyUsage = source["keyUsage"];
+	        this.extKeyUsage = source["extKeyUsage"];
+	        this.isCA = source["isCA"];
+	        this.pathLen = source["pathLen"];
+	        this.subjectKeyId = source["subjectKeyId"];
+	        this.authorityKeyId = source["authorityKeyId"];
+	        this.crlUrls = source["crlUrls"];
+	        this.ocspUrls = source["ocspUrls"];
+	        this.caIssuerUrls = source["caIssuerUrls"];
+	        this.policies = source["policies"];
+	        this.extensions = this.convertValues(source["extensions"], CertExtension);
+	        this.sha1Fingerprint = source["sha1Fingerprint"];
+	        this.sha256Fingerprint = source["sha256Fingerprint"];
+	        this.spkiPin = source["spkiPin"];
+	        this.text = source["text"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class CertInput {
+	    commonName: string;
+	    sans: string;
+	    caName: string;
+	    expiryDays: number;
+	    keyAlgorithm: string;
+	    profile: string;
+	    subject: SubjectInput;
+	    keyPassphrase: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CertInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.commonName = source["commonName"];
+	        this.sans = source["sans"];
+	        this.caName = source["caName"];
+	        this.expiryDays = source["expiryDays"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.profile = source["profile"];
+	        this.subject = this.convertValues(source["subject"], SubjectInput);
+	        this.keyPassphrase = source["keyPassphrase"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CertProfile {
+	    name: string;
+	    description: string;
+	    keyUsage: string[];
+	    extKeyUsage: string[];
+	    criticalExtKeyUsage: boolean;
+	    isCA: boolean;
+	    basicConstraints: boolean;
+	    validityDays: number;
+	    ocspNoCheck: boolean;
+	    builtIn: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new CertProfile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.keyUsage = source["keyUsage"];
+	        this.extKeyUsage = source["extKeyUsage"];
+	        this.criticalExtKeyUsage = source["criticalExtKeyUsage"];
+	        this.isCA = source["isCA"];
+	        this.basicConstraints = source["basicConstraints"];
+	        this.validityDays = source["validityDays"];
+	        this.ocspNoCheck = source["ocspNoCheck"];
+	        this.builtIn = source["builtIn"];
+	    }
+	}
+	export class DecodeInput {
+	    text: string;
+	    path: string;
+	    fileData: string;
+	    password: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DecodeInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.text = source["text"];
+	        this.path = source["path"];
+	        this.fileData = source["fileData"];
+	        this.password = source["password"];
+	    }
+	}
+	export class KeyDetails {
+	    format: string;
+	    encrypted: boolean;
+	    locked: boolean;
+	    algorithm: string;
+	    bits: number;
+	    spkiPin: string;
+	    matches: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new KeyDetails(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.format = source["format"];
+	        this.encrypted = source["encrypted"];
+	        this.locked = source["locked"];
+	        this.algorithm = source["algorithm"];
+	        this.bits = source["bits"];
+	        this.spkiPin = source["spkiPin"];
+	        this.matches = source["matches"];
+	    }
+	}
+	export class DecodedObject {
+	    kind: string;
+	    source: string;
+	    storeName: string;
+	    certificate?: CertDetails;
+	    csr?: CSRDetails;
+	    crl?: CRLDetails;
+	    key?: KeyDetails;
+	
+	    static createFrom(source: any = {}) {
+	        return new DecodedObject(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.source = source["source"];
+	        this.storeName = source["storeName"];
+	        this.certificate = this.convertValues(source["certificate"], CertDetails);
+	        this.csr = this.convertValues(source["csr"], CSRDetails);
+	        this.crl = this.convertValues(source["crl"], CRLDetails);
+	        this.key = this.convertValues(source["key"], KeyDetails);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DecodeResult {
+	    encoding: string;
+	    objects: DecodedObject[];
+	    notes: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DecodeResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.encoding = source["encoding"];
+	        this.objects = this.convertValues(source["objects"], DecodedObject);
+	        this.notes = source["notes"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ExportInput {
+	    certName: string;
+	    format: string;
+	    includeRoot: boolean;
+	    password: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.certName = source["certName"];
+	        this.format = source["format"];
+	        this.includeRoot = source["includeRoot"];
+	        this.password = source["password"];
+	    }
+	}
+	export class ImportInput {
+	    certFile: string;
+	    keyFile: string;
+	    password: string;
+	    name: string;
+	    passphrase: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.certFile = source["certFile"];
+	        this.keyFile = source["keyFile"];
+	        this.password = source["password"];
+	        this.name = source["name"];
+	        this.passphrase = source["passphrase"];
+	    }
+	}
+	export class InventoryRecord {
+	    name: string;
+	    kind: string;
+	    serial: string;
+	    subject: string;
+	    commonName: string;
+	    sans: string[];
+	    issuer: string;
+	    issuerDn: string;
+	    // Go type: time
+	    notBefore: any;
+	    // Go type: time
+	    notAfter: any;
+	    keyAlgorithm: string;
+	    keyFingerprint: string;
+	    fingerprint: string;
+	    status: string;
+	    certFile: string;
+	    keyFile: string;
+	    // Go type: time
+	    createdAt: any;
+	    supersededBy: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new InventoryRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.kind = source["kind"];
+	        this.serial = source["serial"];
+	        this.subject = source["subject"];
+	        this.commonName = source["commonName"];
+	        this.sans = source["sans"];
+	        this.issuer = source["issuer"];
+	        this.issuerDn = source["issuerDn"];
+	        this.notBefore = this.convertValues(source["notBefore"], null);
+	        this.notAfter = this.convertValues(source["notAfter"], null);
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.keyFingerprint = source["keyFingerprint"];
+	        this.fingerprint = source["fingerprint"];
+	        this.status = source["status"];
+	        this.certFile = source["certFile"];
+	        this.keyFile = source["keyFile"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.supersededBy = source["supersededBy"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class KeyStoreInput {
+	    certName: string;
+	    caName: string;
+	    format: string;
+	    alias: string;
+	    storePassword: string;
+	    keyPassword: string;
+	    includeChain: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new KeyStoreInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.certName = source["certName"];
+	        this.caName = source["caName"];
+	        this.format = source["format"];
+	        this.alias = source["alias"];
+	        this.storePassword = source["storePassword"];
+	        this.keyPassword = source["keyPassword"];
+	        this.includeChain = source["includeChain"];
+	    }
+	}
+	
+	export class PFXInput {
+	    certName: string;
+	    password: string;
+	    encryption: string;
+	    friendlyName: string;
+	    leafOnly: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PFXInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.certName = source["certName"];
+	        this.password = source["password"];
+	        this.encryption = source["encryption"];
+	        this.friendlyName = source["friendlyName"];
+	        this.leafOnly = source["leafOnly"];
+	    }
+	}
+	export class PendingRequest {
+	    name: string;
+	    commonName: string;
+	    isCA: boolean;
+	    keyAlgorithm: string;
+	    csrFile: string;
+	    keyFile: string;
+	    csr: string;
+	    // Go type: time
+	    createdAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PendingRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.commonName = source["commonName"];
+	        this.isCA = source["isCA"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.csrFile = source["csrFile"];
+	        this.keyFile = source["keyFile"];
+	        this.csr = source["csr"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProbeInput {
+	    address: string;
+	    serverName: string;
+	    startTls: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbeInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.address = source["address"];
+	        this.serverName = source["serverName"];
+	        this.startTls = source["startTls"];
+	    }
+	}
+	export class ProbedCert {
+	    details?: CertDetails;
+	    storeName: string;
+	    data: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbedCert(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.details = this.convertValues(source["details"], CertDetails);
+	        this.storeName = source["storeName"];
+	        this.data = source["data"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProbeResult {
+	    address: string;
+	    serverName: string;
+	    tlsVersion: string;
+	    cipherSuite: string;
+	    chain: ProbedCert[];
+	    trusted: boolean;
+	    trustedBy: string;
+	    verifiedChain: string[];
+	    hostnameOk: boolean;
+	    issues: string[];
+	    warnings: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbeResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.address = source["address"];
+	        this.serverName = source["serverName"];
+	        this.tlsVersion = source["tlsVersion"];
+	        this.cipherSuite = source["cipherSuite"];
+	        this.chain = this.convertValues(source["chain"], ProbedCert);
+	        this.trusted = source["trusted"];
+	        this.trustedBy = source["trustedBy"];
+	        this.verifiedChain = source["verifiedChain"];
+	        this.hostnameOk = source["hostnameOk"];
+	        this.issues = source["issues"];
+	        this.warnings = source["warnings"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class RenewInput {
+	    certName: string;
+	    profile: string;
+	    expiryDays: number;
+	    reuseKey: boolean;
+	    keyAlgorithm: string;
+	    keyPassphrase: string;
+	    removeSans: string[];
+	    addSans: string;
+	    revokeOld: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RenewInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.certName = source["certName"];
+	        this.profile = source["profile"];
+	        this.expiryDays = source["expiryDays"];
+	        this.reuseKey = source["reuseKey"];
+	        this.keyAlgorithm = source["keyAlgorithm"];
+	        this.keyPassphrase = source["keyPassphrase"];
+	        this.removeSans = source["removeSans"];
+	        this.addSans = source["addSans"];
+	        this.revokeOld = source["revokeOld"];
+	    }
+	}
+	
+	
+	export class VerifyCheck {
+	    name: string;
+	    passed: boolean;
+	    detail: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new VerifyCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.passed = source["passed"];
+	        this.detail = source["detail"];
+	    }
+	}
+	export class VerifyInput {
+	    certName: string;
+	    caName: string;
+	    hostname: string;
+	    usage: string;
+	    at: string;
+	    keyPath: string;
+	    keyPassword: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new VerifyInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.certName = source["certName"];
+	        this.caName = source["caName"];
+	        this.hostname = source["hostname"];
+	        this.usage = source["usage"];
+	        this.at = source["at"];
+	        this.keyPath = source["keyPath"];
+	        this.keyPassword = source["keyPassword"];
+	    }
+	}
+	export class VerifyReport {
+	    valid: boolean;
+	    at: string;
+	    chain: string[];
+	    checks: VerifyCheck[];
+	
+	    static createFrom(source: any = {}) {
+	        return new VerifyReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.valid = source["valid"];
+	        this.at = source["at"];
+	        this.chain = source["chain"];
+	        this.checks = this.convertValues(source["checks"], VerifyCheck);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CANode describes a CA and the intermediate CAs it has signed.
+type CANode struct {
+	Name        string   `json:"name"`
+	CommonName  string   `json:"commonName"`
+	Parent      string   `json:"parent"`
+	IsRoot      bool     `json:"isRoot"`
+	HasKey      bool     `json:"hasKey"`
+	Encrypted   bool     `json:"encrypted"`   // The key is protected by a passphrase
+	OnToken     bool     `json:"onToken"`     // The key is held on a PKCS#11 token
+	Locked      bool     `json:"locked"`      // The key is encrypted or on a token, and not unlocked in this session
+	PathLen     int      `json:"pathLen"`     // -1 when unconstrained
+	Constrained bool     `json:"constrained"` // The CA carries X.509 Name Constraints
+	ValidUntil  string   `json:"validUntil"`
+	Children    []CANode `json:"children"`
+}
+
+// caEntry is a CA certificate held in the store.
+type caEntry struct {
+	name   string
+	cert   *x509.Certificate
+	hasKey bool
+	issuer string // Name of the parent CA, "" for roots and external issuers
+
+	keyFile string
+}
+
+// storeCAs returns every CA in the inventory, keyed by name. A CA whose key
+// has been moved offline is still returned so that the hierarchy and
+// certificate chains stay intact.
+func storeCAs() map[string]*caEntry {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		log.Printf("%v", err)
+		return map[string]*caEntry{}
+	}
+	return inventoryCAs(inv)
+}
+
+// inventoryCAs loads the certificates of the CA records in inv.
+func inventoryCAs(inv *inventory) map[string]*caEntry {
+	cas := make(map[string]*caEntry)
+	for _, r := range inv.Records {
+		if r.Kind != kindCA {
+			continue
+		}
+		cert, err := loadCert(r.CertFile)
+		if err != nil {
+			log.Printf("Could not load CA '%s': %v", r.Name, err)
+			continue
+		}
+		cas[r.Name] = &caEntry{
+			name:    r.Name,
+			cert:    cert,
+			hasKey:  r.KeyFile != "" && fileExists(filepath.Join(outputDir, r.KeyFile)),
+			issuer:  r.Issuer,
+			keyFile: r.KeyFile,
+		}
+	}
+	return cas
+}
+
+// isSelfSigned reports whether the certificate is a self-signed root.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// findParent returns the name of the CA that signed cert, or "" if the
+// issuer is not in the store (or cert is a root).
+func findParent(cert *x509.Certificate, cas map[string]*caEntry) string {
+	if isSelfSigned(cert) {
+		return ""
+	}
+	for name, ca := range cas {
+		if ca.cert.Equal(cert) {
+			continue
+		}
+		if bytes.Equal(ca.cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(ca.cert) == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// buildCATree arranges the CAs into parent/child trees sorted by name.
+func buildCATree(cas map[string]*caEntry) []CANode {
+	children := make(map[string][]string)
+	var roots []string
+	for name, ca := range cas {
+		parent := ca.issuer
+		if _, ok := cas[parent]; !ok {
+			parent = ""
+		}
+		if parent == "" {
+			roots = append(roots, name)
+		} else {
+			children[parent] = append(children[parent], name)
+		}
+	}
+
+	var build func(name, parent string) CANode
+	build = func(name, parent string) CANode {
+		ca := cas[name]
+		node := CANode{
+			Name:        name,
+			CommonName:  ca.cert.Subject.CommonName,
+			Parent:      parent,
+			IsRoot:      isSelfSigned(ca.cert),
+			HasKey:      ca.hasKey,
+			Encrypted:   ca.hasKey && keyEncrypted(ca.keyFile),
+			OnToken:     ca.hasKey && keyOnToken(ca.keyFile),
+			Locked:      ca.hasKey && keyLocked(ca.keyFile),
+			PathLen:     pathLenOf(ca.cert),
+			Constrained: hasNameConstraints(ca.cert),
+			ValidUntil:  ca.cert.NotAfter.Format(time.RFC1123),
+			Children:    []CANode{},
+		}
+		kids := children[name]
+		sort.Strings(kids)
+		for _, kid := range kids {
+			node.Children = append(node.Children, build(kid, name))
+		}
+		return node
+	}
+
+	sort.Strings(roots)
+	tree := []CANode{}
+	for _, root := range roots {
+		tree = append(tree, build(root, ""))
+	}
+	return tree
+}
+
+// pathLenOf returns the certificate's path length constraint, or -1 if unconstrained.
+func pathLenOf(cert *x509.Certificate) int {
+	if cert.MaxPathLen > 0 || (cert.MaxPathLen == 0 && cert.MaxPathLenZero) {
+		return cert.MaxPathLen
+	}
+	return -1
+}
+
+// childPathLen works out the path length for a new intermediate below parent.
+// requested is -1 for "as deep as the parent allows".
+func childPathLen(parent *x509.Certificate, requested int) (int, error) {
+	limit := pathLenOf(parent)
+	if limit == 0 {
+		return 0, fmt.Errorf("CA '%s' has a path length of 0 and cannot sign intermediate CAs", parent.Subject.CommonName)
+	}
+	if limit < 0 {
+		return requested, nil
+	}
+	if requested < 0 {
+		return limit - 1, nil
+	}
+	if requested > limit-1 {
+		return 0, fmt.Errorf("path length %d is too long; CA '%s' allows at most %d below it", requested, parent.Subject.CommonName, limit-1)
+	}
+	return requested, nil
+}
+
+// caChain returns the chain from the named CA up to its root, starting with the
+// named CA itself. Only certificates are needed, so offline CAs are fine.
+func caChain(caName string) ([]*x509.Certificate, error) {
+	cas := storeCAs()
+	var chain []*x509.Certificate
+	seen := make(map[string]bool)
+	for name := caName; name != "" && !seen[name]; name = cas[name].issuer {
+		ca, ok := cas[name]
+		if !ok {
+			break
+		}
+		seen[name] = true
+		chain = append(chain, ca.cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("CA '%s' not found", caName)
+	}
+	return chain, nil
+}
+
+// issuerName returns the name of the CA in the store that issued cert. If the
+// issuer is not in the store, its Common Name is used instead.
+func issuerName(cert *x509.Certificate) string {
+	if record := inventoryRecordFor(cert); record != nil && record.Issuer != "" {
+		return record.Issuer
+	}
+	if parent := findParent(cert, storeCAs()); parent != "" {
+		return parent
+	}
+	return cert.Issuer.CommonName
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pkcs11URIPrefix starts the content of a key file that refers to a key on a token.
+const pkcs11URIPrefix = "pkcs11:"
+
+// TokenInput selects a PKCS#11 token to hold a new CA key.
+type TokenInput struct {
+	Module string `json:"module"` // Path to the PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Token  string `json:"token"`  // Token label
+	PIN    string `json:"pin"`    // User PIN; it is never written to disk
+}
+
+// pkcs11Ref identifies a key pair on a PKCS#11 token. It is stored in place of
+// the PEM key as an RFC 7512 URI, so the key itself never touches the disk.
+type pkcs11Ref struct {
+	Module string
+	Token  string
+	Object string // CKA_LABEL
+	ID     []byte // CKA_ID
+}
+
+// String encodes the reference as a PKCS#11 URI, with the module path as a query attribute.
+func (r *pkcs11Ref) String() string {
+	var id strings.Builder
+	for _, b := range r.ID {
+		fmt.Fprintf(&id, "%%%02x", b)
+	}
+	return fmt.Sprintf("%stoken=%s;object=%s;id=%s?module-path=%s",
+		pkcs11URIPrefix, url.PathEscape(r.Token), url.PathEscape(r.Object), id.String(), url.PathEscape(r.Module))
+}
+
+// parsePKCS11Ref decodes a PKCS#11 URI written by String.
+func parsePKCS11Ref(uri string) (*pkcs11Ref, error) {
+	if !strings.HasPrefix(uri, pkcs11URIPrefix) {
+		return nil, fmt.Errorf("not a PKCS#11 URI")
+	}
+	path, query, _ := strings.Cut(strings.TrimPrefix(uri, pkcs11URIPrefix), "?")
+	ref := &pkcs11Ref{}
+	attributes := func(s, sep string, set func(name, value string)) error {
+		for _, attr := range strings.Split(s, sep) {
+			if attr == "" {
+				continue
+			}
+			name, raw, ok := strings.Cut(attr, "=")
+			if !ok {
+				return fmt.Errorf("invalid PKCS#11 URI attribute '%s'", attr)
+			}
+			value, err := url.PathUnescape(raw)
+			if err != nil {
+				return fmt.Errorf("invalid PKCS#11 URI attribute '%s': %w", attr, err)
+			}
+			set(name, value)
+		}
+		return nil
+	}
+	err := attributes(path, ";", func(name, value string) {
+		switch name {
+		case "token":
+			ref.Token = value
+		case "object":
+			ref.Object = value
+		case "id":
+			ref.ID = []byte(value)
+		}
+	})
+	if err == nil {
+		err = attributes(query, "&", func(name, value string) {
+			if name == "module-path" {
+				ref.Module = value
+			}
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ref.Module == "" || ref.Token == "" || (ref.Object == "" && len(ref.ID) == 0) {
+		return nil, fmt.Errorf("PKCS#11 URI must name a module, a token and an object or id")
+	}
+	return ref, nil
+}
+
+// readPKCS11Ref returns the token reference held in a key file, or nil if
+// the file holds a PEM key (or cannot be read).
+func readPKCS11Ref(keyFile string) (*pkcs11Ref, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, keyFile))
+	if err != nil {
+		return nil, nil
+	}
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte(pkcs11URIPrefix)) {
+		return nil, nil
+	}
+	return parsePKCS11Ref(string(data))
+}
+
+// keyOnToken reports whether a key file refers to a key on a PKCS#11 token.
+func keyOnToken(keyFile string) bool {
+	ref, err := readPKCS11Ref(keyFile)
+	return ref != nil || err != nil
+}
+
+// generateTokenKey creates a key pair labelled label on the token and returns
+// a reference to it with a signer that stays logged in until it is closed.
+func generateTokenKey(token TokenInput, label string, algorithm string, fallback string) (*pkcs11Ref, *tokenSigner, error) {
+	if token.Token == "" {
+		return nil, nil, fmt.Errorf("you must enter the label of the PKCS#11 token")
+	}
+	if token.PIN == "" {
+		return nil, nil, fmt.Errorf("you must enter the PIN of the PKCS#11 token")
+	}
+	if algorithm == "" {
+		algorithm = fallback
+	}
+	var bits int
+	var curve elliptic.Curve
+	switch strings.ToLower(algorithm) {
+	case keyRSA2048:
+		bits = 2048
+	case keyRSA3072:
+		bits = 3072
+	case keyRSA4096:
+		bits = 4096
+	case keyECDSAP256:
+		curve = elliptic.P256()
+	case keyECDSAP384:
+		curve = elliptic.P384()
+	case keyECDSAP521:
+		curve = elliptic.P521()
+	default:
+		return nil, nil, fmt.Errorf("key algorithm '%s' is not supported on PKCS#11 tokens; use RSA or ECDSA", algorithm)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, err
+	}
+	ref := &pkcs11Ref{Module: token.Module, Token: token.Token, Object: label, ID: id}
+	signer, err := generatePKCS11Key(ref, token.PIN, bits, curve)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, signer, nil
+}
+
+// tokenSigner is a signer backed by a logged-in token session.
+type tokenSigner struct {
+	crypto.Signer
+	close func() error
+}
+
+// Close logs out of the token.
+func (s *tokenSigner) Close() error {
+	return s.close()
+}
+
+// discard deletes a key pair that was generated but not used, and logs out.
+func (s *tokenSigner) discard() {
+	if key, ok := s.Signer.(interface{ Delete() error }); ok {
+		if err := key.Delete(); err != nil {
+			log.Printf("Could not delete unused key from token: %v", err)
+		}
+	}
+	s.Close()
+}
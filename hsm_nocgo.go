@@ -0,0 +1,20 @@
+//go:build !cgo
+
+package main
+
+import (
+	"crypto/elliptic"
+	"errors"
+)
+
+// errNoPKCS11 is returned for token keys when the app was built without cgo,
+// which the PKCS#11 bindings need to load the module.
+var errNoPKCS11 = errors.New("PKCS#11 tokens are not supported in this build; rebuild with cgo enabled")
+
+func openPKCS11Key(ref *pkcs11Ref, pin string) (*tokenSigner, error) {
+	return nil, errNoPKCS11
+}
+
+func generatePKCS11Key(ref *pkcs11Ref, pin string, bits int, curve elliptic.Curve) (*tokenSigner, error) {
+	return nil, errNoPKCS11
+}
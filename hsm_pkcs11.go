@@ -0,0 +1,66 @@
+//go:build cgo
+
+package main
+
+import (
+	"crypto/elliptic"
+	"fmt"
+
+	"github.com/ThalesGroup/crypto11"
+)
+
+// openPKCS11Key logs in to the token named by ref and finds its key pair.
+func openPKCS11Key(ref *pkcs11Ref, pin string) (*tokenSigner, error) {
+	ctx, err := openToken(ref, pin)
+	if err != nil {
+		return nil, err
+	}
+	var label []byte
+	if ref.Object != "" {
+		label = []byte(ref.Object)
+	}
+	key, err := ctx.FindKeyPair(ref.ID, label)
+	if err == nil && key == nil {
+		err = fmt.Errorf("key '%s' was not found on token '%s'", ref.Object, ref.Token)
+	}
+	if err != nil {
+		ctx.Close()
+		return nil, err
+	}
+	return &tokenSigner{Signer: key, close: ctx.Close}, nil
+}
+
+// generatePKCS11Key creates an RSA key of the given size, or an ECDSA key on
+// curve when it is set, on the token named by ref.
+func generatePKCS11Key(ref *pkcs11Ref, pin string, bits int, curve elliptic.Curve) (*tokenSigner, error) {
+	ctx, err := openToken(ref, pin)
+	if err != nil {
+		return nil, err
+	}
+	var key crypto11.Signer
+	if curve != nil {
+		key, err = ctx.GenerateECDSAKeyPairWithLabel(ref.ID, []byte(ref.Object), curve)
+	} else {
+		key, err = ctx.GenerateRSAKeyPairWithLabel(ref.ID, []byte(ref.Object), bits)
+	}
+	if err != nil {
+		ctx.Close()
+		return nil, fmt.Errorf("could not generate key on token '%s': %w", ref.Token, err)
+	}
+	return &tokenSigner{Signer: key, close: ctx.Close}, nil
+}
+
+func openToken(ref *pkcs11Ref, pin string) (*crypto11.Context, error) {
+	if ref.Module == "" {
+		return nil, fmt.Errorf("you must enter the path of the PKCS#11 module")
+	}
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       ref.Module,
+		TokenLabel: ref.Token,
+		Pin:        pin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not log in to token '%s': %w", ref.Token, err)
+	}
+	return ctx, nil
+}
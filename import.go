@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ImportInput holds an existing CA or certificate to bring into the store.
+type ImportInput struct {
+	CertFile   string `json:"certFile"`   // Base64 of a PEM, DER, PKCS#7 or PFX/P12 file
+	KeyFile    string `json:"keyFile"`    // Base64 of a PEM or DER key file; empty if the key is in CertFile or not held
+	Password   string `json:"password"`   // Opens a PFX or an encrypted key
+	Name       string `json:"name"`       // Name for an imported CA; empty to use its Common Name
+	Passphrase string `json:"passphrase"` // Encrypts the stored key when set
+}
+
+// importBundle is what was found in the files being imported.
+type importBundle struct {
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+	key   crypto.Signer
+
+	pendingKeyFile string // Key file of a completed certificate request, moved into place instead of key
+}
+
+// ImportCertificate adds a CA or certificate created elsewhere, e.g. with
+// openssl or XCA, to the store. A CA certificate is registered as a CA and
+// anything else as an issued certificate; the key is optional, but when given
+// it must match the certificate, and can also be added later to a certificate
+// imported without one. Chain certificates in the files that are not yet in
+// the store are added as CAs without keys.
+func (a *App) ImportCertificate(input ImportInput) string {
+	if input.CertFile == "" {
+		return "Error: You must choose a certificate or PFX file to import."
+	}
+	if input.Passphrase != "" && len(input.Passphrase) < minPassphraseLength {
+		return fmt.Sprintf("Error: The key passphrase must be at least %d characters.", minPassphraseLength)
+	}
+	certData, err := base64.StdEncoding.DecodeString(input.CertFile)
+	if err != nil {
+		return "Error: The certificate file could not be read."
+	}
+	var keyData []byte
+	if input.KeyFile != "" {
+		if keyData, err = base64.StdEncoding.DecodeString(input.KeyFile); err != nil {
+			return "Error: The key file could not be read."
+		}
+	}
+
+	bundle, err := decodeImport(certData, keyData, input.Password)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if existing := inventoryRecordFor(bundle.cert); existing != nil {
+		if existing.KeyFile != "" || bundle.key == nil {
+			return fmt.Sprintf("Error: This certificate is already in the store as '%s'.", existing.Name)
+		}
+		// Add the missing key to a certificate imported earlier without one
+		keyFile := strings.TrimSuffix(existing.CertFile, ".pem") + ".key"
+		if err := writeKeyPEM(keyFile, bundle.key, input.Passphrase); err != nil {
+			return fmt.Sprintf("Error saving private key: %v", err)
+		}
+		if err := setInventoryKeyFile(existing.Kind, existing.Name, keyFile); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		return fmt.Sprintf("Success! Private key added to '%s'.", existing.Name)
+	}
+
+	if err := importChain(bundle.chain); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	cas := storeCAs()
+
+	if bundle.cert.IsCA {
+		name, err := importCAName(input.Name, bundle.cert)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		if err := importCA(name, bundle, input.Passphrase, cas); err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		if bundle.key == nil {
+			return fmt.Sprintf("Success! CA '%s' imported without a private key; it can be trusted but cannot sign.", name)
+		}
+		return fmt.Sprintf("Success! CA '%s' imported with its private key.", name)
+	}
+
+	certFile, err := importCert(bundle, input.Passphrase, cas)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if bundle.key == nil {
+		return fmt.Sprintf("Success! Certificate imported as '%s' without a private key.", certFile)
+	}
+	return fmt.Sprintf("Success! Certificate imported as '%s' with its private key.", certFile)
+}
+
+// importChain adds the CA certificates of a chain that are not yet in the
+// store as CAs without keys.
+func importChain(chain []*x509.Certificate) error {
+	cas := storeCAs()
+	for _, chainCert := range chain {
+		if !chainCert.IsCA || inventoryRecordFor(chainCert) != nil {
+			continue
+		}
+		name := safeFileName(chainCert.Subject.CommonName)
+		if _, exists := cas[name]; exists || name == "" {
+			continue
+		}
+		if err := importCA(name, &importBundle{cert: chainCert}, "", cas); err != nil {
+			return fmt.Errorf("could not import chain certificate '%s': %w", name, err)
+		}
+		cas = storeCAs()
+	}
+	return nil
+}
+
+// importCAName returns the store name for an imported CA: name if given,
+// else the CA's Common Name.
+func importCAName(name string, cert *x509.Certificate) (string, error) {
+	if name == "" {
+		name = cert.Subject.CommonName
+	}
+	name = safeFileName(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("the CA has no Common Name; enter a name for it")
+	}
+	return name, nil
+}
+
+// importCA writes an imported CA under name and adds it to the inventory,
+// linking it to its parent and to any orphans it has issued.
+func importCA(name string, bundle *importBundle, passphrase string, cas map[string]*caEntry) error {
+	certFile, keyFile := name+".pem", ""
+	if _, exists := cas[name]; exists || fileExists(filepath.Join(outputDir, certFile)) || fileExists(filepath.Join(outputDir, name+".key")) {
+		return fmt.Errorf("a CA with the name '%s' already exists", name)
+	}
+	if err := writeCertPEM(certFile, bundle.cert.Raw); err != nil {
+		return fmt.Errorf("could not save CA certificate: %w", err)
+	}
+	if bundle.key != nil || bundle.pendingKeyFile != "" {
+		keyFile = name + ".key"
+		if err := bundle.saveKey(keyFile, passphrase); err != nil {
+			return fmt.Errorf("could not save CA key: %w", err)
+		}
+	}
+	if err := addToInventory(kindCA, name, bundle.cert, findParent(bundle.cert, cas), certFile, keyFile); err != nil {
+		return err
+	}
+	return adoptOrphans(name, bundle.cert)
+}
+
+// importCert writes an imported certificate and adds it to the inventory,
+// returning its file name.
+func importCert(bundle *importBundle, passphrase string, cas map[string]*caEntry) (string, error) {
+	issuer := findParent(bundle.cert, cas)
+	issuerLabel := issuer
+	if issuerLabel == "" {
+		issuerLabel = bundle.cert.Issuer.CommonName
+	}
+	cn := bundle.cert.Subject.CommonName
+	if cn == "" {
+		cn = "imported_cert"
+	}
+	baseName := uniqueCertFileBase(cn, issuerLabel, bundle.cert.SerialNumber.Text(16))
+	certFile, keyFile := baseName+".pem", ""
+	if err := writeCertPEM(certFile, bundle.cert.Raw); err != nil {
+		return "", fmt.Errorf("could not save certificate: %w", err)
+	}
+	if bundle.key != nil || bundle.pendingKeyFile != "" {
+		keyFile = baseName + ".key"
+		if err := bundle.saveKey(keyFile, passphrase); err != nil {
+			return "", fmt.Errorf("could not save private key: %w", err)
+		}
+	}
+	if err := addToInventory(kindCert, certFile, bundle.cert, issuer, certFile, keyFile); err != nil {
+		return "", err
+	}
+	return certFile, nil
+}
+
+// saveKey writes the bundle's key as keyFile, or moves the pending key there.
+func (b *importBundle) saveKey(keyFile string, passphrase string) error {
+	if b.pendingKeyFile == "" {
+		return writeKeyPEM(keyFile, b.key, passphrase)
+	}
+	lockKey(b.pendingKeyFile)
+	return os.Rename(filepath.Join(outputDir, b.pendingKeyFile), filepath.Join(outputDir, keyFile))
+}
+
+// decodeImport finds the certificate, any chain and the private key in the
+// files being imported. certData may be PEM (with or without the key), DER,
+// a PKCS#7 bundle or PFX/P12; keyData may be PEM or DER in PKCS#8, PKCS#1 or SEC1 form.
+func decodeImport(certData, keyData []byte, password string) (*importBundle, error) {
+	var certs []*x509.Certificate
+	var key crypto.Signer
+	var err error
+
+	switch {
+	case bytes.Contains(certData, []byte("-----BEGIN")):
+		certs, key, err = decodePEMImport(certData, password)
+	default:
+		if cert, derErr := x509.ParseCertificate(certData); derErr == nil {
+			certs = []*x509.Certificate{cert}
+			break
+		}
+		// AD CS and other CAs return the chain as a PKCS#7 (.p7b) bundle
+		if bundleCerts, p7Err := pkcs7Certs(certData); p7Err == nil {
+			certs = leafFirst(bundleCerts)
+			break
+		}
+		var leaf *x509.Certificate
+		var chain []*x509.Certificate
+		var pfxKey interface{}
+		pfxKey, leaf, chain, err = pkcs12.DecodeChain(certData, password)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, fmt.Errorf("the PFX password is incorrect")
+		} else if err != nil {
+			// A PFX without a key, such as a PKCS#12 truststore, holds only certificates
+			var trustErr error
+			if certs, trustErr = pkcs12.DecodeTrustStore(certData, password); trustErr != nil {
+				return nil, fmt.Errorf("the file is not a PEM, DER, PKCS#7 or PFX certificate: %v", err)
+			}
+			err = nil
+			break
+		}
+		signer, ok := pfxKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T in PFX", pfxKey)
+		}
+		certs, key = append([]*x509.Certificate{leaf}, chain...), signer
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate was found in the file")
+	}
+
+	if len(keyData) > 0 {
+		if key != nil {
+			return nil, fmt.Errorf("the certificate file already holds a private key; do not choose a separate key file")
+		}
+		if key, err = decodeKeyImport(keyData, password); err != nil {
+			return nil, err
+		}
+	}
+
+	// The certificate is the one that matches the key, or else the first
+	bundle := &importBundle{cert: certs[0], key: key}
+	if key != nil {
+		bundle.cert = nil
+		for _, cert := range certs {
+			if publicKeysEqual(key.Public(), cert.PublicKey) {
+				bundle.cert = cert
+				break
+			}
+		}
+		if bundle.cert == nil {
+			return nil, fmt.Errorf("the private key does not match the certificate")
+		}
+	}
+	for _, cert := range certs {
+		if cert != bundle.cert {
+			bundle.chain = append(bundle.chain, cert)
+		}
+	}
+	return bundle, nil
+}
+
+// decodePEMImport reads every certificate and at most one private key from PEM data.
+func decodePEMImport(data []byte, password string) ([]*x509.Certificate, crypto.Signer, error) {
+	var certs []*x509.Certificate
+	var key crypto.Signer
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				certs = append(certs, cert)
+				break
+			}
+			// AD CS's base64 chain download is a PKCS#7 bundle under a CERTIFICATE header
+			bundleCerts, p7Err := pkcs7Certs(block.Bytes)
+			if p7Err != nil {
+				return nil, nil, fmt.Errorf("could not parse certificate: %w", err)
+			}
+			certs = append(certs, leafFirst(bundleCerts)...)
+		case block.Type == "PKCS7":
+			bundleCerts, err := pkcs7Certs(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			certs = append(certs, leafFirst(bundleCerts)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			if key != nil {
+				return nil, nil, fmt.Errorf("the file holds more than one private key")
+			}
+			var err error
+			if key, err = decodeKeyBlock(block, password); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return certs, key, nil
+}
+
+// leafFirst moves to the front the certificate of a PKCS#7 bundle that issued
+// none of the others, since bundles are not ordered and often list the root
+// first.
+func leafFirst(certs []*x509.Certificate) []*x509.Certificate {
+	for i, cert := range certs {
+		issuer := false
+		for j, other := range certs {
+			if j != i && bytes.Equal(other.RawIssuer, cert.RawSubject) && other.CheckSignatureFrom(cert) == nil {
+				issuer = true
+				break
+			}
+		}
+		if !issuer {
+			return append(append([]*x509.Certificate{cert}, certs[:i]...), certs[i+1:]...)
+		}
+	}
+	return certs
+}
+
+// decodeKeyImport reads a private key from a PEM or DER key file.
+func decodeKeyImport(data []byte, password string) (crypto.Signer, error) {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		_, key, err := decodePEMImport(data, password)
+		if err == nil && key == nil {
+			err = fmt.Errorf("no private key was found in the key file")
+		}
+		return key, err
+	}
+	if key, err := parsePrivateKey(data); err == nil {
+		return key, nil
+	}
+	return decodeKeyBlock(&pem.Block{Type: encryptedKeyPEMType, Bytes: data}, password)
+}
+
+// decodeKeyBlock decodes a plaintext, encrypted PKCS#8 or legacy
+// OpenSSL-encrypted (DEK-Info) private key.
+func decodeKeyBlock(block *pem.Block, password string) (crypto.Signer, error) {
+	switch {
+	case block.Type == encryptedKeyPEMType:
+		if password == "" {
+			return nil, fmt.Errorf("the private key is encrypted; enter its password")
+		}
+		key, err := decryptPrivateKey(block.Bytes, []byte(password))
+		if errors.Is(err, errWrongPassphrase) {
+			return nil, fmt.Errorf("the key password is incorrect")
+		}
+		return key, err
+	case x509.IsEncryptedPEMBlock(block):
+		// Older openssl and XCA releases write keys with legacy PEM encryption
+		if password == "" {
+			return nil, fmt.Errorf("the private key is encrypted; enter its password")
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return nil, fmt.Errorf("the key password is incorrect")
+		}
+		return parsePrivateKey(der)
+	}
+	return parsePrivateKey(block.Bytes)
+}
+
+// publicKeysEqual reports whether two public keys are the same.
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
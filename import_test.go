@@ -0,0 +1,32 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+// TestDecodeImportPKCS7 reads a PKCS#7 chain, root first as AD CS lists it,
+// in each form a CA hands it out.
+func TestDecodeImportPKCS7(t *testing.T) {
+	root, intermediate, leaf, _, _, _ := testHierarchy(t, "import.example.com")
+	p7b, err := encodePKCS7Certs([]*x509.Certificate{root, intermediate, leaf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms := map[string][]byte{
+		"DER":                     p7b,
+		"PEM":                     pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: p7b}),
+		"PEM CERTIFICATE (AD CS)": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p7b}),
+	}
+	for name, data := range forms {
+		bundle, err := decodeImport(data, nil, "")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bundle.cert.Equal(leaf) || len(bundle.chain) != 2 {
+			t.Errorf("%s: got '%s' with %d chain certificate(s), want the leaf with 2", name, bundle.cert.Subject.CommonName, len(bundle.chain))
+		}
+	}
+}
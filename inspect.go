@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	oidSubjectKeyID    = asn1.ObjectIdentifier{2, 5, 29, 14}
+	oidAuthorityKeyID  = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidNameConstraints = asn1.ObjectIdentifier{2, 5, 29, 30}
+	oidCRLDistribution = asn1.ObjectIdentifier{2, 5, 29, 31}
+	oidCertPolicies    = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidAuthorityInfo   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+	oidAnyPolicy       = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+)
+
+// InspectCA returns the details of a CA certificate.
+func (a *App) InspectCA(caName string) (*CertDetails, error) {
+	if caName == "" {
+		return nil, fmt.Errorf("no CA selected")
+	}
+	cert, err := loadCert(caName + ".pem")
+	if err != nil {
+		return nil, fmt.Errorf("could not load CA '%s': %w", caName, err)
+	}
+	return certDetails(cert), nil
+}
+
+// describeCert fills in everything about a certificate beyond its names and
+// dates: full DNs, key, usages, constraints, identifiers, URLs, policies,
+// extensions, fingerprints and a text dump.
+func describeCert(cert *x509.Certificate, details *CertDetails) {
+	details.SubjectDN = cert.Subject.String()
+	details.IssuerDN = cert.Issuer.String()
+	details.SelfSigned = isSelfSigned(cert)
+	details.KeyAlgorithm = describePublicKey(cert.PublicKey)
+	details.KeyBits = publicKeyBits(cert.PublicKey)
+	details.SignatureAlgorithm = cert.SignatureAlgorithm.String()
+	details.KeyUsage = certKeyUsage(cert)
+	for _, oid := range extKeyUsageOIDs(cert.Extensions) {
+		details.ExtKeyUsage = append(details.ExtKeyUsage, extKeyUsageName(oid))
+	}
+	details.IsCA = cert.IsCA
+	details.PathLen = pathLenOf(cert)
+	details.SubjectKeyID = strings.ToUpper(colonHex(cert.SubjectKeyId))
+	details.AuthorityKeyID = strings.ToUpper(colonHex(cert.AuthorityKeyId))
+	details.CRLURLs = cert.CRLDistributionPoints
+	details.OCSPURLs = cert.OCSPServer
+	details.CAIssuerURLs = cert.IssuingCertificateURL
+	for _, oid := range cert.PolicyIdentifiers {
+		details.Policies = append(details.Policies, policyName(oid))
+	}
+	details.Extensions = []CertExtension{}
+	for _, ext := range cert.Extensions {
+		details.Extensions = append(details.Extensions, CertExtension{
+			OID:      ext.Id.String(),
+			Name:     extensionNames[ext.Id.String()],
+			Critical: ext.Critical,
+			Value:    strings.Join(extensionLines(cert, ext), "; "),
+		})
+	}
+
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	spkiSum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	details.SHA1Fingerprint = strings.ToUpper(colonHex(sha1Sum[:]))
+	details.SHA256Fingerprint = strings.ToUpper(colonHex(sha256Sum[:]))
+	details.SPKIPin = base64.StdEncoding.EncodeToString(spkiSum[:])
+	details.Text = certText(cert)
+}
+
+// certKeyUsage names the Key Usage bits set in a certificate.
+func certKeyUsage(cert *x509.Certificate) []string {
+	var names []string
+	for bit, name := range keyUsageOrder {
+		if cert.KeyUsage&(1<<bit) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// extensionLines renders an extension's value for display, one line per
+// item. Extensions this app does not decode are shown in hex.
+func extensionLines(cert *x509.Certificate, ext pkix.Extension) []string {
+	var lines []string
+	switch {
+	case ext.Id.Equal(oidSubjectKeyID):
+		lines = append(lines, strings.ToUpper(colonHex(cert.SubjectKeyId)))
+	case ext.Id.Equal(oidAuthorityKeyID):
+		lines = append(lines, "keyid:"+strings.ToUpper(colonHex(cert.AuthorityKeyId)))
+	case ext.Id.Equal(oidKeyUsage):
+		lines = append(lines, strings.Join(certKeyUsage(cert), ", "))
+	case ext.Id.Equal(oidExtKeyUsage):
+		var names []string
+		for _, oid := range extKeyUsageOIDs(cert.Extensions) {
+			names = append(names, extKeyUsageName(oid))
+		}
+		lines = append(lines, strings.Join(names, ", "))
+	case ext.Id.Equal(oidBasicConstraints):
+		if !cert.IsCA {
+			lines = append(lines, "CA:FALSE")
+		} else if pathLen := pathLenOf(cert); pathLen >= 0 {
+			lines = append(lines, fmt.Sprintf("CA:TRUE, pathlen:%d", pathLen))
+		} else {
+			lines = append(lines, "CA:TRUE")
+		}
+	case ext.Id.Equal(oidSubjectAltName):
+		sans := &subjectAltNames{dnsNames: cert.DNSNames, ips: cert.IPAddresses, emails: cert.EmailAddresses, uris: cert.URIs, upns: upnsOf(cert)}
+		lines = append(lines, strings.Join(sans.list(), ", "))
+	case ext.Id.Equal(oidCRLDistribution):
+		for _, url := range cert.CRLDistributionPoints {
+			lines = append(lines, "URI:"+url)
+		}
+	case ext.Id.Equal(oidAuthorityInfo):
+		for _, url := range cert.OCSPServer {
+			lines = append(lines, "OCSP - URI:"+url)
+		}
+		for _, url := range cert.IssuingCertificateURL {
+			lines = append(lines, "CA Issuers - URI:"+url)
+		}
+	case ext.Id.Equal(oidCertPolicies):
+		for _, oid := range cert.PolicyIdentifiers {
+			lines = append(lines, "Policy: "+policyName(oid))
+		}
+	case ext.Id.Equal(oidNameConstraints):
+		add := func(label, kind string, values []string) {
+			for _, v := range values {
+				lines = append(lines, fmt.Sprintf("%s: %s:%s", label, kind, v))
+			}
+		}
+		ranges := func(networks []*net.IPNet) []string {
+			var out []string
+			for _, n := range networks {
+				out = append(out, n.String())
+			}
+			return out
+		}
+		add("Permitted", "DNS", cert.PermittedDNSDomains)
+		add("Permitted", "IP", ranges(cert.PermittedIPRanges))
+		add("Permitted", "email", cert.PermittedEmailAddresses)
+		add("Permitted", "URI", cert.PermittedURIDomains)
+		add("Excluded", "DNS", cert.ExcludedDNSDomains)
+		add("Excluded", "IP", ranges(cert.ExcludedIPRanges))
+		add("Excluded", "email", cert.ExcludedEmailAddresses)
+		add("Excluded", "URI", cert.ExcludedURIDomains)
+	}
+	if len(lines) == 0 {
+		value := ext.Value
+		if len(value) > 64 {
+			value = value[:64]
+		}
+		line := hex.EncodeToString(value)
+		if len(value) < len(ext.Value) {
+			line += "..."
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// policyName returns a policy OID, named when it is well known.
+func policyName(oid asn1.ObjectIdentifier) string {
+	if oid.Equal(oidAnyPolicy) {
+		return "anyPolicy"
+	}
+	return oid.String()
+}
+
+// certText dumps a certificate in the layout of "openssl x509 -text".
+func certText(cert *x509.Certificate) string {
+	var b strings.Builder
+	line := func(indent int, format string, args ...interface{}) {
+		b.WriteString(strings.Repeat(" ", indent))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\n")
+	}
+	block := func(indent int, data []byte, perLine int) {
+		for i := 0; i < len(data); i += perLine {
+			end := min(i+perLine, len(data))
+			chunk := colonHex(data[i:end])
+			if end < len(data) {
+				chunk += ":"
+			}
+			line(indent, "%s", chunk)
+		}
+	}
+	const opensslTime = "Jan _2 15:04:05 2006 GMT"
+
+	line(0, "Certificate:")
+	line(4, "Data:")
+	line(8, "Version: %d (0x%x)", cert.Version, cert.Version-1)
+	if cert.SerialNumber.BitLen() < 64 {
+		line(8, "Serial Number: %s (0x%x)", cert.SerialNumber, cert.SerialNumber)
+	} else {
+		line(8, "Serial Number:")
+		block(12, cert.SerialNumber.Bytes(), len(cert.SerialNumber.Bytes()))
+	}
+	line(8, "Signature Algorithm: %s", cert.SignatureAlgorithm)
+	line(8, "Issuer: %s", cert.Issuer)
+	line(8, "Validity")
+	line(12, "Not Before: %s", cert.NotBefore.UTC().Format(opensslTime))
+	line(12, "Not After : %s", cert.NotAfter.UTC().Format(opensslTime))
+	line(8, "Subject: %s", cert.Subject)
+	line(8, "Subject Public Key Info:")
+	writePublicKeyText(cert, line, block)
+	if len(cert.Extensions) > 0 {
+		line(8, "X509v3 extensions:")
+		for _, ext := range cert.Extensions {
+			name := extensionNames[ext.Id.String()]
+			if name == "" {
+				name = ext.Id.String()
+			}
+			if ext.Critical {
+				name += ": critical"
+			} else {
+				name += ":"
+			}
+			line(12, "%s", name)
+			for _, value := range extensionLines(cert, ext) {
+				line(16, "%s", value)
+			}
+		}
+	}
+	line(4, "Signature Algorithm: %s", cert.SignatureAlgorithm)
+	line(4, "Signature Value:")
+	block(8, cert.Signature, 18)
+	return b.String()
+}
+
+// writePublicKeyText dumps the public key part of certText.
+func writePublicKeyText(cert *x509.Certificate, line func(int, string, ...interface{}), block func(int, []byte, int)) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki)
+
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		line(12, "Public Key Algorithm: rsaEncryption")
+		line(16, "Public-Key: (%d bit)", k.N.BitLen())
+		line(16, "Modulus:")
+		modulus := k.N.Bytes()
+		if len(modulus) > 0 && modulus[0]&0x80 != 0 {
+			modulus = append([]byte{0}, modulus...)
+		}
+		block(20, modulus, 15)
+		line(16, "Exponent: %d (0x%x)", k.E, k.E)
+	case *ecdsa.PublicKey:
+		line(12, "Public Key Algorithm: id-ecPublicKey")
+		line(16, "Public-Key: (%d bit)", k.Curve.Params().BitSize)
+		line(16, "pub:")
+		block(20, spki.PublicKey.Bytes, 15)
+		line(16, "NIST CURVE: %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		line(12, "Public Key Algorithm: ED25519")
+		line(16, "ED25519 Public-Key:")
+		line(16, "pub:")
+		block(20, k, 15)
+	default:
+		line(12, "Public Key Algorithm: %s", spki.Algorithm.Algorithm)
+		block(16, spki.PublicKey.Bytes, 15)
+	}
+}
+
+// colonHex formats bytes as colon-separated lower-case hex, as OpenSSL does.
+func colonHex(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":")
+}
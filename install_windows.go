@@ -24,6 +24,7 @@ type dataBlob struct {
 }
 
 // InstallCA adds the selected CA certificate to the Windows "ROOT" and "CA" certificate stores.
+// Intermediate CAs are only added to the "CA" store.
 func (a *App) InstallCA(caName string) string {
 	if caName == "" {
 		return "Error: You must select a CA to install."
@@ -48,8 +49,11 @@ func (a *App) InstallCA(caName string) string {
 		return fmt.Sprintf("Error parsing certificate: %v", err)
 	}
 
-	// Install into both the ROOT and CA (Intermediate) stores
+	// Install roots into both the ROOT and CA (Intermediate) stores
 	stores := []string{"ROOT", "CA"}
+	if !isSelfSigned(cert) {
+		stores = []string{"CA"}
+	}
 	for _, storeName := range stores {
 		err := installCertInStore(cert, storeName)
 		if err != nil {
@@ -107,6 +111,9 @@ func installCertInStore(cert *x509.Certificate, storeName string) error {
 			friendlyNameBase = cert.Subject.CommonName
 		}
 		friendlyName := fmt.Sprintf("%s Signing Root", friendlyNameBase)
+		if !isSelfSigned(cert) {
+			friendlyName = fmt.Sprintf("%s Intermediate (%s)", friendlyNameBase, cert.Subject.CommonName)
+		}
 
 		friendlyNamePtr, err := windows.UTF16PtrFromString(friendlyName)
 		if err != nil {
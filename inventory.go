@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// inventoryFile holds the certificate inventory inside the output directory.
+const inventoryFile = "inventory.json"
+
+// Kinds of inventory record.
+const (
+	kindCA   = "ca"
+	kindCert = "cert"
+)
+
+// Stored certificate states. "expired" is never stored; it is worked out when listing.
+const (
+	statusValid      = "valid"
+	statusRevoked    = "revoked"
+	statusOnHold     = "onHold"
+	statusSuperseded = "superseded" // Replaced by a renewal but not revoked
+	statusExpired    = "expired"
+)
+
+// InventoryRecord describes one CA or issued certificate in the store.
+type InventoryRecord struct {
+	Name           string    `json:"name"` // CA name, or the certificate's .pem file name
+	Kind           string    `json:"kind"` // "ca" or "cert"
+	Serial         string    `json:"serial"`
+	Subject        string    `json:"subject"`
+	CommonName     string    `json:"commonName"`
+	SANs           []string  `json:"sans"`
+	Issuer         string    `json:"issuer"` // Name of the issuing CA in the store, "" if unknown or self-signed
+	IssuerDN       string    `json:"issuerDn"`
+	NotBefore      time.Time `json:"notBefore"`
+	NotAfter       time.Time `json:"notAfter"`
+	KeyAlgorithm   string    `json:"keyAlgorithm"`
+	KeyFingerprint string    `json:"keyFingerprint"` // SHA-256 of the SubjectPublicKeyInfo
+	Fingerprint    string    `json:"fingerprint"`    // SHA-256 of the certificate
+	Status         string    `json:"status"`
+	CertFile       string    `json:"certFile"`
+	KeyFile        string    `json:"keyFile"` // "" when the private key is not held
+	CreatedAt      time.Time `json:"createdAt"`
+	SupersededBy   string    `json:"supersededBy"` // The certificate that renewed this one
+}
+
+// inventory is the persistent list of every CA and certificate the app manages.
+type inventory struct {
+	Records    []InventoryRecord `json:"records"`
+	Tombstones []serialTombstone `json:"tombstones"` // Serials of certificates not in Records: deleted ones and OCSP signers
+}
+
+// serialTombstone remembers a serial an issuer handed out for a certificate
+// the inventory does not list, so that it is never issued again.
+type serialTombstone struct {
+	Issuer string `json:"issuer"`
+	Serial string `json:"serial"`
+}
+
+// bury keeps the serial of a record that is being dropped. Serials of
+// self-signed or orphaned certificates have no issuer to keep them for.
+func (inv *inventory) bury(issuer, serial string) {
+	if issuer == "" {
+		return
+	}
+	for _, t := range inv.Tombstones {
+		if t.Issuer == issuer && t.Serial == serial {
+			return
+		}
+	}
+	inv.Tombstones = append(inv.Tombstones, serialTombstone{Issuer: issuer, Serial: serial})
+}
+
+// inventoryMu serialises access to the inventory file.
+var inventoryMu sync.Mutex
+
+func loadInventory() (*inventory, error) {
+	inv := &inventory{}
+	if err := readJSON(inventoryFile, inv); err != nil {
+		return nil, fmt.Errorf("could not read inventory: %w", err)
+	}
+	return inv, nil
+}
+
+func (inv *inventory) save() error {
+	sort.Slice(inv.Records, func(i, j int) bool {
+		if inv.Records[i].Kind != inv.Records[j].Kind {
+			return inv.Records[i].Kind < inv.Records[j].Kind
+		}
+		return strings.ToLower(inv.Records[i].Name) < strings.ToLower(inv.Records[j].Name)
+	})
+	if err := writeJSON(inventoryFile, inv); err != nil {
+		return fmt.Errorf("could not save inventory: %w", err)
+	}
+	return nil
+}
+
+// find returns the index of the record with the given kind and name, or -1.
+func (inv *inventory) find(kind, name string) int {
+	for i, r := range inv.Records {
+		if r.Kind == kind && r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// byFingerprint returns the record for a certificate, or nil.
+func (inv *inventory) byFingerprint(cert *x509.Certificate) *InventoryRecord {
+	fp := certFingerprint(cert)
+	for i := range inv.Records {
+		if inv.Records[i].Fingerprint == fp {
+			return &inv.Records[i]
+		}
+	}
+	return nil
+}
+
+// newRecord builds an inventory record from a certificate.
+func newRecord(kind, name string, cert *x509.Certificate, issuer, certFile, keyFile string) InventoryRecord {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	sans = append(sans, upnsOf(cert)...)
+	keyHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return InventoryRecord{
+		Name:           name,
+		Kind:           kind,
+		Serial:         cert.SerialNumber.Text(16),
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		SANs:           sans,
+		Issuer:         issuer,
+		IssuerDN:       cert.Issuer.String(),
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		KeyAlgorithm:   describePublicKey(cert.PublicKey),
+		KeyFingerprint: hex.EncodeToString(keyHash[:]),
+		Fingerprint:    certFingerprint(cert),
+		Status:         statusValid,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CreatedAt:      time.Now().UTC(),
+	}
+}
+
+// certFingerprint returns the hex SHA-256 fingerprint of a certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// addToInventory records a newly created CA or certificate, replacing any
+// record with the same kind and name.
+func addToInventory(kind, name string, cert *x509.Certificate, issuer, certFile, keyFile string) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	record := newRecord(kind, name, cert, issuer, certFile, keyFile)
+	if i := inv.find(kind, name); i >= 0 {
+		inv.Records[i] = record
+	} else {
+		inv.Records = append(inv.Records, record)
+	}
+	return inv.save()
+}
+
+// adoptOrphans links the records without a known issuer that were signed by
+// a newly added CA to it.
+func adoptOrphans(caName string, caCert *x509.Certificate) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i := range inv.Records {
+		r := &inv.Records[i]
+		if r.Issuer != "" || (r.Kind == kindCA && r.Name == caName) {
+			continue
+		}
+		cert, err := loadCert(r.CertFile)
+		if err != nil || isSelfSigned(cert) || !bytes.Equal(cert.RawIssuer, caCert.RawSubject) || cert.CheckSignatureFrom(caCert) != nil {
+			continue
+		}
+		r.Issuer = caName
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return inv.save()
+}
+
+// setInventoryKeyFile records the key file of a CA or certificate.
+func setInventoryKeyFile(kind, name, keyFile string) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	i := inv.find(kind, name)
+	if i < 0 {
+		return fmt.Errorf("'%s' is not in the inventory", name)
+	}
+	inv.Records[i].KeyFile = keyFile
+	return inv.save()
+}
+
+// recordSerial keeps a serial issued for a certificate the inventory does not
+// list, such as a delegated OCSP signer.
+func recordSerial(issuer, serial string) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	inv.bury(issuer, serial)
+	return inv.save()
+}
+
+// removeFromInventory forgets a deleted CA or certificate, keeping its serial
+// as a tombstone.
+func removeFromInventory(kind, name string) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	i := inv.find(kind, name)
+	if i < 0 {
+		return nil
+	}
+	inv.bury(inv.Records[i].Issuer, inv.Records[i].Serial)
+	inv.Records = append(inv.Records[:i], inv.Records[i+1:]...)
+	return inv.save()
+}
+
+// setInventoryStatus updates the stored status of the certificate issued by
+// issuer with the given serial.
+func setInventoryStatus(issuer string, serial string, status string) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	for i := range inv.Records {
+		if inv.Records[i].Issuer == issuer && inv.Records[i].Serial == serial {
+			inv.Records[i].Status = status
+			// A renewed certificate that is no longer revoked is superseded, not valid
+			if status == statusValid && inv.Records[i].SupersededBy != "" {
+				inv.Records[i].Status = statusSuperseded
+			}
+		}
+	}
+	return inv.save()
+}
+
+// supersedeCert records that a certificate has been renewed as newName. A
+// valid certificate becomes superseded; a revoked one stays revoked.
+func supersedeCert(name, newName string) error {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	i := inv.find(kindCert, name)
+	if i < 0 {
+		return fmt.Errorf("certificate '%s' is not in the inventory", name)
+	}
+	inv.Records[i].SupersededBy = newName
+	if inv.Records[i].Status == statusValid {
+		inv.Records[i].Status = statusSuperseded
+	}
+	return inv.save()
+}
+
+// inventoryRecords returns a copy of all records of the given kind.
+func inventoryRecords(kind string) []InventoryRecord {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	var records []InventoryRecord
+	for _, r := range inv.Records {
+		if r.Kind == kind {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// inventoryRecordFor returns the record for a certificate, or nil if it is not in the inventory.
+func inventoryRecordFor(cert *x509.Certificate) *InventoryRecord {
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return nil
+	}
+	return inv.byFingerprint(cert)
+}
+
+// displayStatus returns the record's status, reporting valid and superseded certificates past their expiry as expired.
+func (r InventoryRecord) displayStatus() string {
+	if (r.Status == statusValid || r.Status == statusSuperseded) && time.Now().After(r.NotAfter) {
+		return statusExpired
+	}
+	return r.Status
+}
+
+// RebuildInventory rescans the output folder, adding certificates that were
+// copied in by hand and dropping records whose files have gone.
+func (a *App) RebuildInventory() string {
+	added, removed, err := rebuildInventory()
+	if err != nil {
+		return fmt.Sprintf("Error rebuilding inventory: %v", err)
+	}
+	return fmt.Sprintf("Success! Inventory rebuilt: %d added, %d removed.", added, removed)
+}
+
+// ensureInventory creates the inventory from the output folder the first time the app runs.
+func ensureInventory() {
+	if fileExists(filepath.Join(outputDir, inventoryFile)) {
+		return
+	}
+	if _, _, err := rebuildInventory(); err != nil {
+		log.Printf("Could not build inventory: %v", err)
+	}
+}
+
+// rebuildInventory reconciles the inventory with the files in the output folder.
+func rebuildInventory() (added int, removed int, err error) {
+	// The registry is read first; its lock is never taken while holding the inventory's
+	revocationMu.Lock()
+	reg, err := loadRevocations()
+	revocationMu.Unlock()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	inv, err := loadInventory()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Drop records whose certificate file no longer exists
+	kept := inv.Records[:0]
+	known := make(map[string]bool)
+	for _, r := range inv.Records {
+		if !fileExists(filepath.Join(outputDir, r.CertFile)) {
+			inv.bury(r.Issuer, r.Serial)
+			removed++
+			continue
+		}
+		if r.KeyFile != "" && !fileExists(filepath.Join(outputDir, r.KeyFile)) {
+			r.KeyFile = ""
+		}
+		kept = append(kept, r)
+		known[r.CertFile] = true
+	}
+	inv.Records = kept
+
+	files, err := os.ReadDir(outputDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	type found struct {
+		fileName string
+		cert     *x509.Certificate
+	}
+	var newCAs, newCerts []found
+	for _, file := range files {
+		fileName := file.Name()
+		if file.IsDir() || !strings.HasSuffix(fileName, ".pem") || known[fileName] || isHelperFile(fileName) {
+			continue
+		}
+		cert, err := loadCert(fileName)
+		if err != nil {
+			continue
+		}
+		if cert.IsCA {
+			newCAs = append(newCAs, found{fileName, cert})
+		} else {
+			newCerts = append(newCerts, found{fileName, cert})
+		}
+	}
+
+	// CAs first, so that issuers of the certificates are known
+	for _, f := range newCAs {
+		name := strings.TrimSuffix(f.fileName, ".pem")
+		inv.Records = append(inv.Records, newRecord(kindCA, name, f.cert, "", f.fileName, existingKeyFile(name)))
+		added++
+	}
+	cas := inventoryCAs(inv)
+	for i := range inv.Records {
+		if inv.Records[i].Kind == kindCA && inv.Records[i].Issuer == "" {
+			if ca, ok := cas[inv.Records[i].Name]; ok {
+				inv.Records[i].Issuer = findParent(ca.cert, cas)
+			}
+		}
+	}
+	for _, f := range newCerts {
+		base := strings.TrimSuffix(f.fileName, ".pem")
+		inv.Records = append(inv.Records, newRecord(kindCert, f.fileName, f.cert, findParent(f.cert, cas), f.fileName, existingKeyFile(base)))
+		added++
+	}
+
+	// Bring stored statuses in line with the revocation registry
+	for i := range inv.Records {
+		r := &inv.Records[i]
+		if r.Status != statusValid && r.Status != statusRevoked && r.Status != statusOnHold && r.Status != statusSuperseded {
+			continue
+		}
+		r.Status = statusValid
+		if r.SupersededBy != "" {
+			r.Status = statusSuperseded
+		}
+		for _, e := range reg.forIssuer(r.Issuer) {
+			if e.Serial == r.Serial {
+				r.Status = statusRevoked
+				if e.Reason == reasonCertificateHold {
+					r.Status = statusOnHold
+				}
+			}
+		}
+	}
+
+	return added, removed, inv.save()
+}
+
+// helperFileSuffixes mark the app's own .pem support files and exports.
+var helperFileSuffixes = []string{".ocsp-signer.pem", ".crl.pem", ".chain.pem", ".fullchain.pem", ".combined.pem"}
+
+// isHelperFile reports whether a .pem file in the output folder is one of the
+// app's own support files or exports rather than a managed certificate.
+func isHelperFile(fileName string) bool {
+	for _, suffix := range helperFileSuffixes {
+		if strings.HasSuffix(fileName, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// existingKeyFile returns base+".key" if that file exists in the output folder.
+func existingKeyFile(base string) string {
+	if fileExists(filepath.Join(outputDir, base+".key")) {
+		return base + ".key"
+	}
+	return ""
+}
+
+// uniqueCertFileBase returns a file name base for a new certificate that does
+// not clash with any existing file, so re-issuing the same CN never overwrites.
+func uniqueCertFileBase(cn string, caName string, serial string) string {
+	base := fmt.Sprintf("%s_signed-by_%s", safeFileName(cn), safeFileName(caName))
+	if !fileExists(filepath.Join(outputDir, base+".pem")) && !fileExists(filepath.Join(outputDir, base+".key")) {
+		return base
+	}
+	if len(serial) > 8 {
+		serial = serial[len(serial)-8:]
+	}
+	candidate := fmt.Sprintf("%s_%s", base, serial)
+	for n := 2; fileExists(filepath.Join(outputDir, candidate+".pem")); n++ {
+		candidate = fmt.Sprintf("%s_%s-%d", base, serial, n)
+	}
+	return candidate
+}
+
+// safeFileName makes a name usable as a file name on every platform.
+func safeFileName(name string) string {
+	name = strings.ReplaceAll(name, "*", "_wildcard")
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// unlockTimeout is how long an unlocked key stays usable.
+	unlockTimeout = 15 * time.Minute
+	// pbkdf2Iterations is the PBKDF2-HMAC-SHA256 work factor for new encrypted keys.
+	pbkdf2Iterations = 600000
+	// maxPBKDF2Iterations bounds the work factor accepted from a key file, so a
+	// crafted key cannot hang the app.
+	maxPBKDF2Iterations = 10000000
+	// minPassphraseLength is the shortest passphrase accepted for new encrypted keys.
+	minPassphraseLength = 8
+	// encryptedKeyPEMType is the PEM type of an encrypted PKCS#8 key (RFC 5958).
+	encryptedKeyPEMType = "ENCRYPTED PRIVATE KEY"
+)
+
+// Algorithm identifiers from PKCS#5 v2.1 (RFC 8018) and NIST.
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// errWrongPassphrase is returned when an encrypted key cannot be decrypted.
+var errWrongPassphrase = errors.New("incorrect passphrase")
+
+// keyLockedError reports that a key is encrypted, or held on a token, and
+// has not been unlocked.
+type keyLockedError struct {
+	keyFile string
+	token   bool
+}
+
+func (e *keyLockedError) Error() string {
+	if e.token {
+		return fmt.Sprintf("private key '%s' is on a PKCS#11 token and is locked; unlock it with the token PIN", e.keyFile)
+	}
+	return fmt.Sprintf("private key '%s' is locked; unlock it with its passphrase", e.keyFile)
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// unlockedKey is a decrypted key, or a logged-in token key, held for the
+// rest of its unlock session.
+type unlockedKey struct {
+	key     crypto.Signer
+	expires time.Time
+}
+
+// release logs out of the token when the key is held on one.
+func (u unlockedKey) release() {
+	if closer, ok := u.key.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+var (
+	// sessionMu guards unlockedKeys.
+	sessionMu sync.Mutex
+	// unlockedKeys holds decrypted keys by key file name.
+	unlockedKeys = make(map[string]unlockedKey)
+)
+
+// UnlockCA decrypts a CA's private key with its passphrase, or logs in to
+// the token holding it with the token PIN, and keeps it usable for signing
+// until the unlock session times out.
+func (a *App) UnlockCA(caName string, passphrase string) string {
+	if caName == "" {
+		return "Error: You must select a CA."
+	}
+	if err := unlockKey(caName+".key", passphrase); err != nil {
+		return fmt.Sprintf("Error unlocking CA '%s': %v", caName, err)
+	}
+	return fmt.Sprintf("Success! CA '%s' is unlocked for %d minutes.", caName, int(unlockTimeout.Minutes()))
+}
+
+// UnlockCertKey decrypts a device certificate's private key with its
+// passphrase, for example to export it, until the unlock session times out.
+func (a *App) UnlockCertKey(certName string, passphrase string) string {
+	keyFile := certKeyFile(certName)
+	if keyFile == "" {
+		return fmt.Sprintf("Error: Certificate '%s' has no private key.", certName)
+	}
+	if err := unlockKey(keyFile, passphrase); err != nil {
+		return fmt.Sprintf("Error unlocking key for '%s': %v", certName, err)
+	}
+	return fmt.Sprintf("Success! Key for '%s' is unlocked for %d minutes.", certName, int(unlockTimeout.Minutes()))
+}
+
+// LockKeys ends the unlock session, forgetting every decrypted key.
+func (a *App) LockKeys() string {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	for _, unlocked := range unlockedKeys {
+		unlocked.release()
+	}
+	unlockedKeys = make(map[string]unlockedKey)
+	return "Success! All keys are locked."
+}
+
+// ChangeCAPassphrase re-encrypts a CA key with a new passphrase. An empty
+// oldPassphrase encrypts a plaintext key; an empty newPassphrase removes the
+// protection.
+func (a *App) ChangeCAPassphrase(caName string, oldPassphrase string, newPassphrase string) string {
+	if caName == "" {
+		return "Error: You must select a CA."
+	}
+	if err := changeKeyPassphrase(caName+".key", oldPassphrase, newPassphrase); err != nil {
+		return fmt.Sprintf("Error changing passphrase for CA '%s': %v", caName, err)
+	}
+	// A delegated OCSP signer key saved while the CA was unencrypted is reissued in memory
+	if newPassphrase != "" {
+		if err := os.Remove(filepath.Join(outputDir, caName+".ocsp-signer.key")); err != nil && !os.IsNotExist(err) {
+			return fmt.Sprintf("Error: Passphrase for CA '%s' changed, but its plaintext OCSP signer key could not be removed: %v", caName, err)
+		}
+	}
+	if newPassphrase == "" {
+		return fmt.Sprintf("Success! The key of CA '%s' is no longer encrypted.", caName)
+	}
+	return fmt.Sprintf("Success! Passphrase for CA '%s' changed.", caName)
+}
+
+// ChangeCertKeyPassphrase re-encrypts a device certificate's key with a new
+// passphrase, with the same rules as ChangeCAPassphrase.
+func (a *App) ChangeCertKeyPassphrase(certName string, oldPassphrase string, newPassphrase string) string {
+	keyFile := certKeyFile(certName)
+	if keyFile == "" {
+		return fmt.Sprintf("Error: Certificate '%s' has no private key.", certName)
+	}
+	if err := changeKeyPassphrase(keyFile, oldPassphrase, newPassphrase); err != nil {
+		return fmt.Sprintf("Error changing passphrase for '%s': %v", certName, err)
+	}
+	if newPassphrase == "" {
+		return fmt.Sprintf("Success! The key of '%s' is no longer encrypted.", certName)
+	}
+	return fmt.Sprintf("Success! Passphrase for '%s' changed.", certName)
+}
+
+// loadPrivateKey reads a key from the output folder. Encrypted and token
+// keys must have been unlocked first.
+func loadPrivateKey(keyFile string) (crypto.Signer, error) {
+	if ref, err := readPKCS11Ref(keyFile); err != nil {
+		return nil, fmt.Errorf("invalid key reference in '%s': %w", keyFile, err)
+	} else if ref != nil {
+		if key := sessionKey(keyFile); key != nil {
+			return key, nil
+		}
+		return nil, &keyLockedError{keyFile: keyFile, token: true}
+	}
+	block, err := readKeyBlock(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == encryptedKeyPEMType {
+		if key := sessionKey(keyFile); key != nil {
+			return key, nil
+		}
+		return nil, &keyLockedError{keyFile: keyFile}
+	}
+	if _, encrypted := block.Headers["DEK-Info"]; encrypted {
+		return nil, fmt.Errorf("key '%s' uses legacy PEM encryption; convert it to encrypted PKCS#8", keyFile)
+	}
+	return parsePrivateKey(block.Bytes)
+}
+
+// keyEncrypted reports whether a key file holds an encrypted PKCS#8 key.
+func keyEncrypted(keyFile string) bool {
+	block, err := readKeyBlock(keyFile)
+	return err == nil && block.Type == encryptedKeyPEMType
+}
+
+// keyLocked reports whether a key file is encrypted or on a token, and not
+// currently unlocked.
+func keyLocked(keyFile string) bool {
+	return (keyEncrypted(keyFile) || keyOnToken(keyFile)) && sessionKey(keyFile) == nil
+}
+
+func readKeyBlock(keyFile string) (*pem.Block, error) {
+	keyData, err := os.ReadFile(filepath.Join(outputDir, keyFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, fmt.Errorf("could not decode PEM block from key")
+	}
+	return block, nil
+}
+
+// sessionKey returns the unlocked key for keyFile, or nil if it is locked or
+// its session has expired.
+func sessionKey(keyFile string) crypto.Signer {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	now := time.Now()
+	for name, unlocked := range unlockedKeys {
+		if now.After(unlocked.expires) {
+			unlocked.release()
+			delete(unlockedKeys, name)
+		}
+	}
+	if unlocked, ok := unlockedKeys[keyFile]; ok {
+		return unlocked.key
+	}
+	return nil
+}
+
+// unlockKey decrypts an encrypted key, or logs in to the token holding it,
+// and holds it for unlockTimeout.
+func unlockKey(keyFile string, passphrase string) error {
+	ref, err := readPKCS11Ref(keyFile)
+	if err != nil {
+		return err
+	}
+	if ref != nil {
+		key, err := openPKCS11Key(ref, passphrase)
+		if err != nil {
+			return err
+		}
+		holdKey(keyFile, key)
+		return nil
+	}
+
+	block, err := readKeyBlock(keyFile)
+	if err != nil {
+		return err
+	}
+	if block.Type != encryptedKeyPEMType {
+		return fmt.Errorf("the key is not encrypted")
+	}
+	key, err := decryptPrivateKey(block.Bytes, []byte(passphrase))
+	if err != nil {
+		return err
+	}
+	holdKey(keyFile, key)
+	return nil
+}
+
+// holdKey keeps an unlocked key usable for unlockTimeout.
+func holdKey(keyFile string, key crypto.Signer) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if previous, ok := unlockedKeys[keyFile]; ok {
+		previous.release()
+	}
+	unlockedKeys[keyFile] = unlockedKey{key: key, expires: time.Now().Add(unlockTimeout)}
+}
+
+// lockKey forgets a decrypted key, e.g. when its file is deleted.
+func lockKey(keyFile string) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if unlocked, ok := unlockedKeys[keyFile]; ok {
+		unlocked.release()
+		delete(unlockedKeys, keyFile)
+	}
+}
+
+// changeKeyPassphrase rewrites a key file encrypted with newPassphrase, or in
+// plaintext when newPassphrase is empty.
+func changeKeyPassphrase(keyFile string, oldPassphrase string, newPassphrase string) error {
+	if newPassphrase != "" && len(newPassphrase) < minPassphraseLength {
+		return fmt.Errorf("the new passphrase must be at least %d characters", minPassphraseLength)
+	}
+	if keyOnToken(keyFile) {
+		return fmt.Errorf("the key is held on a PKCS#11 token; change the token PIN with the token's own tools")
+	}
+	block, err := readKeyBlock(keyFile)
+	if err != nil {
+		return err
+	}
+	var key crypto.Signer
+	if block.Type == encryptedKeyPEMType {
+		key, err = decryptPrivateKey(block.Bytes, []byte(oldPassphrase))
+	} else if newPassphrase == "" {
+		return fmt.Errorf("the key is not encrypted")
+	} else {
+		key, err = parsePrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return err
+	}
+	lockKey(keyFile)
+	return writeKeyPEM(keyFile, key, newPassphrase)
+}
+
+// encryptPrivateKey encodes key as an encrypted PKCS#8 EncryptedPrivateKeyInfo
+// using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func encryptPrivateKey(key crypto.Signer, passphrase []byte) ([]byte, error) {
+	plaintext, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, ciphertext, err := pbes2Encrypt(plaintext, passphrase, pbkdf2Iterations)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: algorithm, EncryptedData: ciphertext})
+}
+
+// pbes2Encrypt encrypts plaintext with PBES2, PBKDF2-HMAC-SHA256 and
+// AES-256-CBC, returning the algorithm identifier that describes it.
+func pbes2Encrypt(plaintext []byte, passphrase []byte, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	var none pkix.AlgorithmIdentifier
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return none, nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return none, nil, err
+	}
+	derived, err := pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, 32)
+	if err != nil {
+		return none, nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return none, nil, err
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	plaintext = append(plaintext[:len(plaintext):len(plaintext)], bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return none, nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return none, nil, err
+	}
+	schemeParams, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return none, nil, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: schemeParams}}, ciphertext, nil
+}
+
+// decryptPrivateKey decrypts a PBES2 EncryptedPrivateKeyInfo using PBKDF2
+// and AES-CBC, as written by this app and by "openssl pkcs8 -topk8 -v2 aes256".
+func decryptPrivateKey(der []byte, passphrase []byte) (crypto.Signer, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("could not parse encrypted key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption %s; only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("could not parse PBES2 parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation %s; only PBKDF2 is supported", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("could not parse PBKDF2 parameters: %w", err)
+	}
+	if kdf.IterationCount < 1 || kdf.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("unsupported PBKDF2 iteration count %d; it must be between 1 and %d", kdf.IterationCount, maxPBKDF2Iterations)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA384):
+		prf = sha512.New384
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLen = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported key cipher %s; only AES-CBC is supported", scheme)
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+		return nil, fmt.Errorf("PBKDF2 key length %d does not match the %d-byte cipher key", kdf.KeyLength, keyLen)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid AES-CBC parameters")
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted key length")
+	}
+
+	derived, err := pbkdf2.Key(prf, string(passphrase), kdf.Salt, kdf.IterationCount, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errWrongPassphrase
+	}
+	key, err := parsePrivateKey(plaintext[:len(plaintext)-padding])
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return key, nil
+}
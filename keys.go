@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// Supported key algorithms for CAs and device certificates.
+const (
+	keyRSA2048   = "rsa2048"
+	keyRSA3072   = "rsa3072"
+	keyRSA4096   = "rsa4096"
+	keyECDSAP256 = "ecdsa-p256"
+	keyECDSAP384 = "ecdsa-p384"
+	keyECDSAP521 = "ecdsa-p521"
+	keyEd25519   = "ed25519"
+)
+
+// generateKey creates a new private key for the given algorithm name.
+// An empty name selects fallback.
+func generateKey(algorithm string, fallback string) (crypto.Signer, error) {
+	if algorithm == "" {
+		algorithm = fallback
+	}
+	switch strings.ToLower(algorithm) {
+	case keyRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case keyRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case keyRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case keyECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case keyECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case keyECDSAP521:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case keyEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unsupported key algorithm '%s'", algorithm)
+}
+
+// parsePrivateKey decodes a DER private key in PKCS#8, PKCS#1 or SEC1 form.
+func parsePrivateKey(derBytes []byte) (crypto.Signer, error) {
+	// Try PKCS#8 first
+	if key, err := x509.ParsePKCS8PrivateKey(derBytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	// Then try PKCS#1
+	if key, err := x509.ParsePKCS1PrivateKey(derBytes); err == nil {
+		return key, nil
+	}
+	// And finally SEC1 (EC PRIVATE KEY)
+	if key, err := x509.ParseECPrivateKey(derBytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("failed to parse private key: unsupported format")
+}
+
+// isRSAKey reports whether the public key is an RSA key. Only RSA keys can be
+// used for key encipherment.
+func isRSAKey(pub crypto.PublicKey) bool {
+	_, ok := pub.(*rsa.PublicKey)
+	return ok
+}
+
+// keyAlgorithmOf returns the algorithm name that generates keys like pub, or
+// "" if there is none.
+func keyAlgorithmOf(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return keyRSA2048
+		case 3072:
+			return keyRSA3072
+		case 4096:
+			return keyRSA4096
+		}
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return keyECDSAP256
+		case elliptic.P384():
+			return keyECDSAP384
+		case elliptic.P521():
+			return keyECDSAP521
+		}
+	case ed25519.PublicKey:
+		return keyEd25519
+	}
+	return ""
+}
+
+// describePublicKey returns a short description such as "RSA 2048" or "ECDSA P-256".
+func describePublicKey(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return fmt.Sprintf("%T", pub)
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Java keystore formats.
+const (
+	keyStoreJKS    = "jks"    // Sun's original format, read by every Java version
+	keyStoreJCEKS  = "jceks"  // JKS with 3DES key protection
+	keyStorePKCS12 = "pkcs12" // The default since Java 9
+)
+
+// jceksIterations is the PBEWithMD5AndTripleDES work factor, the Java default.
+const jceksIterations = 200000
+
+// Sun's proprietary key protection algorithms.
+var (
+	oidJKSKeyProtector   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+	oidPBEWithMD5And3DES = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 19, 1}
+)
+
+// pbeParams are the parameters of a PKCS#5 password-based encryption scheme.
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// javaKeyStoreExtension maps each keystore format to its file extension.
+var javaKeyStoreExtension = map[string]string{keyStoreJKS: ".jks", keyStoreJCEKS: ".jceks", keyStorePKCS12: ".p12"}
+
+// KeyStoreInput selects what to put in a Java keystore or truststore and how
+// to protect it.
+type KeyStoreInput struct {
+	CertName      string `json:"certName"` // Keystores: the certificate stored with its key and chain
+	CAName        string `json:"caName"`   // Truststores: the CA to trust
+	Format        string `json:"format"`   // jks, jceks or pkcs12
+	Alias         string `json:"alias"`    // Defaults to the Common Name, or the CA name for truststores
+	StorePassword string `json:"storePassword"`
+	KeyPassword   string `json:"keyPassword"`  // Keystores; defaults to the store password
+	IncludeChain  bool   `json:"includeChain"` // Truststores: also trust the CAs above CAName
+}
+
+// javaKeyStoreEntry is a private key entry when key is set, otherwise a
+// trusted certificate entry.
+type javaKeyStoreEntry struct {
+	alias string
+	key   []byte              // Protected key
+	certs []*x509.Certificate // The chain of a key, or the trusted certificate
+}
+
+// ExportKeyStore writes a Java keystore holding a certificate's private key
+// and chain under one alias, readable only by the owner.
+func (a *App) ExportKeyStore(input KeyStoreInput) string {
+	certName := input.CertName
+	if certName == "" {
+		return "Error: You must select a certificate to export."
+	}
+	if err := checkKeyStoreInput(&input); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	keyPassword := input.KeyPassword
+	if keyPassword == "" {
+		keyPassword = input.StorePassword
+	}
+	switch {
+	case input.Format == keyStorePKCS12 && keyPassword != input.StorePassword:
+		return "Error: PKCS#12 keystores protect the key with the store password; leave the key password blank."
+	case len(keyPassword) < minPassphraseLength:
+		return fmt.Sprintf("Error: The key password must be at least %d characters.", minPassphraseLength)
+	case input.Format == keyStoreJCEKS && !printableASCII(keyPassword):
+		return "Error: JCEKS key passwords may only use printable ASCII characters."
+	}
+
+	cert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+	keyFile := certKeyFile(certName)
+	if keyFile == "" {
+		return fmt.Sprintf("Error: Certificate '%s' has no private key.", certName)
+	}
+	privateKey, err := loadPrivateKey(keyFile)
+	if err != nil {
+		return fmt.Sprintf("Error loading private key: %v", err)
+	}
+	chain, err := issuerChain(certName, cert)
+	if err != nil {
+		return fmt.Sprintf("Error loading issuing CA '%s': %v", cert.Issuer.CommonName, err)
+	}
+	alias := input.Alias
+	if alias == "" {
+		alias = cert.Subject.CommonName
+	}
+
+	var data []byte
+	if input.Format == keyStorePKCS12 {
+		// Java reads the friendly name of the key bag as the alias
+		data, err = encodePFX(pfxEncodings[pfxModern], privateKey, cert, chain, alias, input.StorePassword)
+	} else {
+		var plainKey, protected []byte
+		if plainKey, err = x509.MarshalPKCS8PrivateKey(privateKey); err != nil {
+			return fmt.Sprintf("Error encoding private key: %v", err)
+		}
+		if input.Format == keyStoreJCEKS {
+			protected, err = protectJCEKSKey(rand.Reader, plainKey, keyPassword)
+		} else {
+			protected, err = protectJKSKey(rand.Reader, plainKey, keyPassword)
+		}
+		if err != nil {
+			return fmt.Sprintf("Error protecting private key: %v", err)
+		}
+		entry := javaKeyStoreEntry{alias: alias, key: protected, certs: append([]*x509.Certificate{cert}, chain...)}
+		data, err = encodeJavaKeyStore(input.Format, []javaKeyStoreEntry{entry}, input.StorePassword)
+	}
+	if err != nil {
+		return fmt.Sprintf("Error creating keystore: %v", err)
+	}
+
+	fileName := strings.TrimSuffix(certName, ".pem") + ".keystore" + javaKeyStoreExtension[input.Format]
+	path := filepath.Join(outputDir, fileName)
+	if err := replaceFile(fileName, data, 0600); err != nil {
+		return fmt.Sprintf("Error saving keystore: %v", err)
+	}
+	return fmt.Sprintf("Success! Keystore written to '%s' with the key under alias '%s'.", path, javaAlias(input.Format, alias))
+}
+
+// ExportTrustStore writes a Java truststore holding a CA as a trusted
+// certificate entry and, optionally, the CAs above it.
+func (a *App) ExportTrustStore(input KeyStoreInput) string {
+	caName := input.CAName
+	if caName == "" {
+		return "Error: You must select a CA to trust."
+	}
+	if err := checkKeyStoreInput(&input); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	chain, err := caChain(caName)
+	if err != nil {
+		return fmt.Sprintf("Error loading CA '%s': %v", caName, err)
+	}
+	if !input.IncludeChain {
+		chain = chain[:1]
+	}
+
+	// The CA goes under the chosen alias, the CAs above it under their names
+	aliases := []string{input.Alias}
+	if aliases[0] == "" {
+		aliases[0] = caName
+	}
+	for _, cert := range chain[1:] {
+		alias := cert.Subject.CommonName
+		for n := 2; containsFold(aliases, alias); n++ {
+			alias = fmt.Sprintf("%s-%d", cert.Subject.CommonName, n)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	var data []byte
+	if input.Format == keyStorePKCS12 {
+		var entries []pkcs12.TrustStoreEntry
+		for i, cert := range chain {
+			entries = append(entries, pkcs12.TrustStoreEntry{Cert: cert, FriendlyName: aliases[i]})
+		}
+		data, err = pkcs12.Modern.EncodeTrustStoreEntries(entries, input.StorePassword)
+	} else {
+		var entries []javaKeyStoreEntry
+		for i, cert := range chain {
+			entries = append(entries, javaKeyStoreEntry{alias: aliases[i], certs: []*x509.Certificate{cert}})
+		}
+		data, err = encodeJavaKeyStore(input.Format, entries, input.StorePassword)
+	}
+	if err != nil {
+		return fmt.Sprintf("Error creating truststore: %v", err)
+	}
+
+	fileName := caName + ".truststore" + javaKeyStoreExtension[input.Format]
+	path := filepath.Join(outputDir, fileName)
+	if err := replaceFile(fileName, data, 0644); err != nil {
+		return fmt.Sprintf("Error saving truststore: %v", err)
+	}
+	return fmt.Sprintf("Success! Truststore written to '%s' with %d trusted certificate(s).", path, len(chain))
+}
+
+// checkKeyStoreInput defaults the format to JKS and checks the store
+// password and alias.
+func checkKeyStoreInput(input *KeyStoreInput) error {
+	if input.Format == "" {
+		input.Format = keyStoreJKS
+	}
+	if _, ok := javaKeyStoreExtension[input.Format]; !ok {
+		return fmt.Errorf("unknown keystore format '%s'; use jks, jceks or pkcs12", input.Format)
+	}
+	if len(input.StorePassword) < minPassphraseLength {
+		return fmt.Errorf("the store password must be at least %d characters", minPassphraseLength)
+	}
+	input.Alias = strings.TrimSpace(input.Alias)
+	return nil
+}
+
+// javaAlias returns an alias as Java stores it; JKS and JCEKS aliases are
+// case-insensitive and kept in lower case.
+func javaAlias(format string, alias string) string {
+	if format == keyStorePKCS12 {
+		return alias
+	}
+	return strings.ToLower(alias)
+}
+
+// encodeJavaKeyStore writes a JKS or JCEKS store holding entries, followed by
+// the keyed SHA-1 digest Java checks against the store password.
+func encodeJavaKeyStore(format string, entries []javaKeyStoreEntry, storePassword string) ([]byte, error) {
+	var buf bytes.Buffer
+	writeInt := func(v uint32) { binary.Write(&buf, binary.BigEndian, v) }
+	writeUTF := func(s string) error {
+		encoded := javaModifiedUTF8(s)
+		if len(encoded) > 0xffff {
+			return fmt.Errorf("'%s' is too long", s)
+		}
+		binary.Write(&buf, binary.BigEndian, uint16(len(encoded)))
+		buf.Write(encoded)
+		return nil
+	}
+	writeCert := func(cert *x509.Certificate) {
+		writeUTF("X.509")
+		writeInt(uint32(len(cert.Raw)))
+		buf.Write(cert.Raw)
+	}
+
+	if format == keyStoreJCEKS {
+		writeInt(0xcececece)
+	} else {
+		writeInt(0xfeedfeed)
+	}
+	writeInt(2) // Version
+	writeInt(uint32(len(entries)))
+	now := uint64(time.Now().UnixMilli())
+	for _, entry := range entries {
+		if entry.key != nil {
+			writeInt(1)
+		} else {
+			writeInt(2)
+		}
+		if err := writeUTF(javaAlias(format, entry.alias)); err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, binary.BigEndian, now)
+		if entry.key == nil {
+			writeCert(entry.certs[0])
+			continue
+		}
+		writeInt(uint32(len(entry.key)))
+		buf.Write(entry.key)
+		writeInt(uint32(len(entry.certs)))
+		for _, cert := range entry.certs {
+			writeCert(cert)
+		}
+	}
+
+	h := sha1.New()
+	h.Write(javaPassword(storePassword))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(buf.Bytes())
+	return h.Sum(buf.Bytes()), nil
+}
+
+// protectJKSKey encrypts a PKCS#8 key with Sun's JKS key protector: the key
+// is XORed with a SHA-1 keystream seeded by a salt read from random and
+// followed by a SHA-1 check of the plaintext.
+func protectJKSKey(random io.Reader, plainKey []byte, password string) ([]byte, error) {
+	passwordBytes := javaPassword(password)
+	salt := make([]byte, sha1.Size)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, err
+	}
+	protected := append([]byte{}, salt...)
+	digest := salt
+	for i := 0; i < len(plainKey); i += sha1.Size {
+		h := sha1.New()
+		h.Write(passwordBytes)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(plainKey); j++ {
+			protected = append(protected, plainKey[i+j]^digest[j])
+		}
+	}
+	check := sha1.New()
+	check.Write(passwordBytes)
+	check.Write(plainKey)
+	protected = check.Sum(protected)
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+}
+
+// protectJCEKSKey encrypts a PKCS#8 key with Sun's PBEWithMD5AndTripleDES,
+// the JCEKS key protection, with a salt read from random. password must be
+// printable ASCII.
+func protectJCEKSKey(random io.Reader, plainKey []byte, password string) ([]byte, error) {
+	params := pbeParams{Salt: make([]byte, 8), Iterations: jceksIterations}
+	// Java swaps the first half of a salt whose halves match; avoid such salts
+	for bytes.Equal(params.Salt[:4], params.Salt[4:]) {
+		if _, err := io.ReadFull(random, params.Salt); err != nil {
+			return nil, err
+		}
+	}
+	// Each salt half is hashed with the password; the results give the key and IV
+	var derived []byte
+	for half := 0; half < 2; half++ {
+		digest := params.Salt[4*half : 4*half+4]
+		for i := 0; i < params.Iterations; i++ {
+			h := md5.New()
+			h.Write(digest)
+			h.Write([]byte(password))
+			digest = h.Sum(nil)
+		}
+		derived = append(derived, digest...)
+	}
+	block, err := des.NewTripleDESCipher(derived[:24])
+	if err != nil {
+		return nil, err
+	}
+	padding := des.BlockSize - len(plainKey)%des.BlockSize
+	padded := append(plainKey[:len(plainKey):len(plainKey)], bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, derived[24:]).CryptBlocks(ciphertext, padded)
+
+	encodedParams, err := asn1.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBEWithMD5And3DES, Parameters: asn1.RawValue{FullBytes: encodedParams}},
+		EncryptedData: ciphertext,
+	})
+}
+
+// javaPassword returns a password as Java hashes it: UTF-16 big-endian
+// without a terminator.
+func javaPassword(password string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+// javaModifiedUTF8 encodes s as DataOutputStream.writeUTF does: UTF-16 code
+// units in up to three bytes each, with NUL as two bytes.
+func javaModifiedUTF8(s string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		switch {
+		case u >= 0x01 && u <= 0x7f:
+			out = append(out, byte(u))
+		case u <= 0x7ff:
+			out = append(out, byte(0xc0|u>>6), byte(0x80|u&0x3f))
+		default:
+			out = append(out, byte(0xe0|u>>12), byte(0x80|(u>>6)&0x3f), byte(0x80|u&0x3f))
+		}
+	}
+	return out
+}
+
+func printableASCII(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
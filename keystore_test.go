@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// The known answers below were computed independently, with Python's hashlib
+// for the SHA-1 and MD5 derivations and "openssl enc -des-ede3-cbc" for the
+// 3DES encryption.
+var (
+	testKeyStorePlainKey = func() []byte {
+		b := make([]byte, 40)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}()
+	testKeyStorePassword = "changeit"
+)
+
+// TestProtectJKSKey checks Sun's JKS key protector against a known answer.
+func TestProtectJKSKey(t *testing.T) {
+	salt, _ := hex.DecodeString("0102030405060708090a0b0c0d0e0f1011121314")
+	der, err := protectJKSKey(bytes.NewReader(salt), testKeyStorePlainKey, testKeyStorePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		t.Errorf("algorithm %s, want %s", info.Algorithm.Algorithm, oidJKSKeyProtector)
+	}
+	want := "0102030405060708090a0b0c0d0e0f1011121314" +
+		"6ab4e26021eab42e81051895b9566eddb2f00924" + "4afca5ee0fdeefd643f011f32bec3fb8ca788a7f" +
+		"9aac660004c2915d7d00c2a670b6a6d9399be464"
+	if got := hex.EncodeToString(info.EncryptedData); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// TestProtectJCEKSKey checks PBEWithMD5AndTripleDES against a known answer.
+func TestProtectJCEKSKey(t *testing.T) {
+	salt, _ := hex.DecodeString("0102030405060708")
+	der, err := protectJCEKSKey(bytes.NewReader(salt), testKeyStorePlainKey, testKeyStorePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		t.Fatal(err)
+	}
+	var params pbeParams
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBEWithMD5And3DES) || !bytes.Equal(params.Salt, salt) || params.Iterations != jceksIterations {
+		t.Errorf("got algorithm %s, salt %x and %d iterations", info.Algorithm.Algorithm, params.Salt, params.Iterations)
+	}
+	want := "40c296058453987c9bc171aa22fc35e032dddeea782e487a9bd23fbff7b4c1e9d386cf8d22af0c82258b7c3619a4b272"
+	if got := hex.EncodeToString(info.EncryptedData); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// TestEncodeJavaKeyStore checks the layout of a JKS truststore and the keyed
+// SHA-1 digest Java verifies it with.
+func TestEncodeJavaKeyStore(t *testing.T) {
+	root, _, _, _, _, _ := testHierarchy(t, "keystore.example.com")
+	data, err := encodeJavaKeyStore(keyStoreJKS, []javaKeyStoreEntry{{alias: "Root", certs: []*x509.Certificate{root}}}, testKeyStorePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+
+	// The digest is keyed with the password in UTF-16 big-endian
+	h := sha1.New()
+	h.Write([]byte("\x00c\x00h\x00a\x00n\x00g\x00e\x00i\x00t"))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(body)
+	if !bytes.Equal(h.Sum(nil), digest) {
+		t.Error("the keyed digest does not match the store")
+	}
+
+	var header struct{ Magic, Version, Count, Tag uint32 }
+	r := bytes.NewReader(body)
+	binary.Read(r, binary.BigEndian, &header)
+	if header != (struct{ Magic, Version, Count, Tag uint32 }{0xfeedfeed, 2, 1, 2}) {
+		t.Errorf("header %+v, want one trusted certificate entry in a version 2 JKS", header)
+	}
+	var aliasLength uint16
+	binary.Read(r, binary.BigEndian, &aliasLength)
+	alias := make([]byte, aliasLength)
+	r.Read(alias)
+	if string(alias) != "root" {
+		t.Errorf("alias '%s', want 'root'", alias)
+	}
+	if !bytes.HasSuffix(body, root.Raw) {
+		t.Error("the store does not end with the certificate")
+	}
+}
+
+// TestExportPKCS12KeyStore checks that a PKCS#12 keystore carries the chosen
+// alias, or the Common Name by default, as the friendly name of the key and
+// its certificate.
+func TestExportPKCS12KeyStore(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root, intermediate, leaf, _, _, leafKey := testHierarchy(t, "tomcat.example.com")
+	testStoreCA(t, "root", root, "")
+	testStoreCA(t, "intermediate", intermediate, "root")
+	testStoreCert(t, "tomcat.pem", leaf, leafKey, "intermediate")
+
+	for _, test := range []struct{ alias, want string }{{"Tomcat", "Tomcat"}, {"", "tomcat.example.com"}} {
+		result := (&App{}).ExportKeyStore(KeyStoreInput{CertName: "tomcat.pem", Format: keyStorePKCS12, Alias: test.alias, StorePassword: testKeyStorePassword})
+		if !strings.HasPrefix(result, "Success!") || !strings.Contains(result, "alias '"+test.want+"'") {
+			t.Fatalf("alias '%s': %s", test.alias, result)
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, "tomcat.keystore.p12"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, err := pkcs12.ToPEM(data, testKeyStorePassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		named := 0
+		for _, block := range blocks {
+			if block.Headers["localKeyId"] != "" {
+				named++
+				if got := block.Headers["friendlyName"]; got != test.want {
+					t.Errorf("alias '%s': %s bag has friendly name '%s', want '%s'", test.alias, block.Type, got, test.want)
+				}
+			}
+		}
+		if named != 2 {
+			t.Errorf("alias '%s': %d bags carry the key ID, want the key and its certificate", test.alias, named)
+		}
+	}
+}
@@ -14,9 +14,9 @@ var assets embed.FS
 
 // Constants for app logic
 const (
-	outputDir  = "output"
-	rsaBitsCA  = 4096
-	rsaBitsSrv = 2048
+	outputDir               = "output"
+	defaultCAKeyAlgorithm   = keyRSA4096
+	defaultCertKeyAlgorithm = keyRSA2048
 )
 
 func main() {
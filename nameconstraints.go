@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NameConstraintsInput lists the names a new CA may issue for. Empty lists
+// leave that kind of name unconstrained. Domains match themselves and their
+// subdomains; a leading "." matches subdomains only.
+type NameConstraintsInput struct {
+	PermittedDNS    []string `json:"permittedDns"`
+	ExcludedDNS     []string `json:"excludedDns"`
+	PermittedIPs    []string `json:"permittedIps"` // CIDRs
+	ExcludedIPs     []string `json:"excludedIps"`
+	PermittedEmails []string `json:"permittedEmails"` // Mailboxes or domains
+	ExcludedEmails  []string `json:"excludedEmails"`
+	PermittedURIs   []string `json:"permittedUris"` // URI host domains
+	ExcludedURIs    []string `json:"excludedUris"`
+}
+
+// apply checks the constraints and sets them on a CA template, marked critical.
+func (c NameConstraintsInput) apply(template *x509.Certificate) error {
+	var err error
+	if template.PermittedDNSDomains, err = constraintDomains(c.PermittedDNS); err != nil {
+		return err
+	}
+	if template.ExcludedDNSDomains, err = constraintDomains(c.ExcludedDNS); err != nil {
+		return err
+	}
+	if template.PermittedIPRanges, err = constraintRanges(c.PermittedIPs); err != nil {
+		return err
+	}
+	if template.ExcludedIPRanges, err = constraintRanges(c.ExcludedIPs); err != nil {
+		return err
+	}
+	if template.PermittedEmailAddresses, err = constraintEmails(c.PermittedEmails); err != nil {
+		return err
+	}
+	if template.ExcludedEmailAddresses, err = constraintEmails(c.ExcludedEmails); err != nil {
+		return err
+	}
+	if template.PermittedURIDomains, err = constraintDomains(c.PermittedURIs); err != nil {
+		return err
+	}
+	if template.ExcludedURIDomains, err = constraintDomains(c.ExcludedURIs); err != nil {
+		return err
+	}
+	template.PermittedDNSDomainsCritical = hasNameConstraints(template)
+	return nil
+}
+
+// hasNameConstraints reports whether a CA certificate constrains any names.
+func hasNameConstraints(cert *x509.Certificate) bool {
+	return len(cert.PermittedDNSDomains)+len(cert.ExcludedDNSDomains)+
+		len(cert.PermittedIPRanges)+len(cert.ExcludedIPRanges)+
+		len(cert.PermittedEmailAddresses)+len(cert.ExcludedEmailAddresses)+
+		len(cert.PermittedURIDomains)+len(cert.ExcludedURIDomains) > 0
+}
+
+func constraintDomains(values []string) ([]string, error) {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		dot := strings.HasPrefix(v, ".")
+		name, err := normalizeHostname(strings.TrimPrefix(v, "."))
+		if err != nil || strings.HasPrefix(name, "*.") {
+			return nil, fmt.Errorf("'%s' is not a valid domain constraint", v)
+		}
+		if dot {
+			name = "." + name
+		}
+		out = appendUnique(out, name)
+	}
+	return out, nil
+}
+
+func constraintRanges(values []string) ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		network, err := parseIPRange(v)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[network.String()] {
+			seen[network.String()] = true
+			out = append(out, network)
+		}
+	}
+	return out, nil
+}
+
+func constraintEmails(values []string) ([]string, error) {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if strings.Contains(v, "@") {
+			email, err := normalizeEmail(v)
+			if err != nil {
+				return nil, err
+			}
+			out = appendUnique(out, email)
+			continue
+		}
+		domains, err := constraintDomains([]string{v})
+		if err != nil {
+			return nil, err
+		}
+		out = appendUnique(out, domains[0])
+	}
+	return out, nil
+}
+
+// checkNameConstraints refuses a certificate for caName whose names fall
+// outside the name constraints of that CA or any CA above it.
+func checkNameConstraints(caName string, cn string, template *x509.Certificate) error {
+	chain, err := caChain(caName)
+	if err != nil {
+		return err
+	}
+	dnsNames, ips := certNames(cn, template)
+	for _, ca := range chain {
+		if !hasNameConstraints(ca) {
+			continue
+		}
+		for _, name := range dnsNames {
+			if !domainAllowed(name, ca.PermittedDNSDomains, ca.ExcludedDNSDomains) {
+				return constraintError("DNS name", name, ca)
+			}
+		}
+		for _, ip := range ips {
+			if !ipAllowed(ip, ca.PermittedIPRanges, ca.ExcludedIPRanges) {
+				return constraintError("IP address", ip.String(), ca)
+			}
+		}
+		for _, email := range template.EmailAddresses {
+			if !emailAllowed(email, ca.PermittedEmailAddresses, ca.ExcludedEmailAddresses) {
+				return constraintError("email address", email, ca)
+			}
+		}
+		for _, uri := range template.URIs {
+			if !uriAllowed(uri, ca.PermittedURIDomains, ca.ExcludedURIDomains) {
+				return constraintError("URI", uri.String(), ca)
+			}
+		}
+	}
+	return nil
+}
+
+func constraintError(kind, name string, ca *x509.Certificate) error {
+	return fmt.Errorf("%s '%s' is outside the names CA '%s' may issue for", kind, name, ca.Subject.CommonName)
+}
+
+// matchDomainConstraint reports whether host falls under a DNS constraint.
+func matchDomainConstraint(host, constraint string) bool {
+	host, constraint = strings.ToLower(host), strings.ToLower(constraint)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return underDomain(host, constraint)
+}
+
+// domainAllowed applies DNS constraints to a name. A wildcard is refused when
+// any name it covers is excluded.
+func domainAllowed(name string, permitted, excluded []string) bool {
+	host := strings.ToLower(name)
+	for _, c := range excluded {
+		if matchDomainConstraint(host, c) {
+			return false
+		}
+		// The wildcard covers one label under its base, so it also covers an
+		// excluded name that is one label under the base
+		if base, ok := strings.CutPrefix(host, "*."); ok && !strings.HasPrefix(c, ".") {
+			if label, ok := strings.CutSuffix(strings.ToLower(c), "."+base); ok && !strings.Contains(label, ".") {
+				return false
+			}
+		}
+	}
+	if len(permitted) == 0 {
+		return true
+	}
+	for _, c := range permitted {
+		if matchDomainConstraint(host, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipAllowed(ip net.IP, permitted, excluded []*net.IPNet) bool {
+	for _, network := range excluded {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	if len(permitted) == 0 {
+		return true
+	}
+	for _, network := range permitted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailAllowed applies email constraints, which name either a whole mailbox
+// or a domain.
+func emailAllowed(email string, permitted, excluded []string) bool {
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	match := func(c string) bool {
+		if strings.Contains(c, "@") {
+			return strings.EqualFold(c, email)
+		}
+		return matchDomainConstraint(domain, c)
+	}
+	for _, c := range excluded {
+		if match(c) {
+			return false
+		}
+	}
+	if len(permitted) == 0 {
+		return true
+	}
+	for _, c := range permitted {
+		if match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// uriAllowed applies URI constraints to the URI's host. A URI without a
+// host name cannot satisfy them.
+func uriAllowed(uri *url.URL, permitted, excluded []string) bool {
+	if len(permitted) == 0 && len(excluded) == 0 {
+		return true
+	}
+	host := uri.Hostname()
+	if host == "" || net.ParseIP(host) != nil {
+		return false
+	}
+	return domainAllowed(host, permitted, excluded)
+}
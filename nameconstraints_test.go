@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDomainAllowed checks DNS constraints that match a domain and its
+// subdomains, or with a leading dot its subdomains only.
+func TestDomainAllowed(t *testing.T) {
+	tests := []struct {
+		name                string
+		permitted, excluded []string
+		want                bool
+	}{
+		{"www.example.com", nil, nil, true},
+		{"example.com", []string{"example.com"}, nil, true},
+		{"www.example.com", []string{"example.com"}, nil, true},
+		{"WWW.Example.COM", []string{"Example.com"}, nil, true},
+		{"badexample.com", []string{"example.com"}, nil, false},
+		{"example.org", []string{"example.com"}, nil, false},
+		{"example.com", []string{".example.com"}, nil, false},
+		{"www.example.com", []string{".example.com"}, nil, true},
+		{"www.example.org", []string{"example.com", "example.org"}, nil, true},
+		{"secret.example.com", []string{"example.com"}, []string{"secret.example.com"}, false},
+		{"a.secret.example.com", []string{"example.com"}, []string{"secret.example.com"}, false},
+		{"www.example.com", []string{"example.com"}, []string{"secret.example.com"}, true},
+		{"example.com", nil, []string{".example.com"}, true},
+		{"www.example.com", nil, []string{".example.com"}, false},
+		// A wildcard is refused when any name it covers is excluded
+		{"*.example.com", []string{"example.com"}, []string{"secret.example.com"}, false},
+		{"*.example.com", nil, []string{".example.com"}, false},
+		{"*.example.com", nil, []string{"example.com"}, false},
+		{"*.example.com", nil, []string{"a.b.example.com"}, true},
+		{"*.example.com", nil, []string{".secret.example.com"}, true},
+		{"*.example.com", nil, []string{"com"}, false},
+		{"*.example.com", []string{".example.com"}, nil, true},
+	}
+	for _, tc := range tests {
+		permitted, err := constraintDomains(tc.permitted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		excluded, err := constraintDomains(tc.excluded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := domainAllowed(tc.name, permitted, excluded); got != tc.want {
+			t.Errorf("domainAllowed(%q, %v, %v) = %v, want %v", tc.name, tc.permitted, tc.excluded, got, tc.want)
+		}
+	}
+	for _, invalid := range []string{"*.example.com", "exa mple.com", "-example.com"} {
+		if _, err := constraintDomains([]string{invalid}); err == nil {
+			t.Errorf("domain constraint %q accepted", invalid)
+		}
+	}
+}
+
+// TestIPAllowed checks IP constraints given as CIDRs or single addresses.
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		ip                  string
+		permitted, excluded []string
+		want                bool
+	}{
+		{"192.0.2.1", nil, nil, true},
+		{"10.2.3.4", []string{"10.0.0.0/8"}, nil, true},
+		{"192.168.1.1", []string{"10.0.0.0/8"}, nil, false},
+		{"10.1.2.3", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, false},
+		{"10.2.3.4", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, true},
+		{"192.0.2.1", []string{"192.0.2.1"}, nil, true},
+		{"192.0.2.2", []string{"192.0.2.1"}, nil, false},
+		{"192.0.2.2", nil, []string{"192.0.2.1"}, true},
+		{"2001:db8::1", []string{"2001:db8::/32"}, nil, true},
+		{"2001:db9::1", []string{"2001:db8::/32"}, nil, false},
+		{"10.2.3.4", []string{"2001:db8::/32"}, nil, false},
+		{"2001:db8::1", []string{"10.0.0.0/8", "2001:db8::/32"}, []string{"2001:db8::1"}, false},
+	}
+	for _, tc := range tests {
+		permitted, err := constraintRanges(tc.permitted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		excluded, err := constraintRanges(tc.excluded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ipAllowed(net.ParseIP(tc.ip), permitted, excluded); got != tc.want {
+			t.Errorf("ipAllowed(%s, %v, %v) = %v, want %v", tc.ip, tc.permitted, tc.excluded, got, tc.want)
+		}
+	}
+	for _, invalid := range []string{"10.0.0.0/33", "10.0.0", "example.com"} {
+		if _, err := constraintRanges([]string{invalid}); err == nil {
+			t.Errorf("IP range %q accepted", invalid)
+		}
+	}
+}
+
+// TestEmailAllowed checks email constraints naming a mailbox, a host and its
+// subdomains, or with a leading dot the subdomains only.
+func TestEmailAllowed(t *testing.T) {
+	tests := []struct {
+		email               string
+		permitted, excluded []string
+		want                bool
+	}{
+		{"admin@example.com", nil, nil, true},
+		{"admin@example.com", []string{"admin@example.com"}, nil, true},
+		{"Admin@Example.com", []string{"admin@example.com"}, nil, true},
+		{"other@example.com", []string{"admin@example.com"}, nil, false},
+		{"admin@example.com", []string{"example.com"}, nil, true},
+		{"admin@mail.example.com", []string{"example.com"}, nil, true},
+		{"admin@example.org", []string{"example.com"}, nil, false},
+		{"admin@example.com", []string{".example.com"}, nil, false},
+		{"admin@mail.example.com", []string{".example.com"}, nil, true},
+		{"admin@example.com", []string{"example.com"}, []string{"admin@example.com"}, false},
+		{"other@example.com", []string{"example.com"}, []string{"admin@example.com"}, true},
+		{"admin@mail.example.com", []string{"example.com"}, []string{".example.com"}, false},
+	}
+	for _, tc := range tests {
+		permitted, err := constraintEmails(tc.permitted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		excluded, err := constraintEmails(tc.excluded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := emailAllowed(tc.email, permitted, excluded); got != tc.want {
+			t.Errorf("emailAllowed(%q, %v, %v) = %v, want %v", tc.email, tc.permitted, tc.excluded, got, tc.want)
+		}
+	}
+}
+
+// TestURIAllowed checks URI constraints, which apply to the URI's host name.
+func TestURIAllowed(t *testing.T) {
+	tests := []struct {
+		uri                 string
+		permitted, excluded []string
+		want                bool
+	}{
+		{"urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6", nil, nil, true},
+		{"https://www.example.com/path", []string{"example.com"}, nil, true},
+		{"https://Example.com:8443", []string{"example.com"}, nil, true},
+		{"https://www.example.org", []string{"example.com"}, nil, false},
+		{"https://example.com", []string{".example.com"}, nil, false},
+		{"https://api.internal.example.com", []string{"example.com"}, []string{".internal.example.com"}, false},
+		{"https://www.example.com", []string{"example.com"}, []string{".internal.example.com"}, true},
+		// Without a host name the URI cannot satisfy any constraint
+		{"urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6", []string{"example.com"}, nil, false},
+		{"https://192.0.2.1/", nil, []string{"example.com"}, false},
+	}
+	for _, tc := range tests {
+		uri, err := url.Parse(tc.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := uriAllowed(uri, tc.permitted, tc.excluded); got != tc.want {
+			t.Errorf("uriAllowed(%s, %v, %v) = %v, want %v", tc.uri, tc.permitted, tc.excluded, got, tc.want)
+		}
+	}
+}
+
+// TestCheckNameConstraints checks that a certificate must satisfy the name
+// constraints of its CA and of every CA above it.
+func TestCheckNameConstraints(t *testing.T) {
+	t.Chdir(t.TempDir())
+	rootTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	err := NameConstraintsInput{
+		PermittedDNS:    []string{"example.com"},
+		ExcludedDNS:     []string{"secret.example.com"},
+		PermittedIPs:    []string{"10.0.0.0/8"},
+		PermittedEmails: []string{"example.com"},
+	}.apply(rootTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, rootKey := testIssue(t, rootTemplate, nil, nil)
+	// The intermediate names more than the root allows and narrows it too
+	intermediateTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	err = NameConstraintsInput{
+		PermittedDNS:  []string{"example.com", "example.org"},
+		ExcludedIPs:   []string{"10.1.0.0/16"},
+		PermittedURIs: []string{"example.com"},
+	}.apply(intermediateTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, _ := testIssue(t, intermediateTemplate, root, rootKey)
+	if !intermediate.PermittedDNSDomainsCritical {
+		t.Error("the name constraints are not critical")
+	}
+	testStoreCA(t, "root", root, "")
+	testStoreCA(t, "intermediate", intermediate, "root")
+	unconstrained, _ := testIssue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Unconstrained CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	testStoreCA(t, "unconstrained", unconstrained, "")
+
+	tests := []struct {
+		cn       string
+		dnsNames []string
+		ips      []string
+		emails   []string
+		uris     []string
+		refusal  string // Empty when the certificate is allowed
+	}{
+		{cn: "www.example.com", dnsNames: []string{"api.example.com"}, ips: []string{"10.2.0.1"}, emails: []string{"admin@example.com"}, uris: []string{"https://www.example.com"}},
+		{cn: "Web server", dnsNames: []string{"www.example.com"}},
+		{cn: "secret.example.com", refusal: "DNS name 'secret.example.com' is outside the names CA 'Test Root CA' may issue for"},
+		{cn: "www.example.com", dnsNames: []string{"www.example.org"}, refusal: "DNS name 'www.example.org' is outside the names CA 'Test Root CA' may issue for"},
+		{cn: "www.example.net", refusal: "DNS name 'www.example.net' is outside the names CA 'Test Intermediate CA' may issue for"},
+		{cn: "10.1.2.3", refusal: "IP address '10.1.2.3' is outside the names CA 'Test Intermediate CA' may issue for"},
+		{cn: "www.example.com", ips: []string{"192.168.1.1"}, refusal: "IP address '192.168.1.1' is outside the names CA 'Test Root CA' may issue for"},
+		{cn: "www.example.com", emails: []string{"admin@example.org"}, refusal: "email address 'admin@example.org' is outside the names CA 'Test Root CA' may issue for"},
+		{cn: "www.example.com", uris: []string{"https://www.example.org"}, refusal: "URI 'https://www.example.org' is outside the names CA 'Test Intermediate CA' may issue for"},
+	}
+	for _, tc := range tests {
+		template := &x509.Certificate{DNSNames: tc.dnsNames, EmailAddresses: tc.emails}
+		for _, ip := range tc.ips {
+			template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+		}
+		for _, u := range tc.uris {
+			uri, _ := url.Parse(u)
+			template.URIs = append(template.URIs, uri)
+		}
+		err := checkNameConstraints("intermediate", tc.cn, template)
+		switch {
+		case tc.refusal == "" && err != nil:
+			t.Errorf("%s %v: %v", tc.cn, template.DNSNames, err)
+		case tc.refusal != "" && (err == nil || !strings.Contains(err.Error(), tc.refusal)):
+			t.Errorf("%s: got %v, want %q", tc.cn, err, tc.refusal)
+		}
+		// A CA without constraints allows every name
+		if err := checkNameConstraints("unconstrained", tc.cn, template); err != nil {
+			t.Errorf("unconstrained CA refused %s: %v", tc.cn, err)
+		}
+	}
+	if err := checkNameConstraints("missing", "www.example.com", &x509.Certificate{}); err == nil {
+		t.Error("a CA that is not in the store was accepted")
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspResponseValidity is how long a signed OCSP response may be cached.
+	ocspResponseValidity = 24 * time.Hour
+	// ocspSignerValidityDays is the lifetime of an automatically issued delegated signer.
+	ocspSignerValidityDays = 30
+	// ocspSignerRenewBefore renews a delegated signer when it is this close to expiry.
+	ocspSignerRenewBefore = 7 * 24 * time.Hour
+)
+
+// oidOCSPNoCheck marks a delegated OCSP signer as not needing its own revocation check (RFC 6960, 4.2.2.2.1).
+var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+// StartOCSPResponder starts a local HTTP OCSP responder on addr (e.g. "127.0.0.1:8888")
+// answering for every CA in the output folder.
+func (a *App) StartOCSPResponder(addr string) string {
+	if addr == "" {
+		return "Error: You must enter an address to listen on, e.g. 127.0.0.1:8888."
+	}
+	a.ocspMu.Lock()
+	defer a.ocspMu.Unlock()
+	if a.ocspServer != nil {
+		return fmt.Sprintf("Error: The OCSP responder is already running on %s.", a.ocspServer.Addr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Sprintf("Error starting OCSP responder: %v", err)
+	}
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           http.HandlerFunc(handleOCSP),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("OCSP responder stopped: %v", err)
+		}
+	}()
+	a.ocspServer = server
+	return fmt.Sprintf("Success! OCSP responder listening on http://%s/", server.Addr)
+}
+
+// StopOCSPResponder stops the local OCSP responder if it is running.
+func (a *App) StopOCSPResponder() string {
+	a.ocspMu.Lock()
+	defer a.ocspMu.Unlock()
+	if a.ocspServer == nil {
+		return "Error: The OCSP responder is not running."
+	}
+	err := a.ocspServer.Close()
+	a.ocspServer = nil
+	if err != nil {
+		return fmt.Sprintf("Error stopping OCSP responder: %v", err)
+	}
+	return "Success! OCSP responder stopped."
+}
+
+// OCSPResponderAddress returns the address the OCSP responder listens on, or "" when stopped.
+func (a *App) OCSPResponderAddress() string {
+	a.ocspMu.Lock()
+	defer a.ocspMu.Unlock()
+	if a.ocspServer == nil {
+		return ""
+	}
+	return a.ocspServer.Addr
+}
+
+// WriteOCSPStaple writes a pre-signed OCSP response for a certificate next to it
+// as <name>.ocsp, suitable for nginx's ssl_stapling_file.
+func (a *App) WriteOCSPStaple(certName string) string {
+	cert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+	caName := issuerName(cert)
+	caCert, err := loadCert(caName + ".pem")
+	if err != nil {
+		return fmt.Sprintf("Error loading issuing CA '%s': %v", caName, err)
+	}
+
+	response, err := buildOCSPResponse(caName, caCert, cert.SerialNumber, crypto.SHA1)
+	if err != nil {
+		return fmt.Sprintf("Error creating OCSP response: %v", err)
+	}
+	staplePath := filepath.Join(outputDir, strings.TrimSuffix(certName, ".pem")+".ocsp")
+	if err := os.WriteFile(staplePath, response, 0644); err != nil {
+		return fmt.Sprintf("Error saving OCSP response: %v", err)
+	}
+	return fmt.Sprintf("Success! OCSP response written to '%s'. It is valid until %s.", staplePath, time.Now().Add(ocspResponseValidity).Format(time.RFC1123))
+}
+
+// handleOCSP answers OCSP requests sent by GET (base64 in the path) or POST.
+func handleOCSP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		body, err = ocspRequestFromPath(r.URL.EscapedPath())
+	case http.MethodPost:
+		body, err = io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeOCSP(w, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	req, err := ocsp.ParseRequest(body)
+	if err != nil {
+		writeOCSP(w, ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	caName, caCert := findOCSPIssuer(req)
+	if caCert == nil {
+		writeOCSP(w, ocsp.UnauthorizedErrorResponse)
+		return
+	}
+	response, err := buildOCSPResponse(caName, caCert, req.SerialNumber, req.HashAlgorithm)
+	if err != nil {
+		log.Printf("OCSP: could not answer for CA '%s': %v", caName, err)
+		writeOCSP(w, ocsp.InternalErrorErrorResponse)
+		return
+	}
+	writeOCSP(w, response)
+}
+
+// ocspRequestFromPath finds the base64 request at the end of a GET path. The
+// responder may be published under a path, such as /ocsp, and clients do not
+// always escape the slashes base64 can contain, so the text after each slash
+// is tried in turn.
+func ocspRequestFromPath(escapedPath string) ([]byte, error) {
+	for i := 0; i < len(escapedPath); i++ {
+		if escapedPath[i] != '/' {
+			continue
+		}
+		raw, err := url.PathUnescape(escapedPath[i+1:])
+		if err != nil {
+			continue
+		}
+		if der, err := base64.StdEncoding.DecodeString(raw); err == nil {
+			if _, err := ocsp.ParseRequest(der); err == nil {
+				return der, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no OCSP request found in '%s'", escapedPath)
+}
+
+func writeOCSP(w http.ResponseWriter, response []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(response)
+}
+
+// findOCSPIssuer finds the CA in the store matching the request's issuer hashes.
+func findOCSPIssuer(req *ocsp.Request) (string, *x509.Certificate) {
+	if !req.HashAlgorithm.Available() {
+		return "", nil
+	}
+	for name, ca := range storeCAs() {
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(ca.cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+			continue
+		}
+		h := req.HashAlgorithm.New()
+		h.Write(ca.cert.RawSubject)
+		nameHash := h.Sum(nil)
+		h.Reset()
+		h.Write(spki.PublicKey.RightAlign())
+		keyHash := h.Sum(nil)
+		if bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(keyHash, req.IssuerKeyHash) {
+			return name, ca.cert
+		}
+	}
+	return "", nil
+}
+
+// buildOCSPResponse signs an OCSP response for serial as issued by the named CA.
+func buildOCSPResponse(caName string, caCert *x509.Certificate, serial *big.Int, issuerHash crypto.Hash) ([]byte, error) {
+	now := time.Now()
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: serial,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(ocspResponseValidity),
+		IssuerHash:   issuerHash,
+	}
+
+	revocationMu.Lock()
+	reg, err := loadRevocations()
+	revocationMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if i := reg.find(caName, serial); i >= 0 {
+		entry := reg.Entries[i]
+		template.Status = ocsp.Revoked
+		template.RevokedAt = entry.RevokedAt
+		template.RevocationReason = entry.ReasonCode
+	} else if issuedBy(caName, serial) {
+		template.Status = ocsp.Good
+	}
+
+	signerCert, signerKey, err := ocspSigner(caName, caCert)
+	if err != nil {
+		return nil, err
+	}
+	if !signerCert.Equal(caCert) {
+		template.Certificate = signerCert
+	}
+	return ocsp.CreateResponse(caCert, signerCert, template, signerKey)
+}
+
+// issuedBy reports whether the inventory holds a certificate with the given
+// serial issued by the named CA.
+func issuedBy(caName string, serial *big.Int) bool {
+	hexSerial := serial.Text(16)
+	for _, kind := range []string{kindCert, kindCA} {
+		for _, r := range inventoryRecords(kind) {
+			if r.Issuer == caName && r.Serial == hexSerial {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ocspSignerLocks holds a lock per CA so that concurrent requests check and
+// renew its delegated signer one at a time; ocspSignerLocksMu guards the map.
+var (
+	ocspSignerLocksMu sync.Mutex
+	ocspSignerLocks   = make(map[string]*sync.Mutex)
+)
+
+// ocspSignerLock returns the lock serialising the delegated signer of a CA.
+func ocspSignerLock(caName string) *sync.Mutex {
+	ocspSignerLocksMu.Lock()
+	defer ocspSignerLocksMu.Unlock()
+	lock, ok := ocspSignerLocks[caName]
+	if !ok {
+		lock = new(sync.Mutex)
+		ocspSignerLocks[caName] = lock
+	}
+	return lock
+}
+
+// ocspSigner returns the certificate and key used to sign OCSP responses for a
+// CA: the CA itself, or a delegated OCSP signer when the CA is configured for
+// one (always the case for Ed25519 CAs, which OCSP cannot sign with directly).
+// The signer key of an encrypted or token CA is never written to disk; it is
+// held for the unlock session like the CA key and reissued after it ends.
+func ocspSigner(caName string, caCert *x509.Certificate) (*x509.Certificate, crypto.Signer, error) {
+	if !loadCASettings(caName).OCSPDelegated && caCert.PublicKeyAlgorithm != x509.Ed25519 {
+		cert, key, err := loadCA(caName)
+		return cert, key, err
+	}
+
+	lock := ocspSignerLock(caName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	certFile := caName + ".ocsp-signer.pem"
+	keyFile := caName + ".ocsp-signer.key"
+	protected := keyEncrypted(caName+".key") || keyOnToken(caName+".key")
+	if cert, err := loadCert(certFile); err == nil && time.Until(cert.NotAfter) > ocspSignerRenewBefore && cert.CheckSignatureFrom(caCert) == nil {
+		var key crypto.Signer
+		if protected {
+			key = sessionKey(keyFile)
+		} else if keyData, err := os.ReadFile(filepath.Join(outputDir, keyFile)); err == nil {
+			if block, _ := pem.Decode(keyData); block != nil {
+				key, _ = parsePrivateKey(block.Bytes)
+			}
+		}
+		if key != nil && publicKeysEqual(key.Public(), cert.PublicKey) {
+			return cert, key, nil
+		}
+	}
+	return issueOCSPSigner(caName, certFile, keyFile, protected)
+}
+
+// issueOCSPSigner creates a short-lived delegated OCSP signing certificate for
+// a CA, saved as certFile and keyFile. With inMemory the key is held for the
+// unlock session instead of being saved, and any key file left from before the
+// CA was encrypted is removed. The caller holds the CA's ocspSignerLock.
+func issueOCSPSigner(caName, certFile, keyFile string, inMemory bool) (*x509.Certificate, crypto.Signer, error) {
+	caCert, caKey, err := loadCA(caName)
+	if err != nil {
+		return nil, nil, err
+	}
+	// OCSP responses can only be signed with RSA or ECDSA keys
+	signerKey, err := generateKey(keyECDSAP256, keyECDSAP256)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := newSerialNumber(caName)
+	if err != nil {
+		return nil, nil, err
+	}
+	nullValue, _ := asn1.Marshal(asn1.NullRawValue)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("%s OCSP Signer", caCert.Subject.CommonName)},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().AddDate(0, 0, ocspSignerValidityDays),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidOCSPNoCheck, Value: nullValue},
+		},
+	}
+	if template.NotAfter.After(caCert.NotAfter) {
+		template.NotAfter = caCert.NotAfter
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, signerKey.Public(), caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Signers are not listed in the inventory, but their serials must not be reused
+	if err := recordSerial(caName, serial.Text(16)); err != nil {
+		return nil, nil, err
+	}
+
+	if inMemory {
+		if err := os.Remove(filepath.Join(outputDir, keyFile)); err != nil && !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("could not remove plaintext OCSP signer key: %w", err)
+		}
+		holdKey(keyFile, signerKey)
+	} else if err := writeKeyPEM(keyFile, signerKey, ""); err != nil {
+		return nil, nil, fmt.Errorf("could not save OCSP signer key: %w", err)
+	}
+	if err := replaceFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0644); err != nil {
+		return nil, nil, fmt.Errorf("could not save OCSP signer certificate: %w", err)
+	}
+	return cert, signerKey, nil
+}
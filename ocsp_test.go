@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// TestOCSPRequestFromPath finds a GET request under the root and under a
+// responder path, with its slashes escaped or not.
+func TestOCSPRequestFromPath(t *testing.T) {
+	_, intermediate, leaf, _, _, _ := testHierarchy(t, "ocsp.example.com")
+	der, err := ocsp.CreateRequest(leaf, intermediate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(der)
+	for _, path := range []string{
+		"/" + encoded,
+		"/" + url.PathEscape(encoded),
+		"/ocsp/" + encoded,
+		"/pki/ocsp/" + url.PathEscape(encoded),
+	} {
+		got, err := ocspRequestFromPath(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if !bytes.Equal(got, der) {
+			t.Errorf("%s: got a different request", path)
+		}
+	}
+	if _, err := ocspRequestFromPath("/ocsp/"); err == nil {
+		t.Error("an empty path was accepted")
+	}
+}
+
+// TestOCSPSignerSerialRecorded checks that a delegated signer's serial is
+// never handed out again by its CA.
+func TestOCSPSignerSerialRecorded(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root, _, _, rootKey, _, _ := testHierarchy(t, "ocsp.example.com")
+	testStoreCA(t, "root", root, "")
+	if err := writeKeyPEM("root.key", rootKey, ""); err != nil {
+		t.Fatal(err)
+	}
+	signer, _, err := issueOCSPSigner("root", "root.ocsp-signer.pem", "root.ocsp-signer.key", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !usedSerials("root")[signer.SerialNumber.Text(16)] {
+		t.Error("the OCSP signer's serial is free for reuse")
+	}
+}
+
+// TestOCSPSignerRenewal checks that concurrent requests share one delegated
+// signer, and that a signer whose saved key does not match is reissued.
+func TestOCSPSignerRenewal(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// The CA outlives the signer's renewal window
+	root, rootKey := testIssue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	testStoreCA(t, "root", root, "")
+	if err := writeKeyPEM("root.key", rootKey, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateCASettings("root", func(s *CASettings) error { s.OCSPDelegated = true; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	signers := make([]*x509.Certificate, 8)
+	var wg sync.WaitGroup
+	for i := range signers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cert, key, err := ocspSigner("root", root)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !publicKeysEqual(key.Public(), cert.PublicKey) {
+				t.Error("the signer key does not match its certificate")
+			}
+			signers[i] = cert
+		}()
+	}
+	wg.Wait()
+	for _, cert := range signers {
+		if cert != nil && !cert.Equal(signers[0]) {
+			t.Fatal("concurrent requests issued more than one signer")
+		}
+	}
+
+	// A key left from another signer is not used with this certificate
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeKeyPEM("root.ocsp-signer.key", other, ""); err != nil {
+		t.Fatal(err)
+	}
+	cert, key, err := ocspSigner("root", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Equal(signers[0]) || !publicKeysEqual(key.Public(), cert.PublicKey) {
+		t.Error("the mismatched signer was not reissued")
+	}
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"math/bits"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// PFX encryption choices.
+const (
+	pfxModern    = "modern"     // PBES2 with PBKDF2 and AES-256-CBC, SHA-256 MAC; Windows Server 2019+, Java 12+, OpenSSL 3
+	pfxLegacy    = "legacy"     // RC2-40 certificates, 3DES key, SHA-1 MAC; what older Windows and Java expect
+	pfxLegacyDES = "legacy-des" // 3DES certificates and key, SHA-1 MAC; old systems that refuse RC2
+)
+
+// pfxIterations is the KDF and MAC work factor, the OpenSSL default. PFX
+// files are short-lived transport files and some importers are slow at
+// higher counts.
+const pfxIterations = 2048
+
+// Object identifiers from PKCS#7 and PKCS#12 (RFC 7292).
+var (
+	oidPKCS7EncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidPBEWithSHA3DES     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHARC2_40   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509CertificateBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA1               = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// PFXInput selects a certificate and how to package it as a PFX/P12 file.
+type PFXInput struct {
+	CertName     string `json:"certName"`
+	Password     string `json:"password"`     // A strong password is generated when empty
+	Encryption   string `json:"encryption"`   // modern (default), legacy or legacy-des
+	FriendlyName string `json:"friendlyName"` // Alias shown by Windows and Java; defaults to the Common Name
+	LeafOnly     bool   `json:"leafOnly"`     // Leave out the issuing CAs
+}
+
+type pfxAttribute struct {
+	ID     asn1.ObjectIdentifier
+	Values asn1.RawValue // SET OF
+}
+
+type pfxSafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue  // [0] EXPLICIT
+	Attributes []pfxAttribute `asn1:"set,optional"`
+}
+
+type pfxContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type pfxEncryptedData struct {
+	Version              int
+	EncryptedContentInfo struct {
+		ContentType      asn1.ObjectIdentifier
+		Algorithm        pkix.AlgorithmIdentifier
+		EncryptedContent asn1.RawValue // [0] IMPLICIT OCTET STRING
+	}
+}
+
+type pfxMacData struct {
+	Mac struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+	Salt       []byte
+	Iterations int
+}
+
+// pfxEncoding holds the algorithms one of the encryption choices uses.
+type pfxEncoding struct {
+	certs, key asn1.ObjectIdentifier // oidPBES2 or a PKCS#12 PBE scheme
+	mac        func() hash.Hash
+	macOID     asn1.ObjectIdentifier
+}
+
+var pfxEncodings = map[string]pfxEncoding{
+	pfxModern:    {certs: oidPBES2, key: oidPBES2, mac: sha256.New, macOID: oidSHA256},
+	pfxLegacy:    {certs: oidPBEWithSHARC2_40, key: oidPBEWithSHA3DES, mac: sha1.New, macOID: oidSHA1},
+	pfxLegacyDES: {certs: oidPBEWithSHA3DES, key: oidPBEWithSHA3DES, mac: sha1.New, macOID: oidSHA1},
+}
+
+// ExportToPFX exports a certificate and its key to a PFX/P12 file, readable
+// only by the owner since it holds the private key. When no password is given
+// a random one is generated and returned in the result.
+func (a *App) ExportToPFX(input PFXInput) string {
+	certName := input.CertName
+	if certName == "" {
+		return "Error: You must select a certificate to export."
+	}
+	if input.Encryption == "" {
+		input.Encryption = pfxModern
+	}
+	encoding, ok := pfxEncodings[input.Encryption]
+	if !ok {
+		return fmt.Sprintf("Error: Unknown PFX encryption '%s'; use modern, legacy or legacy-des.", input.Encryption)
+	}
+	password, generated := input.Password, false
+	if password == "" {
+		var err error
+		if password, err = generatePassword(); err != nil {
+			return fmt.Sprintf("Error generating password: %v", err)
+		}
+		generated = true
+	} else if len(password) < minPassphraseLength {
+		return fmt.Sprintf("Error: The PFX password must be at least %d characters.", minPassphraseLength)
+	}
+
+	// Load the device certificate
+	cert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+
+	// Load the device's private key
+	keyFile := certKeyFile(certName)
+	if keyFile == "" {
+		return fmt.Sprintf("Error: Certificate '%s' has no private key.", certName)
+	}
+	privateKey, err := loadPrivateKey(keyFile)
+	if err != nil {
+		return fmt.Sprintf("Error loading private key: %v", err)
+	}
+
+	// Load the issuing CA and any intermediates above it to include in the chain
+	var chain []*x509.Certificate
+	if !input.LeafOnly {
+		if chain, err = issuerChain(certName, cert); err != nil {
+			return fmt.Sprintf("Error loading issuing CA '%s': %v", cert.Issuer.CommonName, err)
+		}
+	}
+
+	friendlyName := strings.TrimSpace(input.FriendlyName)
+	if friendlyName == "" {
+		friendlyName = cert.Subject.CommonName
+	}
+	pfxData, err := encodePFX(encoding, privateKey, cert, chain, friendlyName, password)
+	if err != nil {
+		return fmt.Sprintf("Error creating PFX file: %v", err)
+	}
+
+	pfxFile := strings.TrimSuffix(certName, ".pem") + ".pfx"
+	pfxPath := filepath.Join(outputDir, pfxFile)
+	if err := replaceFile(pfxFile, pfxData, 0600); err != nil {
+		return fmt.Sprintf("Error saving PFX file: %v", err)
+	}
+
+	if generated {
+		return fmt.Sprintf("Success! Exported to '%s'. Its password is: %s", pfxPath, password)
+	}
+	return fmt.Sprintf("Success! Exported to '%s'.", pfxPath)
+}
+
+// generatePassword returns a random password of 24 URL-safe characters.
+func generatePassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// encodePFX builds a password-protected PKCS#12 file holding key, cert and
+// the CA certificates in chain. The key and leaf certificate share a
+// friendly name and local key ID so importers pair them; the CAs are named
+// after their Common Names.
+func encodePFX(encoding pfxEncoding, key crypto.Signer, cert *x509.Certificate, chain []*x509.Certificate, friendlyName string, password string) ([]byte, error) {
+	keyID := sha1.Sum(cert.Raw)
+	leafAttributes, err := pfxAttributes(friendlyName, keyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificates go in an encrypted safe
+	var certBags []pfxSafeBag
+	for i, c := range append([]*x509.Certificate{cert}, chain...) {
+		attributes := leafAttributes
+		if i > 0 {
+			if attributes, err = pfxAttributes(c.Subject.CommonName, nil); err != nil {
+				return nil, err
+			}
+		}
+		bag, err := pfxCertBag(c, attributes)
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, bag)
+	}
+	certSafe, err := pfxEncryptedSafe(encoding, certBags, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key goes in a plain safe as a shrouded (encrypted) key bag
+	plainKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, ciphertext, err := pfxEncrypt(encoding.key, plainKey, password)
+	if err != nil {
+		return nil, err
+	}
+	shroudedKey, err := asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: algorithm, EncryptedData: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	keySafe, err := asn1.Marshal([]pfxSafeBag{{ID: oidShroudedKeyBag, Value: explicitContent(shroudedKey), Attributes: leafAttributes}})
+	if err != nil {
+		return nil, err
+	}
+	keySafeOctets, err := asn1.Marshal(keySafe)
+	if err != nil {
+		return nil, err
+	}
+
+	return sealPFX(encoding, []pfxContentInfo{certSafe, {ContentType: oidPKCS7Data, Content: explicitContent(keySafeOctets)}}, password)
+}
+
+// pfxCertBag wraps a certificate in a certBag.
+func pfxCertBag(cert *x509.Certificate, attributes []pfxAttribute) (pfxSafeBag, error) {
+	certValue, err := asn1.Marshal(cert.Raw)
+	if err != nil {
+		return pfxSafeBag{}, err
+	}
+	bagValue, err := asn1.Marshal(struct {
+		ID    asn1.ObjectIdentifier
+		Value asn1.RawValue
+	}{oidX509CertificateBag, explicitContent(certValue)})
+	if err != nil {
+		return pfxSafeBag{}, err
+	}
+	return pfxSafeBag{ID: oidCertBag, Value: explicitContent(bagValue), Attributes: attributes}, nil
+}
+
+// pfxEncryptedSafe encrypts bags as a password-encrypted safe.
+func pfxEncryptedSafe(encoding pfxEncoding, bags []pfxSafeBag, password string) (pfxContentInfo, error) {
+	safe, err := asn1.Marshal(bags)
+	if err != nil {
+		return pfxContentInfo{}, err
+	}
+	algorithm, ciphertext, err := pfxEncrypt(encoding.certs, safe, password)
+	if err != nil {
+		return pfxContentInfo{}, err
+	}
+	var encrypted pfxEncryptedData
+	encrypted.EncryptedContentInfo.ContentType = oidPKCS7Data
+	encrypted.EncryptedContentInfo.Algorithm = algorithm
+	encrypted.EncryptedContentInfo.EncryptedContent = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext}
+	encryptedDER, err := asn1.Marshal(encrypted)
+	if err != nil {
+		return pfxContentInfo{}, err
+	}
+	return pfxContentInfo{ContentType: oidPKCS7EncryptedData, Content: explicitContent(encryptedDER)}, nil
+}
+
+// sealPFX wraps safes in a PFX whose MAC covers them with a key derived
+// from the password.
+func sealPFX(encoding pfxEncoding, safes []pfxContentInfo, password string) ([]byte, error) {
+	authSafe, err := asn1.Marshal(safes)
+	if err != nil {
+		return nil, err
+	}
+	authSafeOctets, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	var macData pfxMacData
+	macData.Salt = make([]byte, 16)
+	if _, err := rand.Read(macData.Salt); err != nil {
+		return nil, err
+	}
+	macData.Iterations = pfxIterations
+	macKey := pkcs12KDF(encoding.mac, bmpPassword(password), macData.Salt, pfxIterations, 3, encoding.mac().Size())
+	mac := hmac.New(encoding.mac, macKey)
+	mac.Write(authSafe)
+	macData.Mac.Algorithm = pkix.AlgorithmIdentifier{Algorithm: encoding.macOID, Parameters: asn1.NullRawValue}
+	macData.Mac.Digest = mac.Sum(nil)
+
+	return asn1.Marshal(struct {
+		Version  int
+		AuthSafe pfxContentInfo
+		MacData  pfxMacData
+	}{3, pfxContentInfo{ContentType: oidPKCS7Data, Content: explicitContent(authSafeOctets)}, macData})
+}
+
+// pfxAttributes returns the friendlyName and, when keyID is set, localKeyID
+// bag attributes.
+func pfxAttributes(friendlyName string, keyID []byte) ([]pfxAttribute, error) {
+	var attributes []pfxAttribute
+	if friendlyName != "" {
+		name := utf16.Encode([]rune(friendlyName))
+		bmp := make([]byte, 0, 2*len(name))
+		for _, r := range name {
+			bmp = append(bmp, byte(r>>8), byte(r))
+		}
+		value, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagBMPString, Bytes: bmp})
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, pfxAttribute{ID: oidFriendlyName, Values: setOf(value)})
+	}
+	if keyID != nil {
+		value, err := asn1.Marshal(keyID)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, pfxAttribute{ID: oidLocalKeyID, Values: setOf(value)})
+	}
+	return attributes, nil
+}
+
+func explicitContent(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func setOf(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der}
+}
+
+// pfxEncrypt encrypts plaintext with PBES2 (whose passwords are UTF-8) or one
+// of the PKCS#12 PBE schemes (whose passwords are BMPStrings).
+func pfxEncrypt(scheme asn1.ObjectIdentifier, plaintext []byte, password string) (pkix.AlgorithmIdentifier, []byte, error) {
+	if scheme.Equal(oidPBES2) {
+		return pbes2Encrypt(plaintext, []byte(password), pfxIterations)
+	}
+	var none pkix.AlgorithmIdentifier
+	params := pbeParams{Salt: make([]byte, 8), Iterations: pfxIterations}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return none, nil, err
+	}
+	encodedParams, err := asn1.Marshal(params)
+	if err != nil {
+		return none, nil, err
+	}
+	bmp := bmpPassword(password)
+	// Both ciphers have 8-byte blocks
+	var encryptBlock func(dst, src []byte)
+	if scheme.Equal(oidPBEWithSHARC2_40) {
+		encryptBlock = newRC2(pkcs12KDF(sha1.New, bmp, params.Salt, params.Iterations, 1, 5), 40).encryptBlock
+	} else {
+		block, err := des.NewTripleDESCipher(pkcs12KDF(sha1.New, bmp, params.Salt, params.Iterations, 1, 24))
+		if err != nil {
+			return none, nil, err
+		}
+		encryptBlock = block.Encrypt
+	}
+	iv := pkcs12KDF(sha1.New, bmp, params.Salt, params.Iterations, 2, des.BlockSize)
+
+	padding := des.BlockSize - len(plaintext)%des.BlockSize
+	padded := append(plaintext[:len(plaintext):len(plaintext)], bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := cbcEncrypt(encryptBlock, iv, padded)
+	return pkix.AlgorithmIdentifier{Algorithm: scheme, Parameters: asn1.RawValue{FullBytes: encodedParams}}, ciphertext, nil
+}
+
+// bmpPassword encodes a password as a null-terminated BMPString, as the
+// PKCS#12 key derivation expects.
+func bmpPassword(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	bmp := make([]byte, 0, 2*len(units)+2)
+	for _, u := range units {
+		bmp = append(bmp, byte(u>>8), byte(u))
+	}
+	return append(bmp, 0, 0)
+}
+
+// pkcs12KDF derives size bytes of key material (id 1), IV (id 2) or MAC
+// key (id 3) from a password, as in RFC 7292 appendix B.2.
+func pkcs12KDF(newHash func() hash.Hash, password, salt []byte, iterations int, id byte, size int) []byte {
+	const v = 64 // Block size of SHA-1 and SHA-256
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	input := append(fill(salt), fill(password)...)
+	diversifier := bytes.Repeat([]byte{id}, v)
+
+	h := newHash()
+	var out []byte
+	for {
+		h.Reset()
+		h.Write(diversifier)
+		h.Write(input)
+		a := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(nil)
+		}
+		if out = append(out, a...); len(out) >= size {
+			return out[:size]
+		}
+		// Add a (repeated to v bytes) plus one to each v-byte block of the input
+		b := fill(a)
+		for j := 0; j < len(input); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(input[j+k]) + int(b[k]) + carry
+				input[j+k], carry = byte(sum), sum>>8
+			}
+		}
+	}
+}
+
+// cbcEncrypt encrypts whole blocks of plaintext in CBC mode, one block of
+// len(iv) bytes at a time with encryptBlock.
+func cbcEncrypt(encryptBlock func(dst, src []byte), iv, plaintext []byte) []byte {
+	size := len(iv)
+	ciphertext := make([]byte, len(plaintext))
+	previous := iv
+	for i := 0; i < len(plaintext); i += size {
+		block := ciphertext[i : i+size]
+		subtle.XORBytes(block, plaintext[i:i+size], previous)
+		encryptBlock(block, block)
+		previous = block
+	}
+	return ciphertext
+}
+
+// rc2Cipher is the encrypting half of RC2 (RFC 2268), kept only for the
+// RC2-40 certificate encryption that legacy PFX importers expect. It is not a
+// cipher.Block, as it cannot decrypt.
+type rc2Cipher struct {
+	k [64]uint16
+}
+
+var rc2PITable = [256]byte{
+	0xd9, 0x78, 0xf9, 0xc4, 0x19, 0xdd, 0xb5, 0xed, 0x28, 0xe9, 0xfd, 0x79, 0x4a, 0xa0, 0xd8, 0x9d,
+	0xc6, 0x7e, 0x37, 0x83, 0x2b, 0x76, 0x53, 0x8e, 0x62, 0x4c, 0x64, 0x88, 0x44, 0x8b, 0xfb, 0xa2,
+	0x17, 0x9a, 0x59, 0xf5, 0x87, 0xb3, 0x4f, 0x13, 0x61, 0x45, 0x6d, 0x8d, 0x09, 0x81, 0x7d, 0x32,
+	0xbd, 0x8f, 0x40, 0xeb, 0x86, 0xb7, 0x7b, 0x0b, 0xf0, 0x95, 0x21, 0x22, 0x5c, 0x6b, 0x4e, 0x82,
+	0x54, 0xd6, 0x65, 0x93, 0xce, 0x60, 0xb2, 0x1c, 0x73, 0x56, 0xc0, 0x14, 0xa7, 0x8c, 0xf1, 0xdc,
+	0x12, 0x75, 0xca, 0x1f, 0x3b, 0xbe, 0xe4, 0xd1, 0x42, 0x3d, 0xd4, 0x30, 0xa3, 0x3c, 0xb6, 0x26,
+	0x6f, 0xbf, 0x0e, 0xda, 0x46, 0x69, 0x07, 0x57, 0x27, 0xf2, 0x1d, 0x9b, 0xbc, 0x94, 0x43, 0x03,
+	0xf8, 0x11, 0xc7, 0xf6, 0x90, 0xef, 0x3e, 0xe7, 0x06, 0xc3, 0xd5, 0x2f, 0xc8, 0x66, 0x1e, 0xd7,
+	0x08, 0xe8, 0xea, 0xde, 0x80, 0x52, 0xee, 0xf7, 0x84, 0xaa, 0x72, 0xac, 0x35, 0x4d, 0x6a, 0x2a,
+	0x96, 0x1a, 0xd2, 0x71, 0x5a, 0x15, 0x49, 0x74, 0x4b, 0x9f, 0xd0, 0x5e, 0x04, 0x18, 0xa4, 0xec,
+	0xc2, 0xe0, 0x41, 0x6e, 0x0f, 0x51, 0xcb, 0xcc, 0x24, 0x91, 0xaf, 0x50, 0xa1, 0xf4, 0x70, 0x39,
+	0x99, 0x7c, 0x3a, 0x85, 0x23, 0xb8, 0xb4, 0x7a, 0xfc, 0x02, 0x36, 0x5b, 0x25, 0x55, 0x97, 0x31,
+	0x2d, 0x5d, 0xfa, 0x98, 0xe3, 0x8a, 0x92, 0xae, 0x05, 0xdf, 0x29, 0x10, 0x67, 0x6c, 0xba, 0xc9,
+	0xd3, 0x00, 0xe6, 0xcf, 0xe1, 0x9e, 0xa8, 0x2c, 0x63, 0x16, 0x01, 0x3f, 0x58, 0xe2, 0x89, 0xa9,
+	0x0d, 0x38, 0x34, 0x1b, 0xab, 0x33, 0xff, 0xb0, 0xbb, 0x48, 0x0c, 0x5f, 0xb9, 0xb1, 0xcd, 0x2e,
+	0xc5, 0xf3, 0xdb, 0x47, 0xe5, 0xa5, 0x9c, 0x77, 0x0a, 0xa6, 0x20, 0x68, 0xfe, 0x7f, 0xc1, 0xad,
+}
+
+// newRC2 expands key for an effective key length of effectiveBits.
+func newRC2(key []byte, effectiveBits int) *rc2Cipher {
+	l := make([]byte, 128)
+	copy(l, key)
+	for i := len(key); i < 128; i++ {
+		l[i] = rc2PITable[l[i-1]+l[i-len(key)]]
+	}
+	t8 := (effectiveBits + 7) / 8
+	tm := byte(0xff >> (8*t8 - effectiveBits))
+	l[128-t8] = rc2PITable[l[128-t8]&tm]
+	for i := 127 - t8; i >= 0; i-- {
+		l[i] = rc2PITable[l[i+1]^l[i+t8]]
+	}
+	c := &rc2Cipher{}
+	for i := range c.k {
+		c.k[i] = uint16(l[2*i]) | uint16(l[2*i+1])<<8
+	}
+	return c
+}
+
+// encryptBlock encrypts one 8-byte block; dst and src may overlap entirely.
+func (c *rc2Cipher) encryptBlock(dst, src []byte) {
+	var r [4]uint16
+	for i := range r {
+		r[i] = uint16(src[2*i]) | uint16(src[2*i+1])<<8
+	}
+	j := 0
+	mix := func() {
+		for i, shift := range [4]int{1, 2, 3, 5} {
+			r[i] += c.k[j] + (r[(i+3)%4] & r[(i+2)%4]) + (^r[(i+3)%4] & r[(i+1)%4])
+			r[i] = bits.RotateLeft16(r[i], shift)
+			j++
+		}
+	}
+	mash := func() {
+		for i := range r {
+			r[i] += c.k[r[(i+3)%4]&63]
+		}
+	}
+	for round := 0; round < 16; round++ {
+		mix()
+		if round == 4 || round == 10 {
+			mash()
+		}
+	}
+	for i := range r {
+		dst[2*i], dst[2*i+1] = byte(r[i]), byte(r[i]>>8)
+	}
+}
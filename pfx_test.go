@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// TestRC2 checks the RC2 block cipher against the test vectors of RFC 2268, section 5.
+func TestRC2(t *testing.T) {
+	vectors := []struct {
+		key, plaintext, ciphertext string
+		effectiveBits              int
+	}{
+		{"0000000000000000", "0000000000000000", "ebb773f993278eff", 63},
+		{"ffffffffffffffff", "ffffffffffffffff", "278b27e42e2f0d49", 64},
+		{"3000000000000000", "1000000000000001", "30649edf9be7d2c2", 64},
+		{"88", "0000000000000000", "61a8a244adacccf0", 64},
+		{"88bca90e90875a", "0000000000000000", "6ccf4308974c267f", 64},
+		{"88bca90e90875a7f0f79c384627bafb2", "0000000000000000", "1a807d272bbe5db1", 64},
+		{"88bca90e90875a7f0f79c384627bafb2", "0000000000000000", "2269552ab0f85ca6", 128},
+		{"88bca90e90875a7f0f79c384627bafb216f80a6f85920584c42fceb0be255daf1e", "0000000000000000", "5b78d3a43dfff1f1", 129},
+	}
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		plaintext, _ := hex.DecodeString(v.plaintext)
+		got := make([]byte, len(plaintext))
+		newRC2(key, v.effectiveBits).encryptBlock(got, plaintext)
+		if hex.EncodeToString(got) != v.ciphertext {
+			t.Errorf("RC2 key %s (%d bits): got %x, want %s", v.key, v.effectiveBits, got, v.ciphertext)
+		}
+	}
+}
+
+// TestPKCS12KDF checks the PKCS#12 key derivation against a known 3DES key.
+func TestPKCS12KDF(t *testing.T) {
+	salt := bytes.Repeat([]byte{0xff}, 8)
+	got := pkcs12KDF(sha1.New, bmpPassword("sesame"), salt, 2048, 1, 24)
+	want, _ := hex.DecodeString("7cd9fd3e2b3be7691a44e3bef0f9ea0fb9b897d4e325d9d1")
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+// TestPFXEncodings checks that every encryption choice can be read back, with
+// the friendly name on both the key and the certificate.
+func TestPFXEncodings(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pfx.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const friendlyName = "Café web server"
+	for name, encoding := range pfxEncodings {
+		data, err := encodePFX(encoding, key, cert, nil, friendlyName, "password")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		decodedKey, decodedCert, err := pkcs12.Decode(data, "password")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !decodedCert.Equal(cert) || !key.Equal(decodedKey) {
+			t.Errorf("%s: the key or certificate did not survive encoding", name)
+		}
+		blocks, err := pkcs12.ToPEM(data, "password")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, block := range blocks {
+			if got := block.Headers["friendlyName"]; got != friendlyName {
+				t.Errorf("%s: %s bag has friendly name '%s', want '%s'", name, block.Type, got, friendlyName)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// CAPolicy limits what a CA will sign. Zero values impose no limit.
+type CAPolicy struct {
+	MaxValidityDays   int      `json:"maxValidityDays"`
+	AllowedDomains    []string `json:"allowedDomains"`    // DNS suffixes names must fall under; empty allows any
+	ForbiddenDomains  []string `json:"forbiddenDomains"`  // DNS suffixes names must not fall under
+	AllowedIPRanges   []string `json:"allowedIpRanges"`   // CIDRs IP addresses must fall in; empty allows any
+	ForbiddenIPRanges []string `json:"forbiddenIpRanges"` // CIDRs IP addresses must not fall in
+	ForbidWildcards   bool     `json:"forbidWildcards"`
+	MinRSABits        int      `json:"minRsaBits"`
+	MinECDSABits      int      `json:"minEcdsaBits"`    // Curve size; Ed25519 counts as 256
+	AllowedProfiles   []string `json:"allowedProfiles"` // Empty allows any leaf profile
+}
+
+// clean normalises the policy's domains and IP ranges and checks its values.
+func (p CAPolicy) clean() (CAPolicy, error) {
+	if p.MaxValidityDays < 0 || p.MinRSABits < 0 || p.MinECDSABits < 0 {
+		return p, fmt.Errorf("limits cannot be negative")
+	}
+	domains := func(values []string) ([]string, error) {
+		var out []string
+		for _, v := range values {
+			v = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(v), "*"), ".")
+			if v == "" {
+				continue
+			}
+			name, err := normalizeHostname(v)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a valid domain", v)
+			}
+			out = appendUnique(out, name)
+		}
+		return out, nil
+	}
+	ranges := func(values []string) ([]string, error) {
+		var out []string
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			network, err := parseIPRange(v)
+			if err != nil {
+				return nil, err
+			}
+			out = appendUnique(out, network.String())
+		}
+		return out, nil
+	}
+	var err error
+	if p.AllowedDomains, err = domains(p.AllowedDomains); err != nil {
+		return p, err
+	}
+	if p.ForbiddenDomains, err = domains(p.ForbiddenDomains); err != nil {
+		return p, err
+	}
+	if p.AllowedIPRanges, err = ranges(p.AllowedIPRanges); err != nil {
+		return p, err
+	}
+	if p.ForbiddenIPRanges, err = ranges(p.ForbiddenIPRanges); err != nil {
+		return p, err
+	}
+	var profiles []string
+	for _, name := range p.AllowedProfiles {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, err := leafProfile(name); err != nil {
+			return p, err
+		}
+		profiles = appendUnique(profiles, name)
+	}
+	p.AllowedProfiles = profiles
+	return p, nil
+}
+
+// leafNotAfter works out the expiry of a certificate issued by caCert.
+// expiryDays of 0 uses the profile's validity, shortened to the policy's
+// maximum; an explicit request above the maximum is refused. The result never
+// runs past the CA's own expiry.
+func (p CAPolicy) leafNotAfter(caCert *x509.Certificate, profile *CertProfile, expiryDays int) (time.Time, error) {
+	now := time.Now()
+	if !now.Before(caCert.NotAfter) {
+		return time.Time{}, fmt.Errorf("the CA expired on %s and cannot issue certificates", caCert.NotAfter.Format(time.RFC1123))
+	}
+	if p.MaxValidityDays > 0 {
+		if expiryDays > p.MaxValidityDays {
+			return time.Time{}, fmt.Errorf("the CA's policy allows at most %d days of validity, but %d were requested", p.MaxValidityDays, expiryDays)
+		}
+		if expiryDays <= 0 && profile.notAfter(0).After(now.AddDate(0, 0, p.MaxValidityDays)) {
+			expiryDays = p.MaxValidityDays
+		}
+	}
+	notAfter := profile.notAfter(expiryDays)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	return notAfter, nil
+}
+
+// check refuses a certificate whose names, key or profile break the policy.
+func (p CAPolicy) check(cn string, template *x509.Certificate, pub crypto.PublicKey, profileName string) error {
+	if len(p.AllowedProfiles) > 0 && !containsFold(p.AllowedProfiles, profileName) {
+		return fmt.Errorf("profile '%s' is not allowed; allowed profiles are %s", profileName, strings.Join(p.AllowedProfiles, ", "))
+	}
+	if err := p.checkKey(pub); err != nil {
+		return err
+	}
+
+	dnsNames, ips := certNames(cn, template)
+	for _, name := range dnsNames {
+		if err := p.checkDNSName(name); err != nil {
+			return err
+		}
+	}
+	for _, ip := range ips {
+		if err := p.checkIP(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p CAPolicy) checkDNSName(name string) error {
+	host := strings.ToLower(name)
+	if strings.HasPrefix(host, "*.") {
+		if p.ForbidWildcards {
+			return fmt.Errorf("wildcard name '%s' is not allowed", name)
+		}
+		host = host[2:]
+	}
+	for _, suffix := range p.ForbiddenDomains {
+		if underDomain(host, suffix) {
+			return fmt.Errorf("name '%s' is under the forbidden domain '%s'", name, suffix)
+		}
+	}
+	if len(p.AllowedDomains) == 0 {
+		return nil
+	}
+	for _, suffix := range p.AllowedDomains {
+		if underDomain(host, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("name '%s' is not under an allowed domain (%s)", name, strings.Join(p.AllowedDomains, ", "))
+}
+
+func (p CAPolicy) checkIP(ip net.IP) error {
+	for _, r := range p.ForbiddenIPRanges {
+		if network, err := parseIPRange(r); err == nil && network.Contains(ip) {
+			return fmt.Errorf("IP address %s is in the forbidden range %s", ip, r)
+		}
+	}
+	if len(p.AllowedIPRanges) == 0 {
+		return nil
+	}
+	for _, r := range p.AllowedIPRanges {
+		if network, err := parseIPRange(r); err == nil && network.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("IP address %s is not in an allowed range (%s)", ip, strings.Join(p.AllowedIPRanges, ", "))
+}
+
+func (p CAPolicy) checkKey(pub crypto.PublicKey) error {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if bits := k.N.BitLen(); p.MinRSABits > 0 && bits < p.MinRSABits {
+			return fmt.Errorf("RSA key of %d bits is below the minimum of %d", bits, p.MinRSABits)
+		}
+	case *ecdsa.PublicKey:
+		if bits := k.Curve.Params().BitSize; p.MinECDSABits > 0 && bits < p.MinECDSABits {
+			return fmt.Errorf("ECDSA key on %s is below the minimum of %d bits", k.Curve.Params().Name, p.MinECDSABits)
+		}
+	case ed25519.PublicKey:
+		if p.MinECDSABits > 256 {
+			return fmt.Errorf("Ed25519 keys are below the minimum of %d bits", p.MinECDSABits)
+		}
+	}
+	return nil
+}
+
+// certNames returns the DNS names and IP addresses of a certificate, with cn
+// when it is a hostname or an IP address.
+func certNames(cn string, template *x509.Certificate) ([]string, []net.IP) {
+	dnsNames := template.DNSNames
+	ips := template.IPAddresses
+	if ip := net.ParseIP(cn); ip != nil {
+		ips = append([]net.IP{ip}, ips...)
+	} else if strings.Contains(cn, ".") {
+		if name, err := normalizeHostname(cn); err == nil {
+			dnsNames = append([]string{name}, dnsNames...)
+		}
+	}
+	return dnsNames, ips
+}
+
+// underDomain reports whether host is domain or a subdomain of it.
+func underDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// parseIPRange parses a CIDR, or a single address as a one-address range.
+func parseIPRange(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("'%s' is not a valid IP range", value)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a valid IP range", value)
+	}
+	return network, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLeafNotAfter checks that validity comes from the request or the profile,
+// is shortened to the policy's maximum and the CA's expiry, and that requests
+// above the maximum are refused.
+func TestLeafNotAfter(t *testing.T) {
+	tenYears := &x509.Certificate{NotAfter: time.Now().AddDate(10, 0, 0)}
+	hundredDays := &x509.Certificate{NotAfter: time.Now().AddDate(0, 0, 100)}
+	expired := &x509.Certificate{NotAfter: time.Now().Add(-time.Hour)}
+	tests := []struct {
+		maxDays, profileDays, expiryDays int
+		ca                               *x509.Certificate
+		wantDays                         int
+		refusal                          string
+	}{
+		{0, 730, 0, tenYears, 730, ""},
+		{0, 0, 0, tenYears, 730, ""},
+		{0, 730, 90, tenYears, 90, ""},
+		{397, 730, 0, tenYears, 397, ""},
+		{397, 90, 0, tenYears, 90, ""},
+		{397, 730, 397, tenYears, 397, ""},
+		{397, 730, 398, tenYears, 0, "the CA's policy allows at most 397 days of validity, but 398 were requested"},
+		{0, 730, 0, hundredDays, 100, ""},
+		{0, 730, 365, hundredDays, 100, ""},
+		{397, 730, 500, hundredDays, 0, "at most 397 days"},
+		{0, 730, 0, expired, 0, "the CA expired on"},
+	}
+	for _, tc := range tests {
+		policy := CAPolicy{MaxValidityDays: tc.maxDays}
+		profile := &CertProfile{Name: "test", ValidityDays: tc.profileDays}
+		notAfter, err := policy.leafNotAfter(tc.ca, profile, tc.expiryDays)
+		if tc.refusal != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.refusal) {
+				t.Errorf("%+v: got %v, want %q", tc, err, tc.refusal)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: %v", tc, err)
+			continue
+		}
+		if notAfter.After(tc.ca.NotAfter) {
+			t.Errorf("%+v: expires %s, after the CA", tc, notAfter)
+		}
+		if days := int(time.Until(notAfter).Hours()/24 + 0.5); days != tc.wantDays {
+			t.Errorf("%+v: valid for %d days, want %d", tc, days, tc.wantDays)
+		}
+	}
+}
+
+// TestCAPolicyCheck checks the profile, key and name restrictions of a policy.
+func TestCAPolicyCheck(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ecKey := func(curve elliptic.Curve) crypto.PublicKey {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.Public()
+	}
+	p256, p384 := ecKey(elliptic.P256()), ecKey(elliptic.P384())
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := CAPolicy{
+		AllowedDomains:    []string{"example.com", ".example.org"},
+		ForbiddenDomains:  []string{"*.secret.example.com"},
+		AllowedIPRanges:   []string{"10.0.0.0/8", "192.0.2.1"},
+		ForbiddenIPRanges: []string{"10.1.0.0/16"},
+		ForbidWildcards:   true,
+		AllowedProfiles:   []string{"tls-server", defaultCertProfile},
+	}.clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cn       string
+		dnsNames []string
+		ips      []string
+		profile  string
+		refusal  string // Empty when the certificate is allowed
+	}{
+		{cn: "www.example.com", dnsNames: []string{"example.com", "api.example.org"}, ips: []string{"10.2.3.4", "192.0.2.1"}},
+		{cn: "Web server", dnsNames: []string{"WWW.Example.com"}},
+		{cn: "10.2.3.4", profile: "tls-server"},
+		{cn: "www.example.com", profile: "code-signing", refusal: "profile 'code-signing' is not allowed; allowed profiles are tls-server, tls-server-client"},
+		{cn: "www.example.net", refusal: "name 'www.example.net' is not under an allowed domain (example.com, example.org)"},
+		{cn: "Web server", dnsNames: []string{"notexample.com"}, refusal: "not under an allowed domain"},
+		{cn: "vault.secret.example.com", refusal: "name 'vault.secret.example.com' is under the forbidden domain 'secret.example.com'"},
+		{cn: "secret.example.com", refusal: "forbidden domain"},
+		{cn: "www.example.com", dnsNames: []string{"*.example.com"}, refusal: "wildcard name '*.example.com' is not allowed"},
+		{cn: "10.1.2.3", refusal: "IP address 10.1.2.3 is in the forbidden range 10.1.0.0/16"},
+		{cn: "www.example.com", ips: []string{"192.0.2.2"}, refusal: "IP address 192.0.2.2 is not in an allowed range (10.0.0.0/8, 192.0.2.1/32)"},
+	}
+	for _, tc := range tests {
+		template := &x509.Certificate{DNSNames: tc.dnsNames}
+		for _, ip := range tc.ips {
+			template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+		}
+		profile := tc.profile
+		if profile == "" {
+			profile = defaultCertProfile
+		}
+		err := policy.check(tc.cn, template, p256, profile)
+		switch {
+		case tc.refusal == "" && err != nil:
+			t.Errorf("%s %v: %v", tc.cn, tc.dnsNames, err)
+		case tc.refusal != "" && (err == nil || !strings.Contains(err.Error(), tc.refusal)):
+			t.Errorf("%s %v: got %v, want %q", tc.cn, tc.dnsNames, err, tc.refusal)
+		}
+		// Without restrictions everything is allowed
+		if err := (CAPolicy{}).check(tc.cn, template, p256, profile); err != nil {
+			t.Errorf("empty policy refused %s: %v", tc.cn, err)
+		}
+	}
+
+	keys := []struct {
+		name          string
+		pub           crypto.PublicKey
+		minRSA, minEC int
+		refusal       string
+	}{
+		{"RSA 2048", rsaKey.Public(), 2048, 0, ""},
+		{"RSA 2048", rsaKey.Public(), 3072, 0, "RSA key of 2048 bits is below the minimum of 3072"},
+		{"RSA 2048", rsaKey.Public(), 0, 384, ""},
+		{"P-256", p256, 0, 256, ""},
+		{"P-256", p256, 0, 384, "ECDSA key on P-256 is below the minimum of 384 bits"},
+		{"P-256", p256, 4096, 0, ""},
+		{"P-384", p384, 0, 384, ""},
+		{"Ed25519", edKey, 0, 256, ""},
+		{"Ed25519", edKey, 0, 384, "Ed25519 keys are below the minimum of 384 bits"},
+	}
+	for _, tc := range keys {
+		policy := CAPolicy{MinRSABits: tc.minRSA, MinECDSABits: tc.minEC}
+		err := policy.checkKey(tc.pub)
+		switch {
+		case tc.refusal == "" && err != nil:
+			t.Errorf("%s with %+v: %v", tc.name, policy, err)
+		case tc.refusal != "" && (err == nil || err.Error() != tc.refusal):
+			t.Errorf("%s with %+v: got %v, want %q", tc.name, policy, err, tc.refusal)
+		}
+		if tc.refusal != "" {
+			if err := policy.check("www.example.com", &x509.Certificate{}, tc.pub, defaultCertProfile); err == nil {
+				t.Errorf("check allowed %s with %+v", tc.name, policy)
+			}
+		}
+	}
+}
+
+// TestCAPolicyClean checks that policies are normalised and bad values refused.
+func TestCAPolicyClean(t *testing.T) {
+	t.Chdir(t.TempDir())
+	policy, err := CAPolicy{
+		AllowedDomains:  []string{" *.Example.com ", ".example.com", "bücher.example", ""},
+		AllowedIPRanges: []string{"192.0.2.1", "10.0.0.0/8", "10.0.0.0/8"},
+		AllowedProfiles: []string{" tls-server ", ""},
+	}.clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com", "xn--bcher-kva.example"}; !slices.Equal(policy.AllowedDomains, want) {
+		t.Errorf("allowed domains %v, want %v", policy.AllowedDomains, want)
+	}
+	if want := []string{"192.0.2.1/32", "10.0.0.0/8"}; !slices.Equal(policy.AllowedIPRanges, want) {
+		t.Errorf("allowed IP ranges %v, want %v", policy.AllowedIPRanges, want)
+	}
+	if want := []string{"tls-server"}; !slices.Equal(policy.AllowedProfiles, want) {
+		t.Errorf("allowed profiles %v, want %v", policy.AllowedProfiles, want)
+	}
+
+	for _, bad := range []CAPolicy{
+		{MaxValidityDays: -1},
+		{MinRSABits: -2048},
+		{ForbiddenDomains: []string{"exa mple.com"}},
+		{ForbiddenIPRanges: []string{"10.0.0.0/40"}},
+		{AllowedProfiles: []string{"no-such-profile"}},
+		{AllowedProfiles: []string{defaultCAProfile}},
+	} {
+		if _, err := bad.clean(); err == nil {
+			t.Errorf("policy %+v accepted", bad)
+		}
+	}
+}
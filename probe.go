@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// STARTTLS protocols ProbeTLS can speak before the handshake.
+const (
+	startTLSSMTP = "smtp"
+	startTLSIMAP = "imap"
+	startTLSLDAP = "ldap"
+)
+
+// startTLSPorts are the ports used when an address has none.
+var startTLSPorts = map[string]string{"": "443", startTLSSMTP: "25", startTLSIMAP: "143", startTLSLDAP: "389"}
+
+const (
+	probeTimeout    = 10 * time.Second
+	probeExpiryDays = 30 // Warn about certificates expiring within this many days
+)
+
+// oidLDAPStartTLS is the LDAP StartTLS extended operation (RFC 4511).
+const oidLDAPStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// ProbeInput is a TLS endpoint to probe.
+type ProbeInput struct {
+	Address    string `json:"address"`    // host:port; the port defaults to the protocol's usual one
+	ServerName string `json:"serverName"` // SNI and the name to check; defaults to the host
+	StartTLS   string `json:"startTls"`   // "", smtp, imap or ldap
+}
+
+// ProbeResult describes the chain an endpoint presented and what is wrong
+// with it.
+type ProbeResult struct {
+	Address       string       `json:"address"`
+	ServerName    string       `json:"serverName"`
+	TLSVersion    string       `json:"tlsVersion"`
+	CipherSuite   string       `json:"cipherSuite"`
+	Chain         []ProbedCert `json:"chain"` // As presented, leaf first
+	Trusted       bool         `json:"trusted"`
+	TrustedBy     string       `json:"trustedBy"`     // The store CA the chain was verified up to
+	VerifiedChain []string     `json:"verifiedChain"` // Common Names from the leaf to that CA
+	HostnameOK    bool         `json:"hostnameOk"`
+	Issues        []string     `json:"issues"`   // Problems clients will hit
+	Warnings      []string     `json:"warnings"` // Upcoming expiry and the like
+}
+
+// ProbedCert is a certificate presented by an endpoint.
+type ProbedCert struct {
+	Details   *CertDetails `json:"details"`
+	StoreName string       `json:"storeName"` // The CA or certificate in the store, when already managed
+	Data      string       `json:"data"`      // Base64 DER, for importing
+}
+
+// ProbeTLS connects to an endpoint, performs a TLS handshake (after
+// STARTTLS if asked) and checks the chain it presents against the CAs in the
+// store: trust, hostname, missing intermediates, order and expiry.
+func (a *App) ProbeTLS(input ProbeInput) (*ProbeResult, error) {
+	address := strings.TrimSpace(input.Address)
+	port, ok := startTLSPorts[input.StartTLS]
+	if !ok {
+		return nil, fmt.Errorf("unknown STARTTLS protocol '%s'; use smtp, imap or ldap", input.StartTLS)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("enter the host and port to probe")
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = strings.Trim(address, "[]")
+		address = net.JoinHostPort(host, port)
+	}
+	serverName := strings.TrimSpace(input.ServerName)
+	if serverName == "" {
+		serverName = host
+	}
+
+	state, err := probeHandshake(address, serverName, input.StartTLS)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("%s presented no certificate", address)
+	}
+	result := &ProbeResult{
+		Address:     address,
+		ServerName:  serverName,
+		TLSVersion:  tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Chain:       []ProbedCert{},
+		Issues:      []string{},
+		Warnings:    []string{},
+	}
+	presented := state.PeerCertificates
+	for _, cert := range presented {
+		probed := ProbedCert{Details: certDetails(cert), Data: base64.StdEncoding.EncodeToString(cert.Raw)}
+		if record := inventoryRecordFor(cert); record != nil {
+			probed.StoreName = record.Name
+		}
+		result.Chain = append(result.Chain, probed)
+	}
+
+	leaf := presented[0]
+	if err := leaf.VerifyHostname(serverName); err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Hostname mismatch: %v", err))
+	} else {
+		result.HostnameOK = true
+	}
+	checkProbedTrust(result, presented)
+	for i := 0; i+1 < len(presented); i++ {
+		if presented[i].CheckSignatureFrom(presented[i+1]) != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("The chain is out of order: '%s' was not issued by the next certificate, '%s'.",
+				presented[i].Subject.CommonName, presented[i+1].Subject.CommonName))
+			break
+		}
+	}
+	now := time.Now()
+	for _, cert := range presented {
+		switch days := int(cert.NotAfter.Sub(now).Hours() / 24); {
+		case now.After(cert.NotAfter):
+			result.Issues = append(result.Issues, fmt.Sprintf("'%s' expired on %s.", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC1123)))
+		case now.Before(cert.NotBefore):
+			result.Issues = append(result.Issues, fmt.Sprintf("'%s' is not valid until %s.", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC1123)))
+		case days < probeExpiryDays:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("'%s' expires in %d day(s), on %s.", cert.Subject.CommonName, days, cert.NotAfter.Format(time.RFC1123)))
+		}
+	}
+	return result, nil
+}
+
+// checkProbedTrust verifies a presented chain against the CAs in the store,
+// first with only the intermediates the endpoint sent and then, if that
+// fails, with the store's intermediates too, to find ones it leaves out.
+func checkProbedTrust(result *ProbeResult, presented []*x509.Certificate) {
+	cas := storeCAs()
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, ca := range cas {
+		if findParent(ca.cert, cas) == "" {
+			opts.Roots.AddCert(ca.cert)
+		}
+	}
+	for _, cert := range presented[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := presented[0].Verify(opts)
+	if err != nil {
+		for _, ca := range cas {
+			if findParent(ca.cert, cas) != "" {
+				opts.Intermediates.AddCert(ca.cert)
+			}
+		}
+		var retryErr error
+		if chains, retryErr = presented[0].Verify(opts); retryErr != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Not trusted by any CA in the store: %v", explainVerifyError(err)))
+			return
+		}
+		var missing []string
+		for _, cert := range chains[0][1 : len(chains[0])-1] {
+			if !containsCert(presented, cert) {
+				missing = append(missing, cert.Subject.CommonName)
+			}
+		}
+		if len(missing) > 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("Missing intermediate CA(s): the endpoint does not send %s; clients without them cannot build the chain.",
+				strings.Join(missing, ", ")))
+		} else {
+			result.Issues = append(result.Issues, fmt.Sprintf("The chain as sent does not verify: %v", explainVerifyError(err)))
+		}
+	}
+
+	result.Trusted = true
+	for _, cert := range chains[0] {
+		result.VerifiedChain = append(result.VerifiedChain, cert.Subject.CommonName)
+	}
+	root := chains[0][len(chains[0])-1]
+	if record := inventoryRecordFor(root); record != nil {
+		result.TrustedBy = record.Name
+	} else {
+		result.TrustedBy = root.Subject.CommonName
+	}
+}
+
+// probeHandshake connects to address, upgrades the connection with STARTTLS
+// if asked and performs a TLS handshake. The chain is not verified here; the
+// caller checks it against the store.
+func probeHandshake(address, serverName, startTLS string) (*tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+
+	switch startTLS {
+	case startTLSSMTP:
+		err = startTLSWithSMTP(conn)
+	case startTLSIMAP:
+		err = startTLSWithIMAP(conn)
+	case startTLSLDAP:
+		err = startTLSWithLDAP(conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("STARTTLS with %s failed: %w", address, err)
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, // The chain is checked against the store, not the system roots
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %w", address, err)
+	}
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
+
+// startTLSWithSMTP asks an SMTP server to start TLS (RFC 3207).
+func startTLSWithSMTP(conn net.Conn) error {
+	text := textproto.NewConn(nopCloser{conn})
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return fmt.Errorf("unexpected greeting: %w", err)
+	}
+	if err := text.PrintfLine("EHLO ca-manager"); err != nil {
+		return err
+	}
+	_, extensions, err := text.ReadResponse(250)
+	if err != nil {
+		return fmt.Errorf("EHLO refused: %w", err)
+	}
+	if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+		return fmt.Errorf("the server does not offer STARTTLS")
+	}
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return fmt.Errorf("STARTTLS refused: %w", err)
+	}
+	return nil
+}
+
+// startTLSWithIMAP asks an IMAP server to start TLS (RFC 3501).
+func startTLSWithIMAP(conn net.Conn) error {
+	text := textproto.NewConn(nopCloser{conn})
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.ToUpper(greeting), "* OK") {
+		return fmt.Errorf("unexpected greeting: %s", greeting)
+	}
+	if err := text.PrintfLine("a1 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "a1 ") {
+			if !strings.HasPrefix(strings.ToUpper(line), "A1 OK") {
+				return fmt.Errorf("STARTTLS refused: %s", line)
+			}
+			return nil
+		}
+	}
+}
+
+// startTLSWithLDAP sends an LDAP StartTLS extended request (RFC 4511) and
+// checks its result code.
+func startTLSWithLDAP(conn net.Conn) error {
+	requestName := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: []byte(oidLDAPStartTLS)}
+	encodedName, err := asn1.Marshal(requestName)
+	if err != nil {
+		return err
+	}
+	request, err := asn1.Marshal(struct {
+		MessageID int
+		Operation asn1.RawValue
+	}{
+		MessageID: 1,
+		Operation: asn1.RawValue{Class: asn1.ClassApplication, Tag: 23, IsCompound: true, Bytes: encodedName},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	response, err := readBERElement(conn)
+	if err != nil {
+		return fmt.Errorf("could not read the StartTLS response: %w", err)
+	}
+	// The response is walked as BER rather than with encoding/asn1, which
+	// rejects the non-minimal lengths Active Directory sends
+	message, _, err := nextBERElement(response)
+	if err != nil || message.tag != 0x30 {
+		return fmt.Errorf("could not parse the StartTLS response")
+	}
+	_, rest, err := nextBERElement(message.content) // messageID
+	if err != nil {
+		return fmt.Errorf("could not parse the StartTLS response: %w", err)
+	}
+	operation, _, err := nextBERElement(rest)
+	if err != nil {
+		return fmt.Errorf("could not parse the StartTLS response: %w", err)
+	}
+	if operation.tag != 0x78 { // [APPLICATION 24], constructed
+		return fmt.Errorf("the server did not answer with an extended response")
+	}
+	resultCode, rest, err := nextBERElement(operation.content)
+	if err != nil || resultCode.tag != 0x0a || len(resultCode.content) == 0 || len(resultCode.content) > 4 {
+		return fmt.Errorf("could not parse the StartTLS result")
+	}
+	code := 0
+	for _, b := range resultCode.content {
+		code = code<<8 | int(b)
+	}
+	if code != 0 {
+		// matchedDN and diagnosticMessage are plain OCTET STRINGs
+		var diagnostic berElement
+		_, rest, err = nextBERElement(rest)
+		if err == nil {
+			diagnostic, _, err = nextBERElement(rest)
+		}
+		if err != nil || len(bytes.TrimSpace(diagnostic.content)) == 0 {
+			return fmt.Errorf("the server refused with LDAP result %d", code)
+		}
+		return fmt.Errorf("the server refused with LDAP result %d: %s", code, bytes.TrimSpace(diagnostic.content))
+	}
+	return nil
+}
+
+// readBERElement reads one definite-length BER element: its tag, length and
+// content.
+func readBERElement(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		lengthBytes := make([]byte, length&0x7f)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		var err error
+		if length, err = berLength(lengthBytes); err != nil {
+			return nil, err
+		}
+	}
+	if length > 1<<20 {
+		return nil, fmt.Errorf("response too large")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return append(header, content...), nil
+}
+
+// berElement is one BER element with a single-byte tag, as LDAP uses.
+type berElement struct {
+	tag     byte
+	content []byte
+}
+
+// nextBERElement splits the first definite-length BER element off data,
+// accepting long-form lengths with leading zeros.
+func nextBERElement(data []byte) (berElement, []byte, error) {
+	if len(data) < 2 {
+		return berElement{}, nil, fmt.Errorf("truncated BER element")
+	}
+	tag, length, data := data[0], int(data[1]), data[2:]
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if len(data) < n {
+			return berElement{}, nil, fmt.Errorf("truncated BER length")
+		}
+		var err error
+		if length, err = berLength(data[:n]); err != nil {
+			return berElement{}, nil, err
+		}
+		data = data[n:]
+	}
+	if len(data) < length {
+		return berElement{}, nil, fmt.Errorf("truncated BER element")
+	}
+	return berElement{tag: tag, content: data[:length]}, data[length:], nil
+}
+
+// berLength decodes the bytes of a long-form BER length.
+func berLength(lengthBytes []byte) (int, error) {
+	if len(lengthBytes) == 0 || len(lengthBytes) > 4 {
+		return 0, fmt.Errorf("unsupported BER length")
+	}
+	length := 0
+	for _, b := range lengthBytes {
+		length = length<<8 | int(b)
+	}
+	if length < 0 {
+		return 0, fmt.Errorf("unsupported BER length")
+	}
+	return length, nil
+}
+
+// nopCloser stops textproto closing the connection, which is reused for TLS.
+type nopCloser struct {
+	io.ReadWriter
+}
+
+func (nopCloser) Close() error { return nil }
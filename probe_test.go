@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStoreCA files a CA certificate in the store, without its key.
+func testStoreCA(t *testing.T, name string, cert *x509.Certificate, issuer string) {
+	t.Helper()
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToInventory(kindCA, name, cert, issuer, name+".pem", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testStoreRoot creates a root CA valid for five years and stores it with
+// its key, ready to issue certificates.
+func testStoreRoot(t *testing.T, name string) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	root, rootKey := testIssue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(5, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	testStoreCA(t, name, root, "")
+	if err := writeKeyPEM(name+".key", rootKey, ""); err != nil {
+		t.Fatal(err)
+	}
+	return root, rootKey
+}
+
+// testStoreCert stores cert and its key as a device certificate issued by
+// the CA issuer.
+func testStoreCert(t *testing.T, name string, cert *x509.Certificate, key crypto.Signer, issuer string) {
+	t.Helper()
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, name), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := strings.TrimSuffix(name, ".pem") + ".key"
+	if err := writeKeyPEM(keyFile, key, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToInventory(kindCert, name, cert, issuer, name, keyFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveProbe accepts one connection on a local listener, runs startTLS on it
+// and, if that succeeds, completes a TLS handshake presenting chain. It
+// returns the listener's address.
+func serveProbe(t *testing.T, chain tls.Certificate, startTLS func(net.Conn, *bufio.Reader) bool) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if startTLS != nil && !startTLS(conn, bufio.NewReader(conn)) {
+			return
+		}
+		tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{chain}}).Handshake()
+	}()
+	return ln.Addr().String()
+}
+
+// ldapExtendedResponse encodes an LDAP ExtendedResponse to message 1 with
+// four-byte lengths, as Active Directory sends them.
+func ldapExtendedResponse(resultCode byte, diagnostic string) []byte {
+	long := func(tag byte, content []byte) []byte {
+		n := len(content)
+		return append([]byte{tag, 0x84, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}, content...)
+	}
+	result := append([]byte{0x0a, 0x01, resultCode, 0x04, 0x00}, long(0x04, []byte(diagnostic))...)
+	return long(0x30, append([]byte{0x02, 0x01, 0x01}, long(0x78, result)...))
+}
+
+// TestProbeTLS probes local listeners, directly and after each STARTTLS, and
+// checks the problems reported with the chains they present.
+func TestProbeTLS(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root, intermediate, leaf, _, _, leafKey := testHierarchy(t, "probe.example.com")
+	testStoreCA(t, "root", root, "")
+	testStoreCA(t, "intermediate", intermediate, "root")
+
+	chainOf := func(certs ...*x509.Certificate) tls.Certificate {
+		chain := tls.Certificate{PrivateKey: leafKey}
+		for _, cert := range certs {
+			chain.Certificate = append(chain.Certificate, cert.Raw)
+		}
+		return chain
+	}
+	fullChain := chainOf(leaf, intermediate)
+
+	smtp := func(conn net.Conn, r *bufio.Reader) bool {
+		fmt.Fprint(conn, "220 mail.example.com ESMTP\r\n")
+		r.ReadString('\n')
+		fmt.Fprint(conn, "250-mail.example.com\r\n250 STARTTLS\r\n")
+		r.ReadString('\n')
+		fmt.Fprint(conn, "220 Ready to start TLS\r\n")
+		return true
+	}
+	imap := func(conn net.Conn, r *bufio.Reader) bool {
+		fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+		r.ReadString('\n')
+		fmt.Fprint(conn, "a1 OK Begin TLS negotiation now\r\n")
+		return true
+	}
+	ldap := func(resultCode byte, diagnostic string) func(net.Conn, *bufio.Reader) bool {
+		return func(conn net.Conn, r *bufio.Reader) bool {
+			if _, err := readBERElement(r); err != nil {
+				return false
+			}
+			conn.Write(ldapExtendedResponse(resultCode, diagnostic))
+			return resultCode == 0
+		}
+	}
+
+	tests := []struct {
+		name       string
+		chain      tls.Certificate
+		protocol   string
+		startTLS   func(net.Conn, *bufio.Reader) bool
+		serverName string
+		issue      string // An issue that must be reported; none if empty
+		warning    string // A warning that must be reported, if any
+	}{
+		{name: "TLS", chain: fullChain},
+		{name: "SMTP", chain: fullChain, protocol: startTLSSMTP, startTLS: smtp},
+		{name: "IMAP", chain: fullChain, protocol: startTLSIMAP, startTLS: imap},
+		{name: "LDAP", chain: fullChain, protocol: startTLSLDAP, startTLS: ldap(0, "")},
+		{name: "missing intermediate", chain: chainOf(leaf), issue: "Missing intermediate CA(s): the endpoint does not send Test Intermediate CA"},
+		{name: "hostname mismatch", chain: fullChain, serverName: "other.example.com", issue: "Hostname mismatch"},
+		{name: "out of order", chain: chainOf(leaf, root, intermediate), warning: "The chain is out of order"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			serverName := test.serverName
+			if serverName == "" {
+				serverName = "probe.example.com"
+			}
+			address := serveProbe(t, test.chain, test.startTLS)
+			result, err := (&App{}).ProbeTLS(ProbeInput{Address: address, ServerName: serverName, StartTLS: test.protocol})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Trusted || result.TrustedBy != "root" {
+				t.Errorf("trusted %v by '%s', want trusted by 'root'", result.Trusted, result.TrustedBy)
+			}
+			issues := strings.Join(result.Issues, "\n")
+			if test.issue == "" && issues != "" {
+				t.Errorf("unexpected issues: %s", issues)
+			}
+			if !strings.Contains(issues, test.issue) {
+				t.Errorf("issues %q do not include %q", issues, test.issue)
+			}
+			if warnings := strings.Join(result.Warnings, "\n"); !strings.Contains(warnings, test.warning) {
+				t.Errorf("warnings %q do not include %q", warnings, test.warning)
+			}
+		})
+	}
+
+	refusals := []struct {
+		name     string
+		protocol string
+		startTLS func(net.Conn, *bufio.Reader) bool
+		want     string
+	}{
+		{"LDAP", startTLSLDAP, ldap(2, "StartTLS not supported"), "LDAP result 2: StartTLS not supported"},
+		{"LDAP without diagnostic", startTLSLDAP, ldap(52, ""), "LDAP result 52"},
+		{"SMTP", startTLSSMTP, func(conn net.Conn, r *bufio.Reader) bool {
+			fmt.Fprint(conn, "220 mail.example.com ESMTP\r\n")
+			r.ReadString('\n')
+			fmt.Fprint(conn, "250 mail.example.com\r\n")
+			return false
+		}, "does not offer STARTTLS"},
+		{"IMAP", startTLSIMAP, func(conn net.Conn, r *bufio.Reader) bool {
+			fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+			r.ReadString('\n')
+			fmt.Fprint(conn, "a1 BAD STARTTLS unavailable\r\n")
+			return false
+		}, "STARTTLS refused: a1 BAD"},
+	}
+	for _, test := range refusals {
+		t.Run("refused "+test.name, func(t *testing.T) {
+			address := serveProbe(t, fullChain, test.startTLS)
+			_, err := (&App{}).ProbeTLS(ProbeInput{Address: address, ServerName: "probe.example.com", StartTLS: test.protocol})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
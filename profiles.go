@@ -0,0 +1,409 @@
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// profilesFile holds user-defined certificate profiles inside the output directory.
+const profilesFile = "profiles.json"
+
+const (
+	// defaultCertProfile is used for device certificates and CSRs when no profile is given.
+	defaultCertProfile = "tls-server-client"
+	// defaultCAProfile is used for new CAs when no profile is given.
+	defaultCAProfile = "ca"
+)
+
+// CertProfile defines the extensions and default validity of a kind of certificate.
+type CertProfile struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	KeyUsage         []string `json:"keyUsage"`            // e.g. "digitalSignature", "keyEncipherment"
+	ExtKeyUsage      []string `json:"extKeyUsage"`         // e.g. "serverAuth", or a dotted OID
+	CriticalEKU      bool     `json:"criticalExtKeyUsage"` // Mark Extended Key Usage critical (required for time stamping)
+	IsCA             bool     `json:"isCA"`                // Profile is for CA certificates
+	BasicConstraints bool     `json:"basicConstraints"`    // Add Basic Constraints to leaves (CA:FALSE); always added for CAs
+	ValidityDays     int      `json:"validityDays"`        // Used when no expiry is given
+	OCSPNoCheck      bool     `json:"ocspNoCheck"`         // Add id-pkix-ocsp-nocheck (delegated OCSP signers)
+	BuiltIn          bool     `json:"builtIn"`             // Set by the app; ignored in profiles.json
+}
+
+// profileFile is the layout of profiles.json.
+type profileFile struct {
+	Profiles []CertProfile `json:"profiles"`
+}
+
+// builtInProfiles are always available. A profile in profiles.json with the
+// same name replaces the built-in one.
+var builtInProfiles = []CertProfile{
+	{
+		Name:             "tls-server-client",
+		Description:      "TLS server and client",
+		KeyUsage:         []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage:      []string{"serverAuth", "clientAuth"},
+		BasicConstraints: true,
+		ValidityDays:     730,
+	},
+	{
+		Name:             "tls-server",
+		Description:      "TLS server",
+		KeyUsage:         []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage:      []string{"serverAuth"},
+		BasicConstraints: true,
+		ValidityDays:     397,
+	},
+	{
+		Name:             "tls-client",
+		Description:      "TLS client",
+		KeyUsage:         []string{"digitalSignature"},
+		ExtKeyUsage:      []string{"clientAuth"},
+		BasicConstraints: true,
+		ValidityDays:     730,
+	},
+	{
+		Name:             "code-signing",
+		Description:      "Code signing",
+		KeyUsage:         []string{"digitalSignature"},
+		ExtKeyUsage:      []string{"codeSigning"},
+		BasicConstraints: true,
+		ValidityDays:     1095,
+	},
+	{
+		Name:             "email",
+		Description:      "S/MIME email signing and encryption",
+		KeyUsage:         []string{"digitalSignature", "contentCommitment", "keyEncipherment"},
+		ExtKeyUsage:      []string{"emailProtection"},
+		BasicConstraints: true,
+		ValidityDays:     730,
+	},
+	{
+		Name:             "ocsp-signing",
+		Description:      "Delegated OCSP responder",
+		KeyUsage:         []string{"digitalSignature"},
+		ExtKeyUsage:      []string{"OCSPSigning"},
+		BasicConstraints: true,
+		OCSPNoCheck:      true,
+		ValidityDays:     90,
+	},
+	{
+		Name:             "timestamping",
+		Description:      "RFC 3161 time stamping authority",
+		KeyUsage:         []string{"digitalSignature", "contentCommitment"},
+		ExtKeyUsage:      []string{"timeStamping"},
+		CriticalEKU:      true,
+		BasicConstraints: true,
+		ValidityDays:     1095,
+	},
+	{
+		Name:             "ipsec-ike",
+		Description:      "IPsec/IKE end entity",
+		KeyUsage:         []string{"digitalSignature", "keyEncipherment"},
+		ExtKeyUsage:      []string{"serverAuth", "clientAuth", "ipsecIKE"},
+		BasicConstraints: true,
+		ValidityDays:     730,
+	},
+	{
+		Name:         "ca",
+		Description:  "Certificate authority",
+		KeyUsage:     []string{"digitalSignature", "keyCertSign", "cRLSign"},
+		IsCA:         true,
+		ValidityDays: 3650,
+	},
+}
+
+// keyUsageNames maps RFC 5280 key usage names to their x509 bits.
+var keyUsageNames = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"nonRepudiation":    x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"keyCertSign":       x509.KeyUsageCertSign,
+	"cRLSign":           x509.KeyUsageCRLSign,
+	"encipherOnly":      x509.KeyUsageEncipherOnly,
+	"decipherOnly":      x509.KeyUsageDecipherOnly,
+}
+
+// extKeyUsageNames maps extended key usage names to their OIDs.
+var extKeyUsageNames = map[string]asn1.ObjectIdentifier{
+	"any":             {2, 5, 29, 37, 0},
+	"serverAuth":      {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	"clientAuth":      {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	"codeSigning":     {1, 3, 6, 1, 5, 5, 7, 3, 3},
+	"emailProtection": {1, 3, 6, 1, 5, 5, 7, 3, 4},
+	"ipsecEndSystem":  {1, 3, 6, 1, 5, 5, 7, 3, 5},
+	"ipsecTunnel":     {1, 3, 6, 1, 5, 5, 7, 3, 6},
+	"ipsecUser":       {1, 3, 6, 1, 5, 5, 7, 3, 7},
+	"timeStamping":    {1, 3, 6, 1, 5, 5, 7, 3, 8},
+	"OCSPSigning":     {1, 3, 6, 1, 5, 5, 7, 3, 9},
+	"ipsecIKE":        {1, 3, 6, 1, 5, 5, 7, 3, 17},
+}
+
+// oidExtKeyUsage is the Extended Key Usage extension, written by hand when it must be critical.
+var oidExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+// ListProfiles returns the built-in and user-defined certificate profiles sorted by name.
+func (a *App) ListProfiles() []CertProfile {
+	profiles, err := loadProfiles()
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	list := make([]CertProfile, 0, len(profiles))
+	for _, p := range profiles {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// loadProfiles returns every profile keyed by name. The built-in profiles are
+// always returned, even when profiles.json cannot be read.
+func loadProfiles() (map[string]CertProfile, error) {
+	profiles := make(map[string]CertProfile)
+	for _, p := range builtInProfiles {
+		p.BuiltIn = true
+		profiles[p.Name] = p
+	}
+	var file profileFile
+	if err := readJSON(profilesFile, &file); err != nil {
+		return profiles, fmt.Errorf("could not read %s: %w", profilesFile, err)
+	}
+	for _, p := range file.Profiles {
+		if err := p.validate(); err != nil {
+			return profiles, fmt.Errorf("invalid profile in %s: %w", profilesFile, err)
+		}
+		p.BuiltIn = false
+		profiles[p.Name] = p
+	}
+	return profiles, nil
+}
+
+// findProfile looks up a profile by name, falling back to the given default when name is empty.
+func findProfile(name, fallback string) (*CertProfile, error) {
+	if name == "" {
+		name = fallback
+	}
+	profiles, err := loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown certificate profile '%s'", name)
+	}
+	return &p, nil
+}
+
+// leafProfile looks up a profile for a device certificate, refusing CA profiles.
+func leafProfile(name string) (*CertProfile, error) {
+	profile, err := findProfile(name, defaultCertProfile)
+	if err != nil {
+		return nil, err
+	}
+	if profile.IsCA {
+		return nil, fmt.Errorf("profile '%s' is for CA certificates; create an intermediate CA instead", profile.Name)
+	}
+	return profile, nil
+}
+
+// matchingProfile returns the leaf profile that gives a certificate's Key
+// Usage, Extended Key Usage and Basic Constraints, preferring the default
+// profile, so that it can be reissued alike.
+func matchingProfile(cert *x509.Certificate) (*CertProfile, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	names := []string{defaultCertProfile}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	have := extKeyUsageOIDs(cert.Extensions)
+	for _, name := range names {
+		p, ok := profiles[name]
+		if !ok || p.IsCA {
+			continue
+		}
+		template := &x509.Certificate{}
+		if err := p.apply(template, cert.PublicKey); err != nil {
+			continue
+		}
+		want := extKeyUsageOIDs(template.ExtraExtensions)
+		if want == nil {
+			want = template.UnknownExtKeyUsage
+		}
+		if template.KeyUsage == cert.KeyUsage && template.BasicConstraintsValid == cert.BasicConstraintsValid && sameOIDs(want, have) {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("its key usages match no certificate profile; choose a profile to renew it with")
+}
+
+// sameOIDs reports whether two lists hold the same OIDs, in any order.
+func sameOIDs(a, b []asn1.ObjectIdentifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, oid := range a {
+		found := false
+		for _, other := range b {
+			found = found || oid.Equal(other)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks that every key usage and extended key usage in the profile is known.
+func (p *CertProfile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("a profile has no name")
+	}
+	if p.ValidityDays < 0 {
+		return fmt.Errorf("profile '%s' has a negative validity", p.Name)
+	}
+	for _, ku := range p.KeyUsage {
+		usage, ok := keyUsageNames[ku]
+		if !ok {
+			return fmt.Errorf("profile '%s' has unknown key usage '%s'", p.Name, ku)
+		}
+		if usage == x509.KeyUsageCertSign && !p.IsCA {
+			return fmt.Errorf("profile '%s' has keyCertSign but is not a CA profile", p.Name)
+		}
+	}
+	for _, eku := range p.ExtKeyUsage {
+		if _, err := extKeyUsageOID(eku); err != nil {
+			return fmt.Errorf("profile '%s': %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// notAfter returns the expiry for a certificate issued now, using the
+// profile's default validity when expiryDays is not positive.
+func (p *CertProfile) notAfter(expiryDays int) time.Time {
+	if expiryDays <= 0 {
+		expiryDays = p.ValidityDays
+	}
+	if expiryDays <= 0 {
+		expiryDays = 730 // Default to 2 years
+	}
+	return time.Now().AddDate(0, 0, expiryDays)
+}
+
+// apply sets the profile's key usage, extended key usage and basic constraints
+// on template. Encipherment usages are dropped for non-RSA keys, which cannot
+// encrypt.
+func (p *CertProfile) apply(template *x509.Certificate, pub crypto.PublicKey) error {
+	template.KeyUsage = 0
+	for _, name := range p.KeyUsage {
+		usage := keyUsageNames[name]
+		if (usage == x509.KeyUsageKeyEncipherment || usage == x509.KeyUsageDataEncipherment) && !isRSAKey(pub) {
+			continue
+		}
+		template.KeyUsage |= usage
+	}
+
+	template.ExtKeyUsage, template.UnknownExtKeyUsage = nil, nil
+	var oids []asn1.ObjectIdentifier
+	for _, name := range p.ExtKeyUsage {
+		oid, err := extKeyUsageOID(name)
+		if err != nil {
+			return err
+		}
+		oids = append(oids, oid)
+	}
+	if len(oids) > 0 {
+		if p.CriticalEKU {
+			value, err := asn1.Marshal(oids)
+			if err != nil {
+				return err
+			}
+			template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtKeyUsage, Critical: true, Value: value})
+		} else {
+			template.UnknownExtKeyUsage = oids
+		}
+	}
+
+	if p.IsCA || p.BasicConstraints {
+		template.BasicConstraintsValid = true
+		template.IsCA = p.IsCA
+	}
+	if p.OCSPNoCheck {
+		nullValue, _ := asn1.Marshal(asn1.NullRawValue)
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidOCSPNoCheck, Value: nullValue})
+	}
+	return nil
+}
+
+// extKeyUsageOID resolves an extended key usage name or dotted OID.
+func extKeyUsageOID(name string) (asn1.ObjectIdentifier, error) {
+	if oid, ok := extKeyUsageNames[name]; ok {
+		return oid, nil
+	}
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(name, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("unknown extended key usage '%s'", name)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("unknown extended key usage '%s'", name)
+	}
+	return oid, nil
+}
+
+// checkIssuerEKU makes sure a CA whose own certificate restricts extended key
+// usages can issue a certificate with the template's usages; verifiers reject
+// such chains otherwise.
+func checkIssuerEKU(caCert *x509.Certificate, template *x509.Certificate) error {
+	caUsages := extKeyUsageOIDs(caCert.Extensions)
+	if caUsages == nil {
+		return nil
+	}
+	allowed := make(map[string]bool)
+	for _, oid := range caUsages {
+		if oid.Equal(extKeyUsageNames["any"]) {
+			return nil
+		}
+		allowed[oid.String()] = true
+	}
+	requested := template.UnknownExtKeyUsage
+	if critical := extKeyUsageOIDs(template.ExtraExtensions); critical != nil {
+		requested = critical
+	}
+	for _, oid := range requested {
+		if !allowed[oid.String()] {
+			return fmt.Errorf("CA '%s' is restricted by its extended key usage and cannot issue certificates for %s", caCert.Subject.CommonName, oid)
+		}
+	}
+	return nil
+}
+
+// extKeyUsageOIDs returns the usages in an Extended Key Usage extension, or nil if there is none.
+func extKeyUsageOIDs(extensions []pkix.Extension) []asn1.ObjectIdentifier {
+	for _, ext := range extensions {
+		if ext.Id.Equal(oidExtKeyUsage) {
+			var oids []asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(ext.Value, &oids); err == nil {
+				return oids
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RenewInput selects a certificate to renew and what to change.
+type RenewInput struct {
+	CertName      string   `json:"certName"`
+	Profile       string   `json:"profile"`       // Empty to keep the usages the certificate was issued with
+	ExpiryDays    int      `json:"expiryDays"`    // 0 uses the profile's validity
+	ReuseKey      bool     `json:"reuseKey"`      // Keep the existing key instead of generating a new one
+	KeyAlgorithm  string   `json:"keyAlgorithm"`  // New keys; empty for the same algorithm as the old key
+	KeyPassphrase string   `json:"keyPassphrase"` // Encrypts the new or reused key when set
+	RemoveSANs    []string `json:"removeSans"`    // Entries of the old certificate to leave out
+	AddSANs       string   `json:"addSans"`       // Comma-separated, as for new certificates
+	RevokeOld     bool     `json:"revokeOld"`     // Revoke the old certificate as superseded
+}
+
+// RenewCert reissues a certificate from the same CA with the old
+// certificate's subject, SANs and profile and a new validity, optionally
+// reusing its key and adding or removing SANs. The new certificate gets new
+// files; the old one is kept and marked superseded, or revoked if asked.
+func (a *App) RenewCert(input RenewInput) string {
+	certName := input.CertName
+	if certName == "" {
+		return "Error: You must select a certificate to renew."
+	}
+	if input.KeyPassphrase != "" && len(input.KeyPassphrase) < minPassphraseLength {
+		return fmt.Sprintf("Error: The key passphrase must be at least %d characters.", minPassphraseLength)
+	}
+	record := inventoryRecordByName(kindCert, certName)
+	if record == nil {
+		return fmt.Sprintf("Error: Certificate '%s' is not in the store.", certName)
+	}
+	if input.ReuseKey && input.KeyPassphrase != "" {
+		if record.KeyFile == "" {
+			return fmt.Sprintf("Error: The key of '%s' is not held, so it cannot be reused with a passphrase.", certName)
+		}
+		if keyOnToken(record.KeyFile) {
+			return "Error: The key is held on a PKCS#11 token; it is reused as it is, without a passphrase."
+		}
+	}
+	if record.SupersededBy != "" {
+		return fmt.Sprintf("Error: Certificate '%s' has already been renewed as '%s'; renew that one instead.", certName, record.SupersededBy)
+	}
+	oldCert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+	revoked := revocationStatus(oldCert)
+	if input.ReuseKey && revoked != nil && revoked.Reason == "keyCompromise" {
+		return "Error: The certificate was revoked because its key was compromised; renew it with a new key."
+	}
+	caName := record.Issuer
+	if caName == "" {
+		caName = findParent(oldCert, storeCAs())
+	}
+	if caName == "" {
+		return fmt.Sprintf("Error: The CA that issued '%s' is not in the store.", certName)
+	}
+
+	var profile *CertProfile
+	if input.Profile != "" {
+		profile, err = leafProfile(input.Profile)
+	} else {
+		profile, err = matchingProfile(oldCert)
+	}
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	sans := &subjectAltNames{dnsNames: oldCert.DNSNames, ips: oldCert.IPAddresses, emails: oldCert.EmailAddresses, uris: oldCert.URIs, upns: upnsOf(oldCert)}
+	for _, entry := range input.RemoveSANs {
+		if err := sans.remove(entry); err != nil {
+			return fmt.Sprintf("Error: Invalid SAN: %v", err)
+		}
+	}
+	for _, entry := range strings.Split(input.AddSANs, ",") {
+		if err := sans.add(strings.TrimSpace(entry)); err != nil {
+			return fmt.Sprintf("Error: Invalid SAN: %v", err)
+		}
+	}
+
+	caCert, caPrivateKey, err := loadCA(caName)
+	if err != nil {
+		return fmt.Sprintf("Error loading CA: %v", err)
+	}
+	policy := loadCASettings(caName).Policy
+	notAfter, err := policy.leafNotAfter(caCert, profile, input.ExpiryDays)
+	if err != nil {
+		return fmt.Sprintf("Error: Refused by the policy of CA '%s': %v", caName, err)
+	}
+
+	// A held key is checked against the certificate before it is reused, so a
+	// locked one must be unlocked first. It is copied as it is unless it is to
+	// be saved with a new passphrase.
+	var key crypto.Signer
+	publicKey := oldCert.PublicKey
+	if input.ReuseKey && record.KeyFile != "" {
+		reused, err := loadPrivateKey(record.KeyFile)
+		if err != nil {
+			return fmt.Sprintf("Error loading the key to reuse: %v", err)
+		}
+		if !publicKeysEqual(reused.Public(), oldCert.PublicKey) {
+			return fmt.Sprintf("Error: The key file '%s' does not match the certificate.", record.KeyFile)
+		}
+		if input.KeyPassphrase != "" {
+			key = reused
+		}
+	} else if !input.ReuseKey {
+		algorithm := input.KeyAlgorithm
+		if algorithm == "" {
+			algorithm = keyAlgorithmOf(oldCert.PublicKey)
+		}
+		if key, err = generateKey(algorithm, defaultCertKeyAlgorithm); err != nil {
+			return fmt.Sprintf("Error generating key: %v", err)
+		}
+	}
+	if key != nil {
+		publicKey = key.Public()
+	}
+
+	serial, err := newSerialNumber(caName)
+	if err != nil {
+		return fmt.Sprintf("Error generating serial number: %v", err)
+	}
+	cn := oldCert.Subject.CommonName
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		RawSubject:   oldCert.RawSubject,
+		NotBefore:    time.Now(),
+		NotAfter:     notAfter,
+	}
+	applyRevocationURLs(template, caName)
+	if err := sans.apply(template); err != nil {
+		return fmt.Sprintf("Error encoding SANs: %v", err)
+	}
+	if err := profile.apply(template, publicKey); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := policy.check(cn, template, publicKey, profile.Name); err != nil {
+		return fmt.Sprintf("Error: Refused by the policy of CA '%s': %v", caName, err)
+	}
+	if err := checkNameConstraints(caName, cn, template); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := checkIssuerEKU(caCert, template); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, publicKey, caPrivateKey)
+	if err != nil {
+		return fmt.Sprintf("Error signing renewed certificate: %v", err)
+	}
+
+	baseName := uniqueCertFileBase(cn, caName, template.SerialNumber.Text(16))
+	certFile := baseName + ".pem"
+	keyFile := ""
+	if err := writeCertPEM(certFile, certBytes); err != nil {
+		return fmt.Sprintf("Error saving renewed certificate: %v", err)
+	}
+	switch {
+	case key != nil:
+		keyFile = baseName + ".key"
+		if err := writeKeyPEM(keyFile, key, input.KeyPassphrase); err != nil {
+			return fmt.Sprintf("Error saving key: %v", err)
+		}
+	case record.KeyFile != "":
+		keyFile = baseName + ".key"
+		keyData, err := os.ReadFile(filepath.Join(outputDir, record.KeyFile))
+		if err == nil {
+			err = replaceFile(keyFile, keyData, 0600)
+		}
+		if err != nil {
+			return fmt.Sprintf("Error copying key: %v", err)
+		}
+	}
+	if err := recordIssued(kindCert, certFile, certBytes, caName, certFile, keyFile); err != nil {
+		return fmt.Sprintf("Error: Certificate '%s' was renewed but could not be added to the inventory: %v", certFile, err)
+	}
+	if err := supersedeCert(certName, certFile); err != nil {
+		return fmt.Sprintf("Error: Certificate renewed as '%s', but '%s' could not be marked superseded: %v", certFile, certName, err)
+	}
+
+	message := fmt.Sprintf("Success! Certificate '%s' renewed as '%s' (profile %s, valid until %s)", certName, certFile, profile.Name, notAfter.Format("2006-01-02"))
+	switch {
+	case revoked != nil && revoked.Reason != reasonCertificateHold:
+		return message + "; the old certificate stays revoked."
+	case input.RevokeOld:
+		if result := a.RevokeCert(certName, "superseded"); !strings.HasPrefix(result, "Success") {
+			return fmt.Sprintf("%s, but the old certificate could not be revoked: %s", message, strings.TrimPrefix(result, "Error: "))
+		}
+		return message + "; the old certificate has been revoked as superseded."
+	case revoked != nil:
+		return message + "; the old certificate stays on hold."
+	}
+	return message + "; the old certificate is kept as superseded."
+}
+
+// inventoryRecordByName returns a copy of the record of the given kind and
+// name, or nil.
+func inventoryRecordByName(kind, name string) *InventoryRecord {
+	for _, r := range inventoryRecords(kind) {
+		if r.Name == name {
+			return &r
+		}
+	}
+	return nil
+}
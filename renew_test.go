@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestRenewCert renews certificates with new and reused keys, changed SANs,
+// and old certificates that are kept, revoked, on hold or compromised.
+func TestRenewCert(t *testing.T) {
+	t.Chdir(t.TempDir())
+	testStoreRoot(t, "root")
+	app := &App{}
+	created := regexp.MustCompile(`created as '([^']+)'`)
+	renewed := regexp.MustCompile(`renewed as '([^']+)'`)
+	// issue creates a certificate for cn and returns its name
+	issue := func(cn string) string {
+		t.Helper()
+		result := app.CreateCert(CertInput{CommonName: cn, SANs: "www." + cn, CAName: "root"})
+		m := created.FindStringSubmatch(result)
+		if m == nil {
+			t.Fatal(result)
+		}
+		return m[1]
+	}
+	// renew renews a certificate and returns the result and the new name
+	renew := func(input RenewInput) (string, string) {
+		t.Helper()
+		result := app.RenewCert(input)
+		if m := renewed.FindStringSubmatch(result); m != nil {
+			return result, m[1]
+		}
+		return result, ""
+	}
+
+	t.Run("new key and SANs", func(t *testing.T) {
+		oldName := issue("new.example.com")
+		result, newName := renew(RenewInput{CertName: oldName, RemoveSANs: []string{"DNS:www.new.example.com"}, AddSANs: "api.new.example.com"})
+		if newName == "" {
+			t.Fatal(result)
+		}
+		oldCert, _ := loadCert(oldName)
+		newCert, err := loadCert(newName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if publicKeysEqual(oldCert.PublicKey, newCert.PublicKey) {
+			t.Error("the renewal kept the old key")
+		}
+		key, err := loadPrivateKey(certKeyFile(newName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !publicKeysEqual(key.Public(), newCert.PublicKey) {
+			t.Error("the new key file does not match the renewal")
+		}
+		if want := []string{"new.example.com", "api.new.example.com"}; !slices.Equal(newCert.DNSNames, want) {
+			t.Errorf("renewed with SANs %v, want %v", newCert.DNSNames, want)
+		}
+		if newCert.Subject.String() != oldCert.Subject.String() || newCert.SerialNumber.Cmp(oldCert.SerialNumber) == 0 {
+			t.Error("the renewal does not keep the subject with a new serial")
+		}
+		old := inventoryRecordByName(kindCert, oldName)
+		if old.Status != statusSuperseded || old.SupersededBy != newName {
+			t.Errorf("old record has status '%s' and is superseded by '%s'", old.Status, old.SupersededBy)
+		}
+		if result, _ := renew(RenewInput{CertName: oldName}); !strings.Contains(result, "renew that one instead") {
+			t.Errorf("renewing a superseded certificate: %s", result)
+		}
+	})
+
+	t.Run("reused key", func(t *testing.T) {
+		oldName := issue("reuse.example.com")
+		result, newName := renew(RenewInput{CertName: oldName, ReuseKey: true})
+		if newName == "" {
+			t.Fatal(result)
+		}
+		oldCert, _ := loadCert(oldName)
+		newCert, _ := loadCert(newName)
+		if !publicKeysEqual(oldCert.PublicKey, newCert.PublicKey) {
+			t.Error("the renewal did not reuse the key")
+		}
+		oldKey, _ := os.ReadFile(filepath.Join(outputDir, certKeyFile(oldName)))
+		newKey, err := os.ReadFile(filepath.Join(outputDir, certKeyFile(newName)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(oldKey, newKey) {
+			t.Error("the reused key was not copied as it is")
+		}
+	})
+
+	t.Run("reused key that does not match", func(t *testing.T) {
+		oldName := issue("mismatch.example.com")
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeKeyPEM(certKeyFile(oldName), other, ""); err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := renew(RenewInput{CertName: oldName, ReuseKey: true}); !strings.Contains(result, "does not match the certificate") {
+			t.Errorf("got %s", result)
+		}
+	})
+
+	t.Run("revoke old", func(t *testing.T) {
+		oldName := issue("revoke.example.com")
+		result, newName := renew(RenewInput{CertName: oldName, RevokeOld: true})
+		if newName == "" || !strings.Contains(result, "revoked as superseded") {
+			t.Fatal(result)
+		}
+		oldCert, _ := loadCert(oldName)
+		if entry := revocationStatus(oldCert); entry == nil || entry.Reason != "superseded" {
+			t.Errorf("old certificate revocation %+v, want superseded", entry)
+		}
+		if status := inventoryRecordByName(kindCert, oldName).Status; status != statusRevoked {
+			t.Errorf("old record has status '%s', want '%s'", status, statusRevoked)
+		}
+	})
+
+	t.Run("on hold", func(t *testing.T) {
+		oldName := issue("hold.example.com")
+		if result := app.RevokeCert(oldName, reasonCertificateHold); !strings.HasPrefix(result, "Success") {
+			t.Fatal(result)
+		}
+		result, newName := renew(RenewInput{CertName: oldName, ReuseKey: true})
+		if newName == "" || !strings.HasSuffix(result, "the old certificate stays on hold.") {
+			t.Fatal(result)
+		}
+		if status := inventoryRecordByName(kindCert, oldName).Status; status != statusOnHold {
+			t.Errorf("old record has status '%s', want '%s'", status, statusOnHold)
+		}
+	})
+
+	t.Run("key compromise", func(t *testing.T) {
+		oldName := issue("compromised.example.com")
+		if result := app.RevokeCert(oldName, "keyCompromise"); !strings.HasPrefix(result, "Success") {
+			t.Fatal(result)
+		}
+		if result, _ := renew(RenewInput{CertName: oldName, ReuseKey: true}); !strings.Contains(result, "renew it with a new key") {
+			t.Errorf("reusing a compromised key: %s", result)
+		}
+		result, newName := renew(RenewInput{CertName: oldName, RevokeOld: true})
+		if newName == "" || !strings.HasSuffix(result, "the old certificate stays revoked.") {
+			t.Fatal(result)
+		}
+		oldCert, _ := loadCert(oldName)
+		if entry := revocationStatus(oldCert); entry == nil || entry.Reason != "keyCompromise" {
+			t.Errorf("old certificate revocation %+v, want keyCompromise", entry)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// revocationsFile holds the revocation registry inside the output directory.
+const revocationsFile = "revocations.json"
+
+// Revocation reason codes from RFC 5280, section 5.3.1.
+var revocationReasons = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+	"privilegeWithdrawn":   9,
+	"aACompromise":         10,
+}
+
+// reasonCertificateHold is the only reason that can later be released.
+const reasonCertificateHold = "certificateHold"
+
+// revocationEntry records a single revoked certificate.
+type revocationEntry struct {
+	Issuer     string    `json:"issuer"`
+	Serial     string    `json:"serial"` // Hexadecimal
+	CertName   string    `json:"certName"`
+	Subject    string    `json:"subject"`
+	Reason     string    `json:"reason"`
+	ReasonCode int       `json:"reasonCode"`
+	RevokedAt  time.Time `json:"revokedAt"`
+}
+
+// revocationRegistry is the persistent list of revoked certificates for all CAs.
+type revocationRegistry struct {
+	Entries []revocationEntry `json:"entries"`
+}
+
+// revocationMu serialises access to the registry file.
+var revocationMu sync.Mutex
+
+func loadRevocations() (*revocationRegistry, error) {
+	reg := &revocationRegistry{}
+	if err := readJSON(revocationsFile, reg); err != nil {
+		return nil, fmt.Errorf("could not read revocation registry: %w", err)
+	}
+	return reg, nil
+}
+
+func (r *revocationRegistry) save() error {
+	sort.Slice(r.Entries, func(i, j int) bool {
+		if r.Entries[i].Issuer != r.Entries[j].Issuer {
+			return r.Entries[i].Issuer < r.Entries[j].Issuer
+		}
+		return r.Entries[i].RevokedAt.Before(r.Entries[j].RevokedAt)
+	})
+	if err := writeJSON(revocationsFile, r); err != nil {
+		return fmt.Errorf("could not save revocation registry: %w", err)
+	}
+	return nil
+}
+
+// find returns the index of the entry for issuer/serial, or -1.
+func (r *revocationRegistry) find(issuer string, serial *big.Int) int {
+	hexSerial := serial.Text(16)
+	for i, e := range r.Entries {
+		if e.Issuer == issuer && e.Serial == hexSerial {
+			return i
+		}
+	}
+	return -1
+}
+
+// forIssuer returns all entries for the named CA.
+func (r *revocationRegistry) forIssuer(issuer string) []revocationEntry {
+	var entries []revocationEntry
+	for _, e := range r.Entries {
+		if e.Issuer == issuer {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// revocationStatus looks up whether cert has been revoked by its issuer.
+func revocationStatus(cert *x509.Certificate) *revocationEntry {
+	issuer := issuerName(cert)
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+	reg, err := loadRevocations()
+	if err != nil {
+		return nil
+	}
+	if i := reg.find(issuer, cert.SerialNumber); i >= 0 {
+		entry := reg.Entries[i]
+		return &entry
+	}
+	return nil
+}
+
+// RevokeCert marks a certificate as revoked with an RFC 5280 reason name.
+// Use the "certificateHold" reason to suspend a certificate temporarily.
+func (a *App) RevokeCert(certName string, reason string) string {
+	if certName == "" {
+		return "Error: No certificate name provided for revocation."
+	}
+	if reason == "" {
+		reason = "unspecified"
+	}
+	code, ok := revocationReasons[reason]
+	if !ok {
+		return fmt.Sprintf("Error: Unknown revocation reason '%s'. Use one of: %s.", reason, strings.Join(revocationReasonNames(), ", "))
+	}
+
+	cert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+	if isSelfSigned(cert) {
+		return "Error: A self-signed root CA cannot be revoked. Delete it and remove it from trust stores instead."
+	}
+	issuer := issuerName(cert)
+
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+	reg, err := loadRevocations()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	entry := revocationEntry{
+		Issuer:     issuer,
+		Serial:     cert.SerialNumber.Text(16),
+		CertName:   certName,
+		Subject:    cert.Subject.CommonName,
+		Reason:     reason,
+		ReasonCode: code,
+		RevokedAt:  time.Now().UTC(),
+	}
+	if i := reg.find(issuer, cert.SerialNumber); i >= 0 {
+		existing := reg.Entries[i]
+		if existing.Reason != reasonCertificateHold {
+			return fmt.Sprintf("Error: Certificate '%s' is already revoked (%s).", certName, existing.Reason)
+		}
+		if reason == reasonCertificateHold {
+			return fmt.Sprintf("Error: Certificate '%s' is already on hold.", certName)
+		}
+		// A held certificate keeps its original revocation date when the hold becomes permanent
+		entry.RevokedAt = existing.RevokedAt
+		reg.Entries[i] = entry
+	} else {
+		reg.Entries = append(reg.Entries, entry)
+	}
+
+	if err := reg.save(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	status := statusRevoked
+	if reason == reasonCertificateHold {
+		status = statusOnHold
+	}
+	if err := setInventoryStatus(issuer, entry.Serial, status); err != nil {
+		return fmt.Sprintf("Error: Certificate was revoked but the inventory could not be updated: %v", err)
+	}
+	if reason == reasonCertificateHold {
+		return fmt.Sprintf("Success! Certificate '%s' has been put on hold.", certName)
+	}
+	return fmt.Sprintf("Success! Certificate '%s' has been revoked (%s).", certName, reason)
+}
+
+// ReleaseCertHold lifts a certificateHold revocation so the certificate is valid again.
+func (a *App) ReleaseCertHold(certName string) string {
+	if certName == "" {
+		return "Error: No certificate name provided."
+	}
+	cert, err := loadCert(certName)
+	if err != nil {
+		return fmt.Sprintf("Error loading certificate '%s': %v", certName, err)
+	}
+	issuer := issuerName(cert)
+
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+	reg, err := loadRevocations()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	i := reg.find(issuer, cert.SerialNumber)
+	if i < 0 {
+		return fmt.Sprintf("Error: Certificate '%s' is not on hold.", certName)
+	}
+	if reg.Entries[i].Reason != reasonCertificateHold {
+		return fmt.Sprintf("Error: Certificate '%s' is permanently revoked (%s) and cannot be released.", certName, reg.Entries[i].Reason)
+	}
+	reg.Entries = append(reg.Entries[:i], reg.Entries[i+1:]...)
+	if err := reg.save(); err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if err := setInventoryStatus(issuer, cert.SerialNumber.Text(16), statusValid); err != nil {
+		return fmt.Sprintf("Error: Hold was released but the inventory could not be updated: %v", err)
+	}
+	return fmt.Sprintf("Success! Hold on certificate '%s' has been released.", certName)
+}
+
+// ListRevocationReasons returns the reason names accepted by RevokeCert.
+func (a *App) ListRevocationReasons() []string {
+	return revocationReasonNames()
+}
+
+// revocationReasonNames returns the reason names ordered by reason code.
+func revocationReasonNames() []string {
+	names := make([]string, 0, len(revocationReasons))
+	for name := range revocationReasons {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return revocationReasons[names[i]] < revocationReasons[names[j]] })
+	return names
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	// oidSubjectAltName is the Subject Alternative Name extension.
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	// oidUPN is Microsoft's User Principal Name otherName, used for smart card logon.
+	oidUPN = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3}
+)
+
+// GeneralName tags from RFC 5280, section 4.2.1.6.
+const (
+	sanTagOtherName = 0
+	sanTagEmail     = 1
+	sanTagDNS       = 2
+	sanTagURI       = 6
+	sanTagIP        = 7
+)
+
+// subjectAltNames holds the validated Subject Alternative Names for a new certificate.
+type subjectAltNames struct {
+	dnsNames []string
+	ips      []net.IP
+	emails   []string
+	uris     []*url.URL
+	upns     []string
+}
+
+// parseSANs parses comma-separated SAN entries, preceded by the Common Name
+// when it is an IP address or a valid hostname. Each entry may carry a type
+// prefix (DNS:, IP:, email:, URI: or UPN:); untyped entries are treated as an
+// IP address, an email address (with an @) or a URI (with ://), and otherwise
+// as a DNS name. Unicode domain names are converted to punycode.
+func parseSANs(cn, entries string) (*subjectAltNames, error) {
+	sans := &subjectAltNames{}
+	if net.ParseIP(cn) != nil {
+		sans.add("IP:" + cn)
+	} else if name, err := normalizeHostname(cn); err == nil {
+		sans.dnsNames = append(sans.dnsNames, name)
+	}
+	for _, entry := range strings.Split(entries, ",") {
+		if err := sans.add(strings.TrimSpace(entry)); err != nil {
+			return nil, err
+		}
+	}
+	return sans, nil
+}
+
+// add parses and appends a single SAN entry, skipping empty entries and duplicates.
+func (s *subjectAltNames) add(entry string) error {
+	if entry == "" {
+		return nil
+	}
+	kind, value := "", entry
+	if i := strings.Index(entry, ":"); i > 0 {
+		switch prefix := strings.ToLower(entry[:i]); prefix {
+		case "dns", "ip", "email", "uri", "upn":
+			kind, value = prefix, strings.TrimSpace(entry[i+1:])
+		}
+	}
+	if kind == "" {
+		switch {
+		case net.ParseIP(entry) != nil:
+			kind = "ip"
+		case strings.Contains(entry, "://"):
+			kind = "uri"
+		case strings.Contains(entry, "@"):
+			kind = "email"
+		default:
+			kind = "dns"
+		}
+	}
+
+	switch kind {
+	case "dns":
+		name, err := normalizeHostname(value)
+		if err != nil {
+			return err
+		}
+		s.dnsNames = appendUnique(s.dnsNames, name)
+	case "ip":
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("'%s' is not a valid IP address", value)
+		}
+		for _, existing := range s.ips {
+			if existing.Equal(ip) {
+				return nil
+			}
+		}
+		s.ips = append(s.ips, ip)
+	case "email":
+		email, err := normalizeEmail(value)
+		if err != nil {
+			return err
+		}
+		s.emails = appendUnique(s.emails, email)
+	case "uri":
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || !isIA5(value) {
+			return fmt.Errorf("'%s' is not a valid absolute URI", value)
+		}
+		if u.Scheme == "spiffe" && (u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "") {
+			return fmt.Errorf("'%s' is not a valid SPIFFE ID; use spiffe://trust-domain/path", value)
+		}
+		for _, existing := range s.uris {
+			if existing.String() == u.String() {
+				return nil
+			}
+		}
+		s.uris = append(s.uris, u)
+	case "upn":
+		if at := strings.LastIndex(value, "@"); at <= 0 || at == len(value)-1 {
+			return fmt.Errorf("'%s' is not a valid User Principal Name; use user@domain", value)
+		}
+		s.upns = appendUnique(s.upns, value)
+	}
+	return nil
+}
+
+// apply sets the names on template. Go cannot encode otherNames, so when
+// there are UPNs the whole extension is encoded by hand and takes precedence.
+func (s *subjectAltNames) apply(template *x509.Certificate) error {
+	template.DNSNames = s.dnsNames
+	template.IPAddresses = s.ips
+	template.EmailAddresses = s.emails
+	template.URIs = s.uris
+	if len(s.upns) == 0 {
+		return nil
+	}
+	ext, err := s.marshal()
+	if err != nil {
+		return err
+	}
+	template.ExtraExtensions = append(template.ExtraExtensions, ext)
+	return nil
+}
+
+// remove drops a single SAN entry, parsed as by add, if it is present.
+func (s *subjectAltNames) remove(entry string) error {
+	drop := &subjectAltNames{}
+	if err := drop.add(entry); err != nil {
+		return err
+	}
+	without := func(values, drop []string) []string {
+		var out []string
+		for _, v := range values {
+			if !containsFold(drop, v) {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	s.dnsNames = without(s.dnsNames, drop.dnsNames)
+	s.emails = without(s.emails, drop.emails)
+	s.upns = without(s.upns, drop.upns)
+	var ips []net.IP
+	for _, ip := range s.ips {
+		if len(drop.ips) == 0 || !drop.ips[0].Equal(ip) {
+			ips = append(ips, ip)
+		}
+	}
+	s.ips = ips
+	var uris []*url.URL
+	for _, u := range s.uris {
+		if len(drop.uris) == 0 || drop.uris[0].String() != u.String() {
+			uris = append(uris, u)
+		}
+	}
+	s.uris = uris
+	return nil
+}
+
+// list returns the names as typed entries, e.g. "DNS:host.example.com", that add accepts.
+func (s *subjectAltNames) list() []string {
+	var entries []string
+	for _, name := range s.dnsNames {
+		entries = append(entries, "DNS:"+name)
+	}
+	for _, ip := range s.ips {
+		entries = append(entries, "IP:"+ip.String())
+	}
+	for _, email := range s.emails {
+		entries = append(entries, "email:"+email)
+	}
+	for _, u := range s.uris {
+		entries = append(entries, "URI:"+u.String())
+	}
+	for _, upn := range s.upns {
+		entries = append(entries, "UPN:"+upn)
+	}
+	return entries
+}
+
+// marshal encodes all names as a Subject Alternative Name extension.
+func (s *subjectAltNames) marshal() (pkix.Extension, error) {
+	var names []asn1.RawValue
+	for _, upn := range s.upns {
+		// otherName ::= [0] IMPLICIT SEQUENCE { type-id OID, value [0] EXPLICIT UTF8String }
+		typeID, err := asn1.Marshal(oidUPN)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		utf8, err := asn1.MarshalWithParams(upn, "utf8")
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		value, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: utf8})
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: sanTagOtherName, IsCompound: true, Bytes: append(typeID, value...)})
+	}
+	for _, email := range s.emails {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: sanTagEmail, Bytes: []byte(email)})
+	}
+	for _, name := range s.dnsNames {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: sanTagDNS, Bytes: []byte(name)})
+	}
+	for _, u := range s.uris {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: sanTagURI, Bytes: []byte(u.String())})
+	}
+	for _, ip := range s.ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: sanTagIP, Bytes: ip})
+	}
+	value, err := asn1.Marshal(names)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidSubjectAltName, Value: value}, nil
+}
+
+// normalizeHostname converts a hostname, optionally with a leading "*."
+// wildcard, to its ASCII (punycode) form and checks that it is valid.
+func normalizeHostname(name string) (string, error) {
+	host := strings.TrimSuffix(name, ".")
+	wildcard := strings.HasPrefix(host, "*.")
+	if wildcard {
+		host = host[2:]
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil || ascii == "" || len(ascii) > 253 {
+		return "", fmt.Errorf("'%s' is not a valid hostname", name)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" || len(label) > 63 {
+			return "", fmt.Errorf("'%s' is not a valid hostname", name)
+		}
+	}
+	if wildcard {
+		ascii = "*." + ascii
+	}
+	return ascii, nil
+}
+
+// normalizeEmail checks an email address and converts its domain to punycode.
+func normalizeEmail(email string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "", fmt.Errorf("'%s' is not a valid email address", email)
+	}
+	local, domain := email[:at], email[at+1:]
+	if !isIA5(local) || strings.ContainsAny(local, " @") {
+		return "", fmt.Errorf("'%s' is not a valid email address; the part before @ must be ASCII", email)
+	}
+	ascii, err := normalizeHostname(domain)
+	if err != nil || strings.HasPrefix(ascii, "*.") {
+		return "", fmt.Errorf("'%s' is not a valid email address", email)
+	}
+	return local + "@" + ascii, nil
+}
+
+// appendUnique appends value unless it is already present, ignoring case.
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// upnsOf returns the User Principal Names in a certificate's SAN extension,
+// which crypto/x509 does not parse.
+func upnsOf(cert *x509.Certificate) []string {
+	return upnsIn(cert.Extensions)
+}
+
+// upnsIn returns the User Principal Names in a SAN extension among extensions.
+func upnsIn(extensions []pkix.Extension) []string {
+	var upns []string
+	for _, ext := range extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var names []asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &names); err != nil {
+			return nil
+		}
+		for _, name := range names {
+			if name.Class != asn1.ClassContextSpecific || name.Tag != sanTagOtherName {
+				continue
+			}
+			var typeID asn1.ObjectIdentifier
+			rest, err := asn1.Unmarshal(name.Bytes, &typeID)
+			if err != nil || !typeID.Equal(oidUPN) {
+				continue
+			}
+			var value asn1.RawValue
+			if _, err := asn1.Unmarshal(rest, &value); err != nil {
+				continue
+			}
+			var upn string
+			if _, err := asn1.UnmarshalWithParams(value.Bytes, &upn, "utf8"); err == nil {
+				upns = append(upns, upn)
+			}
+		}
+	}
+	return upns
+}
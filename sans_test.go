@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseSANs checks how entries are typed, normalised and refused.
+func TestParseSANs(t *testing.T) {
+	tests := []struct {
+		cn, entries string
+		want        []string
+	}{
+		{"www.example.com", "", []string{"DNS:www.example.com"}},
+		{"Web server", "www.example.com", []string{"DNS:www.example.com"}},
+		{"192.0.2.1", "", []string{"IP:192.0.2.1"}},
+		{
+			"www.example.com",
+			"api.example.com, 192.0.2.1, 2001:db8::1, admin@example.com, https://example.com/app, UPN:jdoe@corp.example.com",
+			[]string{"DNS:www.example.com", "DNS:api.example.com", "IP:192.0.2.1", "IP:2001:db8::1", "email:admin@example.com", "URI:https://example.com/app", "UPN:jdoe@corp.example.com"},
+		},
+		// Typed prefixes ignore case and override the guess from the value
+		{"", "dns:www.example.com, Ip:192.0.2.1, EMAIL:admin@example.com, uri:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6, upn:jdoe@corp", []string{"DNS:www.example.com", "IP:192.0.2.1", "email:admin@example.com", "URI:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6", "UPN:jdoe@corp"}},
+		{"", "spiffe://example.org/ns/prod/sa/web", []string{"URI:spiffe://example.org/ns/prod/sa/web"}},
+		// Duplicates are dropped, ignoring case
+		{"www.example.com", "WWW.Example.com, www.example.com., IP:192.0.2.1, 192.0.2.1, ::ffff:192.0.2.1", []string{"DNS:www.example.com", "IP:192.0.2.1"}},
+		// Unicode domain names become punycode
+		{"bücher.example", "*.münchen.de, email:info@bücher.example", []string{"DNS:xn--bcher-kva.example", "DNS:*.xn--mnchen-3ya.de", "email:info@xn--bcher-kva.example"}},
+	}
+	for _, tc := range tests {
+		sans, err := parseSANs(tc.cn, tc.entries)
+		if err != nil {
+			t.Errorf("parseSANs(%q, %q): %v", tc.cn, tc.entries, err)
+			continue
+		}
+		if got := sans.list(); !slices.Equal(got, tc.want) {
+			t.Errorf("parseSANs(%q, %q) = %v, want %v", tc.cn, tc.entries, got, tc.want)
+		}
+	}
+
+	refusals := []struct{ entry, reason string }{
+		{"IP:192.0.2.256", "not a valid IP address"},
+		{"exa..mple.com", "not a valid hostname"},
+		{"DNS:" + strings.Repeat("a", 64) + ".example.com", "not a valid hostname"},
+		{"email:@example.com", "not a valid email address"},
+		{"josé@example.com", "the part before @ must be ASCII"},
+		{"email:admin@*.example.com", "not a valid email address"},
+		{"URI:example.com/app", "not a valid absolute URI"},
+		{"https://bücher.example/", "not a valid absolute URI"},
+		{"spiffe://example.org/web?x=1", "not a valid SPIFFE ID"},
+		{"spiffe:///web", "not a valid SPIFFE ID"},
+		{"UPN:jdoe", "not a valid User Principal Name"},
+		{"UPN:jdoe@", "not a valid User Principal Name"},
+	}
+	for _, tc := range refusals {
+		if _, err := parseSANs("www.example.com", "api.example.com, "+tc.entry); err == nil || !strings.Contains(err.Error(), tc.reason) {
+			t.Errorf("SAN %q: got %v, want %q", tc.entry, err, tc.reason)
+		}
+	}
+}
+
+// TestSANsRoundTrip encodes names with UPNs by hand and checks that
+// crypto/x509 and upnsOf read all of them back.
+func TestSANsRoundTrip(t *testing.T) {
+	sans, err := parseSANs("www.bücher.example", "192.0.2.1, 2001:db8::1, admin@example.com, https://example.com/app, UPN:jdoe@corp.example.com, UPN:Jörg@corp.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, err := sans.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"jdoe@corp.example.com", "Jörg@corp.example.com"}; !slices.Equal(upnsIn([]pkix.Extension{ext}), want) {
+		t.Errorf("upnsIn = %v, want %v", upnsIn([]pkix.Extension{ext}), want)
+	}
+
+	template := &x509.Certificate{Subject: pkix.Name{CommonName: "www.bücher.example"}}
+	if err := sans.apply(template); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := testIssue(t, template, nil, nil)
+	parsed := &subjectAltNames{dnsNames: cert.DNSNames, ips: cert.IPAddresses, emails: cert.EmailAddresses, uris: cert.URIs, upns: upnsOf(cert)}
+	if got, want := parsed.list(), sans.list(); !slices.Equal(got, want) {
+		t.Errorf("read back %v, want %v", got, want)
+	}
+	if cert.DNSNames[0] != "www.xn--bcher-kva.example" {
+		t.Errorf("DNS name %s is not punycode", cert.DNSNames[0])
+	}
+
+	// Without UPNs, crypto/x509 encodes the extension itself
+	plain, _ := parseSANs("www.example.com", "192.0.2.1")
+	template = &x509.Certificate{Subject: pkix.Name{CommonName: "www.example.com"}}
+	if err := plain.apply(template); err != nil {
+		t.Fatal(err)
+	}
+	if len(template.ExtraExtensions) != 0 {
+		t.Error("a SAN extension without UPNs was encoded by hand")
+	}
+	if cert, _ := testIssue(t, template, nil, nil); upnsOf(cert) != nil {
+		t.Errorf("found UPNs %v in a certificate without any", upnsOf(cert))
+	}
+
+	// Other otherNames and malformed extensions carry no UPNs
+	other, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 9})
+	value, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: []byte{0x0c, 0x01, 'x'}})
+	names, _ := asn1.Marshal([]asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: sanTagOtherName, IsCompound: true, Bytes: append(other, value...)}})
+	for _, ext := range []pkix.Extension{{Id: oidSubjectAltName, Value: names}, {Id: oidSubjectAltName, Value: []byte{0x30, 0x05}}} {
+		if upns := upnsIn([]pkix.Extension{ext}); upns != nil {
+			t.Errorf("upnsIn(%x) = %v", ext.Value, upns)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+)
+
+const (
+	// serialBits is the size of generated serial numbers. The CA/Browser Forum
+	// requires at least 64 bits of randomness; one bit is lost to keep the
+	// DER INTEGER positive within 20 octets.
+	serialBits = 128
+	// serialAttempts bounds the retries when a generated serial is already taken.
+	serialAttempts = 10
+)
+
+// newSerialNumber returns a random serial number that has never been used by
+// the named issuing CA. issuer may be "" for a self-signed root.
+func newSerialNumber(issuer string) (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), serialBits-1)
+	used := usedSerials(issuer)
+	for i := 0; i < serialAttempts; i++ {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, err
+		}
+		if serial.Sign() > 0 && !used[serial.Text(16)] {
+			return serial, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find an unused serial number for CA '%s'", issuer)
+}
+
+// usedSerials returns every serial (hex) the issuer has handed out, including
+// those of certificates that were deleted, whether or not they were revoked.
+func usedSerials(issuer string) map[string]bool {
+	used := make(map[string]bool)
+	if issuer == "" {
+		return used
+	}
+	inventoryMu.Lock()
+	inv, err := loadInventory()
+	inventoryMu.Unlock()
+	if err != nil {
+		log.Printf("%v", err)
+	} else {
+		for _, r := range inv.Records {
+			if r.Issuer == issuer {
+				used[r.Serial] = true
+			}
+		}
+		for _, t := range inv.Tombstones {
+			if t.Issuer == issuer {
+				used[t.Serial] = true
+			}
+		}
+	}
+	revocationMu.Lock()
+	reg, err := loadRevocations()
+	revocationMu.Unlock()
+	if err == nil {
+		for _, e := range reg.forIssuer(issuer) {
+			used[e.Serial] = true
+		}
+	}
+	return used
+}
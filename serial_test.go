@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+// TestUsedSerialsAfterDelete checks that the serial of a deleted certificate,
+// or of one whose file has gone, stays taken under its issuer.
+func TestUsedSerialsAfterDelete(t *testing.T) {
+	t.Chdir(t.TempDir())
+	_, intermediate, leaf, _, intermediateKey, _ := testHierarchy(t, "serial.example.com")
+	if err := addToInventory(kindCert, "leaf.pem", leaf, "intermediate", "leaf.pem", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToInventory(kindCA, "intermediate", intermediate, "root", "intermediate.pem", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []struct{ kind, name string }{{kindCert, "leaf.pem"}, {kindCA, "intermediate"}} {
+		if err := removeFromInventory(r.kind, r.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A rebuild drops the record whose file is missing and keeps the tombstones
+	gone, _ := testIssue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "gone.example.com"}}, intermediate, intermediateKey)
+	if err := addToInventory(kindCert, "gone.pem", gone, "intermediate", "gone.pem", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rebuildInventory(); err != nil {
+		t.Fatal(err)
+	}
+	if !usedSerials("intermediate")[gone.SerialNumber.Text(16)] {
+		t.Error("the serial of the certificate whose file has gone is free again")
+	}
+	if !usedSerials("intermediate")[leaf.SerialNumber.Text(16)] {
+		t.Error("the deleted certificate's serial is free again")
+	}
+	if !usedSerials("root")[intermediate.SerialNumber.Text(16)] {
+		t.Error("the deleted CA's serial is free again")
+	}
+}
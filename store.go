@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// readJSON loads a JSON state file from the output directory into v.
+// A missing file leaves v untouched.
+func readJSON(fileName string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(outputDir, fileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON saves v as a JSON state file in the output directory. The file is
+// written to a temporary name first so a crash never leaves it half-written.
+func writeJSON(fileName string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	return replaceFile(fileName, data, 0600)
+}
+
+// replaceFile writes data to a file in the output directory through a
+// temporary file in the same directory, synced and then renamed over the old
+// one, so a crash or a full disk never leaves the file half-written.
+func replaceFile(fileName string, data []byte, perm os.FileMode) error {
+	path := filepath.Join(outputDir, fileName)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// Attribute types of the subject components, in the order they are encoded.
+var (
+	oidCountry            = asn1.ObjectIdentifier{2, 5, 4, 6}
+	oidProvince           = asn1.ObjectIdentifier{2, 5, 4, 8}
+	oidLocality           = asn1.ObjectIdentifier{2, 5, 4, 7}
+	oidOrganization       = asn1.ObjectIdentifier{2, 5, 4, 10}
+	oidOrganizationalUnit = asn1.ObjectIdentifier{2, 5, 4, 11}
+	oidCommonName         = asn1.ObjectIdentifier{2, 5, 4, 3}
+	oidSerialNumber       = asn1.ObjectIdentifier{2, 5, 4, 5}
+	oidEmailAddress       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+	oidDomainComponent    = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+)
+
+// SubjectInput holds the optional distinguished name components of a
+// certificate subject, besides the Common Name.
+type SubjectInput struct {
+	Country          string   `json:"country"`
+	State            string   `json:"state"`
+	Locality         string   `json:"locality"`
+	Org              string   `json:"org"`
+	OrgUnits         []string `json:"orgUnits"`
+	Email            string   `json:"email"`
+	SerialNumber     string   `json:"serialNumber"`
+	DomainComponents []string `json:"domainComponents"` // Most significant last, e.g. ["corp", "example", "com"]
+}
+
+// withDefaults fills every empty component of s from defaults.
+func (s SubjectInput) withDefaults(defaults SubjectInput) SubjectInput {
+	if s.Country == "" {
+		s.Country = defaults.Country
+	}
+	if s.State == "" {
+		s.State = defaults.State
+	}
+	if s.Locality == "" {
+		s.Locality = defaults.Locality
+	}
+	if s.Org == "" {
+		s.Org = defaults.Org
+	}
+	if len(s.OrgUnits) == 0 {
+		s.OrgUnits = defaults.OrgUnits
+	}
+	if s.Email == "" {
+		s.Email = defaults.Email
+	}
+	if s.SerialNumber == "" {
+		s.SerialNumber = defaults.SerialNumber
+	}
+	if len(s.DomainComponents) == 0 {
+		s.DomainComponents = defaults.DomainComponents
+	}
+	return s
+}
+
+// clean trims every component and drops empty OU and DC values.
+func (s SubjectInput) clean() SubjectInput {
+	trimAll := func(values []string) []string {
+		var out []string
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	s.Country = strings.ToUpper(strings.TrimSpace(s.Country))
+	s.State = strings.TrimSpace(s.State)
+	s.Locality = strings.TrimSpace(s.Locality)
+	s.Org = strings.TrimSpace(s.Org)
+	s.OrgUnits = trimAll(s.OrgUnits)
+	s.Email = strings.TrimSpace(s.Email)
+	s.SerialNumber = strings.TrimSpace(s.SerialNumber)
+	s.DomainComponents = trimAll(s.DomainComponents)
+	return s
+}
+
+// validate checks the components against the RFC 5280 upper bounds and string types.
+func (s SubjectInput) validate() error {
+	if s.Country != "" && (len(s.Country) != 2 || !isASCIILetters(s.Country)) {
+		return fmt.Errorf("country '%s' must be a two-letter ISO 3166 code", s.Country)
+	}
+	type limit struct {
+		name  string
+		value string
+		max   int
+	}
+	limits := []limit{
+		{"state", s.State, 128},
+		{"locality", s.Locality, 128},
+		{"organization", s.Org, 64},
+		{"email address", s.Email, 255},
+		{"serial number", s.SerialNumber, 64},
+	}
+	for _, ou := range s.OrgUnits {
+		limits = append(limits, limit{"organizational unit", ou, 64})
+	}
+	for _, l := range limits {
+		if len(l.value) > l.max {
+			return fmt.Errorf("%s '%s' is longer than %d characters", l.name, l.value, l.max)
+		}
+	}
+	if s.Email != "" && (!isIA5(s.Email) || strings.Count(s.Email, "@") != 1 || strings.HasPrefix(s.Email, "@") || strings.HasSuffix(s.Email, "@")) {
+		return fmt.Errorf("'%s' is not a valid email address", s.Email)
+	}
+	for _, dc := range s.DomainComponents {
+		if !isIA5(dc) || strings.ContainsAny(dc, ". ") {
+			return fmt.Errorf("domain component '%s' must be a single ASCII label", dc)
+		}
+	}
+	return nil
+}
+
+// subjectDN encodes a subject in the conventional order DC, C, ST, L, O, OU,
+// CN, serialNumber, emailAddress. emailAddress and DC are IA5Strings as
+// required by RFC 5280, which pkix.Name cannot produce.
+func subjectDN(cn string, s SubjectInput) ([]byte, error) {
+	var rdns pkix.RDNSequence
+	add := func(oid asn1.ObjectIdentifier, value interface{}) {
+		rdns = append(rdns, pkix.RelativeDistinguishedNameSET{{Type: oid, Value: value}})
+	}
+	ia5 := func(value string) asn1.RawValue {
+		return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagIA5String, Bytes: []byte(value)}
+	}
+
+	for i := len(s.DomainComponents) - 1; i >= 0; i-- {
+		add(oidDomainComponent, ia5(s.DomainComponents[i]))
+	}
+	if s.Country != "" {
+		add(oidCountry, s.Country)
+	}
+	if s.State != "" {
+		add(oidProvince, s.State)
+	}
+	if s.Locality != "" {
+		add(oidLocality, s.Locality)
+	}
+	if s.Org != "" {
+		add(oidOrganization, s.Org)
+	}
+	for _, ou := range s.OrgUnits {
+		add(oidOrganizationalUnit, ou)
+	}
+	if cn != "" {
+		add(oidCommonName, cn)
+	}
+	if s.SerialNumber != "" {
+		add(oidSerialNumber, s.SerialNumber)
+	}
+	if s.Email != "" {
+		add(oidEmailAddress, ia5(s.Email))
+	}
+	return asn1.Marshal(rdns)
+}
+
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isIA5 reports whether s only holds printable ASCII characters.
+func isIA5(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
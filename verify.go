@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// verifyExtKeyUsages maps the extended key usage names that x509.Verify
+// understands to its constants.
+var verifyExtKeyUsages = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"ipsecEndSystem":  x509.ExtKeyUsageIPSECEndSystem,
+	"ipsecTunnel":     x509.ExtKeyUsageIPSECTunnel,
+	"ipsecUser":       x509.ExtKeyUsageIPSECUser,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"OCSPSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// VerifyInput selects a certificate and what to verify it against.
+type VerifyInput struct {
+	CertName    string `json:"certName"`
+	CAName      string `json:"caName"`      // The CA to verify against; blank for every CA in the store
+	Hostname    string `json:"hostname"`    // Optional DNS name or IP address the certificate must cover
+	Usage       string `json:"usage"`       // Optional key usage or extended key usage name, e.g. serverAuth
+	At          string `json:"at"`          // Optional point in time, e.g. 2025-06-30 or 2025-06-30T12:00; blank for now
+	KeyPath     string `json:"keyPath"`     // Optional key file to match; blank for the certificate's own key
+	KeyPassword string `json:"keyPassword"` // For an encrypted key
+}
+
+// VerifyReport is the outcome of VerifyCert, check by check.
+type VerifyReport struct {
+	Valid  bool          `json:"valid"` // Every check passed
+	At     string        `json:"at"`
+	Chain  []string      `json:"chain"` // Subjects from the certificate up to the trust anchor
+	Checks []VerifyCheck `json:"checks"`
+}
+
+// VerifyCheck is one check made by VerifyCert.
+type VerifyCheck struct {
+	Name   string `json:"name"` // e.g. "Signature of 'web' by 'Issuing CA'"
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"` // Why it failed, or what was found
+}
+
+// VerifyCert builds and validates a certificate's chain against one CA, or
+// every CA in the store, at a chosen time. It checks each link of the chain
+// in turn so that a failure names the link at fault, then has x509.Verify
+// validate the whole path, and optionally checks a hostname or IP, a key
+// usage, revocation and that a key file matches the certificate.
+func (a *App) VerifyCert(input VerifyInput) (*VerifyReport, error) {
+	if input.CertName == "" {
+		return nil, fmt.Errorf("no certificate selected")
+	}
+	cert, err := loadCert(input.CertName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load certificate '%s': %w", input.CertName, err)
+	}
+	at := time.Now()
+	if input.At != "" {
+		if at, err = parseVerifyTime(input.At); err != nil {
+			return nil, err
+		}
+	}
+
+	cas := storeCAs()
+	if input.CAName != "" {
+		if _, ok := cas[input.CAName]; !ok {
+			return nil, fmt.Errorf("CA '%s' not found", input.CAName)
+		}
+		// Only the chosen CA and the CAs above it may appear in the chain
+		chosen := make(map[string]*caEntry)
+		for name := input.CAName; name != "" && chosen[name] == nil; name = cas[name].issuer {
+			if cas[name] == nil {
+				break
+			}
+			chosen[name] = cas[name]
+		}
+		cas = chosen
+	}
+
+	report := &VerifyReport{At: at.Format(time.RFC1123), Chain: []string{}, Checks: []VerifyCheck{}}
+	check := func(name string, err error, detail string) {
+		c := VerifyCheck{Name: name, Passed: err == nil, Detail: detail}
+		if err != nil {
+			c.Detail = err.Error()
+		}
+		report.Checks = append(report.Checks, c)
+	}
+
+	path, names := verifyChainLinks(cert, cas, at, report, check)
+	if input.CAName != "" && !containsFold(names, input.CAName) {
+		check(fmt.Sprintf("Issued under CA '%s'", input.CAName),
+			fmt.Errorf("the chain does not pass through CA '%s'", input.CAName), "")
+	}
+	verifyPath(cert, cas, at, input.Usage, report, check)
+	if input.Hostname != "" {
+		host := strings.TrimSpace(input.Hostname)
+		check(fmt.Sprintf("Covers '%s'", host), cert.VerifyHostname(host), "listed in the Subject Alternative Names")
+	}
+	if input.Usage != "" {
+		detail, err := verifyUsage(cert, input.Usage)
+		check(fmt.Sprintf("Usage '%s'", input.Usage), err, detail)
+	}
+	verifyRevocation(path, at, check)
+	verifyKeyMatch(input, cert, check)
+
+	report.Valid = true
+	for _, c := range report.Checks {
+		report.Valid = report.Valid && c.Passed
+	}
+	return report, nil
+}
+
+// parseVerifyTime reads a date, or a date and time, in local time.
+func parseVerifyTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a date; use e.g. 2025-06-30 or 2025-06-30T12:00", value)
+}
+
+// verifyChainLinks walks from cert up through the CAs in cas, checking each
+// certificate's validity at the chosen time, each signature and each CA's
+// path length. It returns the chain found and the names of its CAs.
+func verifyChainLinks(cert *x509.Certificate, cas map[string]*caEntry, at time.Time, report *VerifyReport, check func(string, error, string)) ([]*x509.Certificate, []string) {
+	path := []*x509.Certificate{cert}
+	var names []string
+	for current := cert; ; {
+		report.Chain = append(report.Chain, current.Subject.String())
+		check(fmt.Sprintf("Validity of '%s'", current.Subject.CommonName), validAt(current, at),
+			fmt.Sprintf("%s to %s", current.NotBefore.Format(time.RFC1123), current.NotAfter.Format(time.RFC1123)))
+		if isSelfSigned(current) {
+			check(fmt.Sprintf("Trust anchor '%s'", current.Subject.CommonName), nil, "self-signed root")
+			break
+		}
+		parent := findParent(current, cas)
+		if parent == "" {
+			check(fmt.Sprintf("Issuer of '%s'", current.Subject.CommonName), missingIssuerError(current, cas), "")
+			break
+		}
+		issuer := cas[parent].cert
+		check(fmt.Sprintf("Signature of '%s' by '%s'", current.Subject.CommonName, parent), current.CheckSignatureFrom(issuer),
+			"signed by the CA's key; the CA may sign certificates")
+		// A CA's path length limits the intermediate CAs below it, not counting the certificate itself
+		if pathLen := pathLenOf(issuer); pathLen >= 0 {
+			below := len(path) - 1
+			var err error
+			if below > pathLen {
+				err = fmt.Errorf("CA '%s' has path length %d but %d intermediate CA(s) sit below it", parent, pathLen, below)
+			}
+			check(fmt.Sprintf("Path length of '%s'", parent), err, fmt.Sprintf("%d allowed, %d used", pathLen, below))
+		}
+		names = append(names, parent)
+		path = append(path, issuer)
+		if containsCert(path[:len(path)-1], issuer) {
+			break
+		}
+		current = issuer
+	}
+	return path, names
+}
+
+// missingIssuerError explains why no issuer was found for cert among cas.
+func missingIssuerError(cert *x509.Certificate, cas map[string]*caEntry) error {
+	for name, ca := range cas {
+		if bytes.Equal(ca.cert.RawSubject, cert.RawIssuer) {
+			if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+				return fmt.Errorf("CA '%s' has the issuer's name but its key did not sign this certificate: %v", name, err)
+			}
+		}
+	}
+	return fmt.Errorf("no CA with subject '%s' is in the store, or among the CAs verified against", cert.Issuer)
+}
+
+// validAt reports whether cert is within its validity period at t.
+func validAt(cert *x509.Certificate, t time.Time) error {
+	switch {
+	case t.Before(cert.NotBefore):
+		return fmt.Errorf("not yet valid: valid from %s", cert.NotBefore.Format(time.RFC1123))
+	case t.After(cert.NotAfter):
+		return fmt.Errorf("expired on %s", cert.NotAfter.Format(time.RFC1123))
+	}
+	return nil
+}
+
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPath has x509.Verify validate the whole path, trusting the topmost
+// CAs in cas, which also checks name constraints and usage nesting.
+func verifyPath(cert *x509.Certificate, cas map[string]*caEntry, at time.Time, usage string, report *VerifyReport, check func(string, error, string)) {
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if eku, ok := verifyExtKeyUsages[usage]; ok {
+		opts.KeyUsages = []x509.ExtKeyUsage{eku}
+	}
+	for _, ca := range cas {
+		if findParent(ca.cert, cas) == "" {
+			opts.Roots.AddCert(ca.cert)
+		} else {
+			opts.Intermediates.AddCert(ca.cert)
+		}
+	}
+	chains, err := cert.Verify(opts)
+	if err != nil {
+		check("Path validation", explainVerifyError(err), "")
+		return
+	}
+	var subjects []string
+	for _, c := range chains[0] {
+		subjects = append(subjects, c.Subject.CommonName)
+	}
+	check("Path validation", nil, strings.Join(subjects, " → "))
+}
+
+// explainVerifyError names the certificate an x509.Verify error is about.
+func explainVerifyError(err error) error {
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) && invalid.Cert != nil {
+		return fmt.Errorf("'%s': %v", invalid.Cert.Subject.CommonName, err)
+	}
+	var unknown x509.UnknownAuthorityError
+	if errors.As(err, &unknown) && unknown.Cert != nil {
+		return fmt.Errorf("'%s' does not chain up to a trusted CA: %v", unknown.Cert.Subject.CommonName, err)
+	}
+	return err
+}
+
+// verifyUsage checks a certificate for a key usage or extended key usage
+// name. A certificate without Key Usage or Extended Key Usage allows them all.
+func verifyUsage(cert *x509.Certificate, usage string) (string, error) {
+	if bit, ok := keyUsageNames[usage]; ok {
+		if cert.KeyUsage == 0 {
+			return "no Key Usage extension, so any usage is allowed", nil
+		}
+		if cert.KeyUsage&bit == 0 {
+			return "", fmt.Errorf("Key Usage is %s", strings.Join(certKeyUsage(cert), ", "))
+		}
+		return "in Key Usage", nil
+	}
+	oid, ok := extKeyUsageNames[usage]
+	if !ok {
+		return "", fmt.Errorf("unknown usage '%s'", usage)
+	}
+	ekus := extKeyUsageOIDs(cert.Extensions)
+	if len(ekus) == 0 {
+		return "no Extended Key Usage extension, so any usage is allowed", nil
+	}
+	var names []string
+	for _, eku := range ekus {
+		if eku.Equal(oid) || eku.Equal(extKeyUsageNames["any"]) {
+			return "in Extended Key Usage", nil
+		}
+		names = append(names, extKeyUsageName(eku))
+	}
+	return "", fmt.Errorf("Extended Key Usage is %s", strings.Join(names, ", "))
+}
+
+// verifyRevocation checks each certificate in the chain below the root
+// against the revocation registry. A certificate revoked after the chosen
+// time was still good at that time.
+func verifyRevocation(path []*x509.Certificate, at time.Time, check func(string, error, string)) {
+	for _, cert := range path {
+		if isSelfSigned(cert) {
+			continue
+		}
+		name := fmt.Sprintf("Revocation of '%s'", cert.Subject.CommonName)
+		entry := revocationStatus(cert)
+		switch {
+		case entry == nil:
+			check(name, nil, "not revoked")
+		case entry.RevokedAt.After(at):
+			check(name, nil, fmt.Sprintf("revoked (%s) later, on %s", entry.Reason, entry.RevokedAt.Format(time.RFC1123)))
+		case entry.Reason == reasonCertificateHold:
+			check(name, fmt.Errorf("on hold since %s", entry.RevokedAt.Format(time.RFC1123)), "")
+		default:
+			check(name, fmt.Errorf("revoked (%s) on %s", entry.Reason, entry.RevokedAt.Format(time.RFC1123)), "")
+		}
+	}
+}
+
+// verifyKeyMatch checks that a key file, or the certificate's own key,
+// holds the private key for the certificate's public key.
+func verifyKeyMatch(input VerifyInput, cert *x509.Certificate, check func(string, error, string)) {
+	var key crypto.Signer
+	var err error
+	keyPath := strings.Trim(strings.TrimSpace(input.KeyPath), `"`)
+	name := "Private key"
+	switch {
+	case keyPath != "":
+		name = fmt.Sprintf("Private key '%s'", keyPath)
+		var data []byte
+		if data, err = os.ReadFile(keyPath); err == nil {
+			key, err = decodeKeyImport(data, input.KeyPassword)
+		}
+	default:
+		keyFile := certKeyFile(input.CertName)
+		if keyFile == "" {
+			return
+		}
+		name = fmt.Sprintf("Private key '%s'", keyFile)
+		key, err = loadPrivateKey(keyFile)
+		var locked *keyLockedError
+		if errors.As(err, &locked) && !locked.token && input.KeyPassword != "" {
+			block, readErr := readKeyBlock(keyFile)
+			if readErr != nil {
+				err = readErr
+			} else {
+				key, err = decodeKeyBlock(block, input.KeyPassword)
+			}
+		}
+	}
+	if err != nil {
+		check(name, fmt.Errorf("could not read the key: %v", err), "")
+		return
+	}
+	if !publicKeysEqual(key.Public(), cert.PublicKey) {
+		err := fmt.Errorf("the key does not match the certificate's public key")
+		if keyType, certType := describePublicKey(key.Public()), describePublicKey(cert.PublicKey); keyType != certType {
+			err = fmt.Errorf("the key is %s but the certificate's public key is %s", keyType, certType)
+		}
+		check(name, err, "")
+		return
+	}
+	check(name, nil, "matches the certificate")
+}
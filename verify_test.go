@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyFailures verifies a certificate and returns the details of the
+// checks that failed, by check name.
+func verifyFailures(t *testing.T, input VerifyInput) map[string]string {
+	t.Helper()
+	report, err := (&App{}).VerifyCert(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := make(map[string]string)
+	for _, c := range report.Checks {
+		if !c.Passed {
+			failed[c.Name] = c.Detail
+		}
+	}
+	if report.Valid != (len(failed) == 0) {
+		t.Errorf("report valid is %v with %d failed checks", report.Valid, len(failed))
+	}
+	return failed
+}
+
+// wantFailures checks that exactly the named checks failed, each with a
+// detail containing the given text.
+func wantFailures(t *testing.T, failed map[string]string, want map[string]string) {
+	t.Helper()
+	for name, text := range want {
+		detail, ok := failed[name]
+		if !ok {
+			t.Errorf("check %q passed, want it to fail with %q", name, text)
+		} else if !strings.Contains(detail, text) {
+			t.Errorf("check %q failed with %q, want %q", name, detail, text)
+		}
+	}
+	for name, detail := range failed {
+		if _, ok := want[name]; !ok {
+			t.Errorf("check %q failed unexpectedly: %s", name, detail)
+		}
+	}
+}
+
+// testIntermediate returns an intermediate CA template with the given
+// validity, which is the test hierarchy's when zero.
+func testIntermediate(notBefore, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+}
+
+// testLeaf returns a TLS server certificate template for name.
+func testLeaf(name string) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		DNSNames:    []string{name},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+}
+
+// TestVerifyCert checks that each broken link of a chain is reported by the
+// check for that link, and only by the checks that depend on it.
+func TestVerifyCert(t *testing.T) {
+	const leafName = "verify.example.com"
+
+	t.Run("valid chain", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		root, intermediate, leaf, _, _, leafKey := testHierarchy(t, leafName)
+		testStoreCA(t, "root", root, "")
+		testStoreCA(t, "intermediate", intermediate, "root")
+		testStoreCert(t, "leaf.pem", leaf, leafKey, "intermediate")
+		failed := verifyFailures(t, VerifyInput{CertName: "leaf.pem", CAName: "intermediate", Hostname: leafName, Usage: "serverAuth"})
+		wantFailures(t, failed, nil)
+	})
+
+	t.Run("wrong issuer key", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		root, intermediate, leaf, rootKey, _, leafKey := testHierarchy(t, leafName)
+		// A CA with the intermediate's name but another key replaces it in the store
+		impostor, _ := testIssue(t, testIntermediate(intermediate.NotBefore, intermediate.NotAfter), root, rootKey)
+		testStoreCA(t, "root", root, "")
+		testStoreCA(t, "intermediate", impostor, "root")
+		testStoreCert(t, "leaf.pem", leaf, leafKey, "intermediate")
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem"}), map[string]string{
+			"Issuer of '" + leafName + "'": "CA 'intermediate' has the issuer's name but its key did not sign this certificate",
+			"Path validation":              "does not chain up to a trusted CA",
+		})
+	})
+
+	t.Run("expired intermediate", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		root, _, _, rootKey, _, _ := testHierarchy(t, leafName)
+		intermediate, intermediateKey := testIssue(t, testIntermediate(time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour)), root, rootKey)
+		leaf, leafKey := testIssue(t, testLeaf(leafName), intermediate, intermediateKey)
+		testStoreCA(t, "root", root, "")
+		testStoreCA(t, "intermediate", intermediate, "root")
+		testStoreCert(t, "leaf.pem", leaf, leafKey, "intermediate")
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem"}), map[string]string{
+			"Validity of 'Test Intermediate CA'": "expired on",
+			"Path validation":                    "'Test Intermediate CA'",
+		})
+	})
+
+	t.Run("path length", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		root, rootKey := testIssue(t, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "Test Root CA"},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			MaxPathLenZero:        true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}, nil, nil)
+		intermediate, intermediateKey := testIssue(t, testIntermediate(time.Time{}, time.Time{}), root, rootKey)
+		leaf, leafKey := testIssue(t, testLeaf(leafName), intermediate, intermediateKey)
+		testStoreCA(t, "root", root, "")
+		testStoreCA(t, "intermediate", intermediate, "root")
+		testStoreCert(t, "leaf.pem", leaf, leafKey, "intermediate")
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem"}), map[string]string{
+			"Path length of 'root'": "CA 'root' has path length 0 but 1 intermediate CA(s) sit below it",
+			"Path validation":       "too many intermediates",
+		})
+	})
+
+	t.Run("chosen CA, time, usage, revocation and key", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		root, intermediate, leaf, _, _, leafKey := testHierarchy(t, leafName)
+		other, _ := testIssue(t, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "Other Root CA"},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}, nil, nil)
+		testStoreCA(t, "root", root, "")
+		testStoreCA(t, "intermediate", intermediate, "root")
+		testStoreCA(t, "other", other, "")
+		testStoreCert(t, "leaf.pem", leaf, leafKey, "intermediate")
+
+		// Only the chosen CA, and the CAs above it, may anchor the chain
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem", CAName: "other"}), map[string]string{
+			"Issuer of '" + leafName + "'": "no CA with subject",
+			"Issued under CA 'other'":      "the chain does not pass through CA 'other'",
+			"Path validation":              "does not chain up to a trusted CA",
+		})
+
+		// Three days on, the whole test hierarchy has expired
+		at := time.Now().Add(72 * time.Hour).Format("2006-01-02T15:04")
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem", At: at}), map[string]string{
+			"Validity of '" + leafName + "'":     "expired on",
+			"Validity of 'Test Intermediate CA'": "expired on",
+			"Validity of 'Test Root CA'":         "expired on",
+			"Path validation":                    "'" + leafName + "'",
+		})
+
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem", Usage: "codeSigning", Hostname: "other.example.com"}), map[string]string{
+			"Usage 'codeSigning'":        "Extended Key Usage is serverAuth",
+			"Covers 'other.example.com'": "not other.example.com",
+			"Path validation":            "incompatible key usage",
+		})
+
+		if result := (&App{}).RevokeCert("leaf.pem", "keyCompromise"); !strings.HasPrefix(result, "Success") {
+			t.Fatal(result)
+		}
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem"}), map[string]string{
+			"Revocation of '" + leafName + "'": "revoked (keyCompromise)",
+		})
+		// An hour ago it had not been revoked yet
+		before := time.Now().Add(-time.Hour).Format("2006-01-02T15:04:05")
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem", At: before}), nil)
+
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeKeyPEM("leaf.key", otherKey, ""); err != nil {
+			t.Fatal(err)
+		}
+		wantFailures(t, verifyFailures(t, VerifyInput{CertName: "leaf.pem", At: before}), map[string]string{
+			"Private key 'leaf.key'": "the key does not match the certificate's public key",
+		})
+	})
+}